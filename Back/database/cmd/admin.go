@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"mysqr/database/pkg/postgres"
+)
+
+const adminUsage = `uso: database admin <comando> <usuario>
+
+  create <usuario>    crea una cuenta con rol admin
+  password <usuario>  cambia la contraseña de un admin
+
+La contraseña se lee de ADMIN_PASSWORD o, si no está, de la entrada
+estándar (una línea).`
+
+// minAdminPassword es el largo mínimo de la contraseña de un admin.
+const minAdminPassword = 12
+
+// runAdmin atiende `database admin ...` y termina el proceso. Es la única
+// forma de crear el primer admin: ningún endpoint da ese rol.
+func runAdmin(dbService *postgres.DatabaseService, args []string) {
+	if len(args) < 2 {
+		fmt.Fprintln(os.Stderr, adminUsage)
+		os.Exit(2)
+	}
+	username := args[1]
+
+	switch args[0] {
+	case "create":
+		if err := dbService.CreateAdmin(username, adminPassword()); err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("Admin %q creado", username)
+
+	case "password":
+		found, err := dbService.SetAdminPassword(username, adminPassword())
+		if err != nil {
+			log.Fatal(err)
+		}
+		if !found {
+			log.Fatalf("No existe un admin %q", username)
+		}
+		log.Printf("Contraseña de %q actualizada", username)
+
+	default:
+		fmt.Fprintln(os.Stderr, adminUsage)
+		os.Exit(2)
+	}
+}
+
+func adminPassword() string {
+	password := os.Getenv("ADMIN_PASSWORD")
+	if password == "" {
+		fmt.Fprint(os.Stderr, "Contraseña: ")
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			log.Fatalf("No se pudo leer la contraseña: %v", err)
+		}
+		password = strings.TrimRight(line, "\r\n")
+	}
+	if len(password) < minAdminPassword {
+		log.Fatalf("La contraseña debe tener al menos %d caracteres", minAdminPassword)
+	}
+	return password
+}
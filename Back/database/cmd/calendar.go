@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	"mysqr/database/pkg/postgres"
+)
+
+const calendarUsage = `uso: database calendar <comando>
+
+  terms              lista los períodos académicos
+  generate <periodo> crea los módulos del período según la plantilla de
+                     bloques, saltándose feriados; correrlo de nuevo no
+                     duplica nada`
+
+// runCalendar atiende `database calendar ...` y termina el proceso.
+func runCalendar(dbService *postgres.DatabaseService, args []string) {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, calendarUsage)
+		os.Exit(2)
+	}
+
+	switch args[0] {
+	case "terms":
+		periodos, err := dbService.GetTerms()
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, p := range periodos {
+			fmt.Printf("%-6d %-20s %s a %s\n", p.ID, p.Nombre, p.FechaInicio, p.FechaFin)
+		}
+
+	case "generate":
+		if len(args) < 2 {
+			log.Fatal("Falta el período: database calendar generate <periodo>")
+		}
+		periodoID, err := strconv.Atoi(args[1])
+		if err != nil {
+			log.Fatalf("Período inválido: %q", args[1])
+		}
+		resumen, err := dbService.GenerateModules(periodoID)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if resumen == nil {
+			log.Fatalf("No existe el período %d", periodoID)
+		}
+		log.Printf("Período %d: %d días hábiles (%d feriados) × %d bloques, %d módulos creados, %d ya existían",
+			periodoID, resumen.DiasHabiles, resumen.DiasFeriado, resumen.Bloques, resumen.Creados, resumen.Existentes)
+		if resumen.EnFeriado > 0 {
+			log.Printf("Ojo: %d módulos del período caen en feriados agregados después de generarlos", resumen.EnFeriado)
+		}
+
+	default:
+		fmt.Fprintln(os.Stderr, calendarUsage)
+		os.Exit(2)
+	}
+}
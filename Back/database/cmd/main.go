@@ -1,149 +1,238 @@
 package main
 
 import (
-	"database/sql"
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
-	"io"
 	"log"
 	"net/http"
 	"os"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 
+	"mysqr/database/pkg/canvas"
+	"mysqr/database/pkg/export"
+	"mysqr/database/pkg/ical"
+	"mysqr/database/pkg/models"
 	"mysqr/database/pkg/postgres"
+	"mysqr/pkg/authmw"
+	"mysqr/pkg/clock"
+	"mysqr/pkg/httpcors"
+	"mysqr/qr/pkg/auth"
 
-	"github.com/gorilla/handlers"
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
 	_ "github.com/lib/pq"
 )
 
-func initDatabase(db *sql.DB) error {
-	sqlFile := "migrations/001_init.sql"
-	content, err := os.ReadFile(sqlFile)
+func main() {
+	db, err := postgres.CreateConnection()
 	if err != nil {
-		return err
+		log.Fatalf("Failed to connect to database: %v", err)
 	}
+	defer db.Close()
 
-	statements := strings.Split(string(content), ";")
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		runMigrate(db, os.Args[2:])
+		return
+	}
 
-	for _, stmt := range statements {
-		stmt = strings.TrimSpace(stmt)
-		if stmt == "" {
-			continue
-		}
-		_, err := db.Exec(stmt)
+	// Al arrancar se aplican las migraciones pendientes (bajo advisory lock,
+	// así que varias réplicas pueden arrancar juntas). MIGRATE_ON_START=false
+	// lo desactiva para quien prefiera migrar a mano.
+	if getEnv("MIGRATE_ON_START", "true") != "false" {
+		runner, err := newMigrateRunner(db)
 		if err != nil {
-			return err
+			log.Fatalf("Failed to load migrations: %v", err)
+		}
+		if _, err := runner.Up(context.Background()); err != nil {
+			log.Fatalf("Failed to migrate database: %v", err)
 		}
 	}
 
-	return nil
-}
-
-func main() {
-	db, err := postgres.CreateConnection()
+	clk, err := clock.FromEnv()
 	if err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
+		log.Fatalf("Failed to read institution clock: %v", err)
 	}
-	defer db.Close()
-
-	/*
-		// Initialize database
-		if err := initDatabase(db); err != nil {
-			log.Fatalf("Failed to initialize database: %v", err)
-		}
-	*/
 
 	// Create database service
-	dbService := postgres.NewDatabaseService(db)
+	dbService := postgres.NewDatabaseService(db, clk)
 
-	// 1. Obtener moduloID basado en la fecha y hora actual
-	http.HandleFunc("/api/db/module/current", func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodGet {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-			return
+	if len(os.Args) > 1 && os.Args[1] == "partitions" {
+		runPartitions(dbService, os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "calendar" {
+		runCalendar(dbService, os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "admin" {
+		runAdmin(dbService, os.Args[2:])
+		return
+	}
+
+	rdb := redis.NewClient(&redis.Options{
+		Addr: getEnv("REDIS_HOST", "localhost") + ":" + getEnv("REDIS_PORT", "6379"),
+	})
+	keys := auth.NewRemoteKeySet(getEnv("JWKS_URL", "http://localhost:8087/.well-known/jwks.json"))
+	verifier := auth.NewVerifier(keys, auth.NewSessionStore(rdb))
+
+	r := gin.Default()
+	r.Use(httpcors.Middleware())
+
+	// ownsSection confirma que el usuario puede tocar la sección: el admin
+	// siempre, un profesor solo si Secciones.ProfesorID es el suyo. Si no,
+	// ya respondió el error y devuelve false.
+	ownsSection := func(c *gin.Context, seccionID int) bool {
+		claims := authmw.Claims(c)
+		if claims.Rol == "admin" {
+			return true
 		}
+		if claims.ProfesorID == nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "No tienes permisos para esta acción"})
+			return false
+		}
+		owner, err := dbService.IsSectionProfessor(seccionID, *claims.ProfesorID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return false
+		}
+		if !owner {
+			c.JSON(http.StatusForbidden, gin.H{"error": "No dictas esta sección"})
+			return false
+		}
+		return true
+	}
 
-		moduleID, err := dbService.GetCurrentModuleID()
+	// reportSection lee ?seccion_id= de un reporte de sección y exige que la
+	// sección sea del profesor.
+	reportSection := func(c *gin.Context) (int, bool) {
+		seccionID, err := strconv.Atoi(c.Query("seccion_id"))
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
+			c.JSON(http.StatusBadRequest, gin.H{"error": "ID de sección inválido"})
+			return 0, false
 		}
-		json.NewEncoder(w).Encode(map[string]int{"module_id": moduleID})
-	})
+		return seccionID, ownsSection(c, seccionID)
+	}
 
-	// 2. Registro en QRGenerado con el ProfesorID
-	http.HandleFunc("/api/db/qr/generate", func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodPost {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-			return
+	// reportStudent resuelve sección y alumno de un reporte individual. Un
+	// alumno solo ve el suyo (alumno_id sale del token); el profesor de la
+	// sección, el de cualquiera de sus alumnos. El alumno tiene que estar o
+	// haber estado inscrito en la sección: una baja no borra su historial.
+	reportStudent := func(c *gin.Context) (seccionID, alumnoID int, ok bool) {
+		seccionID, err := strconv.Atoi(c.Query("seccion_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "ID de sección inválido"})
+			return 0, 0, false
 		}
 
-		var request struct {
-			ProfesorID int `json:"profesor_id"`
+		claims := authmw.Claims(c)
+		if claims.Rol == "alumno" {
+			if alumnoID, ok = ownID(c, claims.AlumnoID); !ok {
+				return 0, 0, false
+			}
+		} else {
+			alumnoID, err = strconv.Atoi(c.Query("alumno_id"))
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "ID de alumno inválido"})
+				return 0, 0, false
+			}
+			if !ownsSection(c, seccionID) {
+				return 0, 0, false
+			}
 		}
-		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-			http.Error(w, "Invalid request body", http.StatusBadRequest)
-			return
+
+		enrolled, err := dbService.WasEnrolled(alumnoID, seccionID)
+		if err != nil {
+			log.Printf("Error al verificar inscripción: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al verificar la inscripción del estudiante"})
+			return 0, 0, false
+		}
+		if !enrolled {
+			c.JSON(http.StatusNotFound, gin.H{"error": "El estudiante no está inscrito en esta sección"})
+			return 0, 0, false
 		}
+		return seccionID, alumnoID, true
+	}
+
+	// El alta de alumnos es pública (se hace desde la pantalla de login) y el
+	// feed iCal se autentica con su propio token; todo lo demás exige JWT.
+	api := r.Group("/api/db")
+	authed := api.Group("", authmw.RequireAuth(verifier))
+	professor := authed.Group("", authmw.RequireRole("profesor", "admin"))
+	student := authed.Group("", authmw.RequireRole("alumno", "admin"))
 
-		id, err := dbService.RegisterQRGeneration(request.ProfesorID)
+	// 1. Obtener moduloID basado en la fecha y hora actual
+	authed.GET("/module/current", func(c *gin.Context) {
+		moduleID, err := dbService.GetCurrentModuleID()
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
-		json.NewEncoder(w).Encode(map[string]int64{"id": id})
+		c.JSON(http.StatusOK, gin.H{"module_id": moduleID})
 	})
 
-	// 3. Obtener Secciones.ID y Asignaturas.Nombre usando el ProfesorID
-	http.HandleFunc("/api/db/sections/professor/", func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodGet {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	// 2. Registro en QRGenerado con el ProfesorID (el del token)
+	authed.POST("/qr/generate", authmw.RequireRole("profesor"), func(c *gin.Context) {
+		profesorID, ok := ownID(c, authmw.Claims(c).ProfesorID)
+		if !ok {
 			return
 		}
 
-		idStr := strings.TrimPrefix(r.URL.Path, "/api/db/sections/professor/")
-		id, err := strconv.Atoi(idStr)
+		id, err := dbService.RegisterQRGeneration(profesorID)
 		if err != nil {
-			http.Error(w, "Invalid professor ID", http.StatusBadRequest)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"id": id})
+	})
+
+	// 3. Obtener Secciones.ID y Asignaturas.Nombre usando el ProfesorID.
+	// El ID sale del token (/api/db/sections/professor/me); si viene en la
+	// ruta tiene que ser el propio.
+	professor.GET("/sections/professor/:id", func(c *gin.Context) {
+		id, ok := ownID(c, authmw.Claims(c).ProfesorID)
+		if !ok {
 			return
 		}
 
 		sections, err := dbService.GetSectionsByProfessor(id)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
-		json.NewEncoder(w).Encode(sections)
+		c.JSON(http.StatusOK, sections)
 	})
 
-	// 3.1 Obtener ModuloID actual y SeccionID de ProgramacionClases para un profesor
-	http.HandleFunc("/api/db/professor/current-class", func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodGet {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	// 3.1 Obtener ModuloID actual y SeccionID de ProgramacionClases para el
+	// profesor del token
+	authed.GET("/professor/current-class", authmw.RequireRole("profesor"), func(c *gin.Context) {
+		profesorID, ok := ownID(c, authmw.Claims(c).ProfesorID)
+		if !ok {
 			return
 		}
 
-		profesorID, err := strconv.Atoi(r.URL.Query().Get("profesor_id"))
-		if err != nil {
-			http.Error(w, "Invalid professor ID", http.StatusBadRequest)
+		moduleSection, err := dbService.GetCurrentModuleAndSection(profesorID)
+		if errors.Is(err, postgres.ErrClaseAmbigua) {
+			// Dos secciones en el mismo horario: la elige el profesor desde
+			// GET /api/classes/current.
+			c.JSON(http.StatusConflict, gin.H{"error": "Hay más de una clase programada en este momento"})
 			return
 		}
-
-		moduleSection, err := dbService.GetCurrentModuleAndSection(profesorID)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
 
 		// Si no hay clase programada, devolvemos un objeto vacío con status 200
 		if moduleSection == nil {
-			w.WriteHeader(http.StatusOK)
-			json.NewEncoder(w).Encode(map[string]interface{}{})
+			c.JSON(http.StatusOK, gin.H{})
 			return
 		}
 
-		json.NewEncoder(w).Encode(moduleSection)
+		c.JSON(http.StatusOK, moduleSection)
 	})
 
 	// El registro de asistencia por QR ya no se acepta acá: ahora lo hace el
@@ -151,175 +240,431 @@ func main() {
 	// identidad contra el JWT antes de escribir. Este endpoint se eliminó
 	// porque quedaba abierto sin ninguna validación.
 
-	// 4.1. Registro en Asistencia manual
-	http.HandleFunc("/api/db/attendance/manual", func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodPost {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	// 4.1. Registro en Asistencia manual (solo el profesor de la sección)
+	professor.POST("/attendance/manual", func(c *gin.Context) {
+		var request struct {
+			AlumnoID  int `json:"alumno_id" binding:"required"`
+			SeccionID int `json:"seccion_id" binding:"required"`
+			ModuloID  int `json:"modulo_id" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Cuerpo de la solicitud inválido"})
 			return
 		}
 
-		var request struct {
-			AlumnoID  int `json:"alumno_id"`
-			SeccionID int `json:"seccion_id"`
-			ModuloID  int `json:"modulo_id"`
+		if !ownsSection(c, request.SeccionID) {
+			return
 		}
-		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-			http.Error(w, "Invalid request body", http.StatusBadRequest)
+		enrolled, err := dbService.IsEnrolled(request.AlumnoID, request.SeccionID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if !enrolled {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "El estudiante no está inscrito en esta sección"})
 			return
 		}
 
 		if err := dbService.RegisterManualAttendance(request.AlumnoID, request.SeccionID, request.ModuloID); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
-		w.WriteHeader(http.StatusOK)
+		c.Status(http.StatusOK)
 	})
 
-	// 4.2. Eliminar registros manuales de una sección
-	http.HandleFunc("/api/db/attendance/manual/delete", func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodPost {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	// 4.2. Eliminar registros manuales de una sección (solo su profesor)
+	professor.POST("/attendance/manual/delete", func(c *gin.Context) {
+		var request struct {
+			SeccionID int `json:"seccion_id" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Cuerpo de la solicitud inválido"})
 			return
 		}
 
-		var request struct {
-			SeccionID int `json:"seccion_id"`
-		}
-		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-			http.Error(w, "Invalid request body", http.StatusBadRequest)
+		if !ownsSection(c, request.SeccionID) {
 			return
 		}
 
 		if err := dbService.DeleteManualAttendanceBySection(request.SeccionID); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
-		w.WriteHeader(http.StatusOK)
+		c.Status(http.StatusOK)
 	})
 
-	// 5. Obtener SeccionesID y nombre de asignaturas con el AlumnoId
-	http.HandleFunc("/api/db/sections/student/", func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodGet {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	// 4.3. Cambiar a mano el estado de un alumno en una clase (present, late,
+	// justified, excused, absent), con motivo obligatorio. Solo el profesor
+	// de la sección.
+	professor.PUT("/attendance/status", func(c *gin.Context) {
+		var request struct {
+			AlumnoID  int    `json:"alumno_id" binding:"required"`
+			SeccionID int    `json:"seccion_id" binding:"required"`
+			ModuloID  int    `json:"modulo_id" binding:"required"`
+			Estado    string `json:"status" binding:"required"`
+			Motivo    string `json:"reason"`
+		}
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Cuerpo de la solicitud inválido"})
+			return
+		}
+		if !models.EstadoAsistenciaValido(request.Estado) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Estado inválido: usa present, late, justified, excused o absent"})
+			return
+		}
+		request.Motivo = strings.TrimSpace(request.Motivo)
+		if request.Motivo == "" || len(request.Motivo) > 500 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Indica el motivo del cambio (máximo 500 caracteres)"})
+			return
+		}
+
+		if !ownsSection(c, request.SeccionID) {
+			return
+		}
+		enrolled, err := dbService.IsEnrolled(request.AlumnoID, request.SeccionID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if !enrolled {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "El estudiante no está inscrito en esta sección"})
+			return
+		}
+		scheduled, err := dbService.IsScheduled(request.SeccionID, request.ModuloID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if !scheduled {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "La sección no tiene clase en ese módulo"})
+			return
+		}
+
+		if err := dbService.SetAttendanceStatus(request.AlumnoID, request.SeccionID, request.ModuloID,
+			request.Estado, request.Motivo, authmw.Claims(c).UserID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
+		c.Status(http.StatusOK)
+	})
 
-		idStr := strings.TrimPrefix(r.URL.Path, "/api/db/sections/student/")
-		id, err := strconv.Atoi(idStr)
+	// 4.4. Política de asistencia de la sección: minutos de gracia antes de
+	// marcar atraso y cuánto vale cada estado en el porcentaje.
+	professor.GET("/attendance/policy", func(c *gin.Context) {
+		seccionID, ok := reportSection(c)
+		if !ok {
+			return
+		}
+		politica, err := dbService.GetAttendancePolicy(seccionID)
 		if err != nil {
-			http.Error(w, "Invalid student ID", http.StatusBadRequest)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
+		c.JSON(http.StatusOK, politica)
+	})
 
-		sections, err := dbService.GetSectionsByStudent(id)
+	// Los campos que no vengan en el cuerpo conservan su valor actual.
+	professor.PUT("/attendance/policy", func(c *gin.Context) {
+		seccionID, ok := reportSection(c)
+		if !ok {
+			return
+		}
+		politica, err := dbService.GetAttendancePolicy(seccionID)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if err := c.ShouldBindJSON(&politica); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Cuerpo de la solicitud inválido"})
 			return
 		}
-		json.NewEncoder(w).Encode(sections)
+		politica.SeccionID = seccionID
+		if politica.MinutosGracia < 0 || politica.MinutosGracia > 240 ||
+			politica.CreditoTarde < 0 || politica.CreditoTarde > 100 ||
+			politica.CreditoJustificada < 0 || politica.CreditoJustificada > 100 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "grace_minutes va de 0 a 240 y los créditos de 0 a 100"})
+			return
+		}
+
+		if err := dbService.SetAttendancePolicy(politica); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, politica)
 	})
 
-	// 6. Obtener registros de ReporteAsistencia
-	http.HandleFunc("/api/db/attendance/report", func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodGet {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	// 5. Obtener SeccionesID y nombre de asignaturas con el AlumnoId. Igual
+	// que con el profesor, el ID sale del token (/api/db/sections/student/me).
+	student.GET("/sections/student/:id", func(c *gin.Context) {
+		id, ok := ownID(c, authmw.Claims(c).AlumnoID)
+		if !ok {
 			return
 		}
 
-		seccionID, err := strconv.Atoi(r.URL.Query().Get("seccion_id"))
+		sections, err := dbService.GetSectionsByStudent(id)
 		if err != nil {
-			http.Error(w, "Invalid section ID", http.StatusBadRequest)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, sections)
+	})
+
+	// 6. Reporte de asistencia de la sección (solo el profesor de la sección)
+	professor.GET("/attendance/report", func(c *gin.Context) {
+		seccionID, ok := reportSection(c)
+		if !ok {
 			return
 		}
 
-		// Ejecutar la función directamente
-		query := `SELECT * FROM obtener_asistencia_por_seccion($1)`
-		var reporte []byte
-		err = db.QueryRow(query, seccionID).Scan(&reporte)
+		reporte, err := dbService.GetSectionAttendanceReport(seccionID)
 		if err != nil {
 			log.Printf("Error al obtener reporte de asistencia: %v", err)
-			http.Error(w, "Error al obtener reporte de asistencia", http.StatusInternalServerError)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al obtener reporte de asistencia"})
 			return
 		}
 
-		// Establecer el tipo de contenido y enviar la respuesta
-		w.Header().Set("Content-Type", "application/json")
-		w.Write(reporte)
+		c.JSON(http.StatusOK, reporte)
 	})
 
-	// 6.1 Obtener asistencia de un estudiante específico
-	http.HandleFunc("/api/db/attendance/student", func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodGet {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	// 6.1 Obtener asistencia de un estudiante específico.
+	authed.GET("/attendance/student", func(c *gin.Context) {
+		seccionID, alumnoID, ok := reportStudent(c)
+		if !ok {
 			return
 		}
 
-		seccionID, err := strconv.Atoi(r.URL.Query().Get("seccion_id"))
+		reporte, err := dbService.GetStudentAttendanceReport(seccionID, alumnoID)
 		if err != nil {
-			log.Printf("Error al convertir seccion_id: %v", err)
-			http.Error(w, "ID de sección inválido", http.StatusBadRequest)
+			log.Printf("Error al obtener reporte de asistencia del estudiante: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al obtener reporte de asistencia"})
 			return
 		}
 
-		alumnoID, err := strconv.Atoi(r.URL.Query().Get("alumno_id"))
-		if err != nil {
-			log.Printf("Error al convertir alumno_id: %v", err)
-			http.Error(w, "ID de alumno inválido", http.StatusBadRequest)
+		c.JSON(http.StatusOK, reporte)
+	})
+
+	// 6.2 Reportes estructurados (v2): un registro tipado por clase con
+	// módulo, fecha, horario, estado (present, late, justified, excused o
+	// absent, ver models.EstadoAsistenciaValido), origen (qr/manual) y hora
+	// de registro. Mismos permisos que los de arriba; cómo dibujar cada
+	// estado queda del lado de la app.
+	v2 := authed.Group("/v2")
+	v2.GET("/attendance/report", authmw.RequireRole("profesor", "admin"), func(c *gin.Context) {
+		seccionID, ok := reportSection(c)
+		if !ok {
 			return
 		}
 
-		// Verificar que el estudiante está inscrito en la sección
-		enrolled, err := dbService.IsEnrolled(alumnoID, seccionID)
+		reporte, err := dbService.GetSectionAttendanceRecords(seccionID)
 		if err != nil {
-			log.Printf("Error al verificar inscripción: %v", err)
-			http.Error(w, "Error al verificar la inscripción del estudiante", http.StatusInternalServerError)
+			log.Printf("Error al obtener reporte de asistencia: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al obtener reporte de asistencia"})
 			return
 		}
 
-		if !enrolled {
-			http.Error(w, "El estudiante no está inscrito en esta sección", http.StatusNotFound)
+		c.JSON(http.StatusOK, reporte)
+	})
+
+	v2.GET("/attendance/student", func(c *gin.Context) {
+		seccionID, alumnoID, ok := reportStudent(c)
+		if !ok {
 			return
 		}
 
-		// Ejecutar la función directamente
-		query := `SELECT * FROM obtener_asistencia_estudiante_seccion($1, $2)`
-		var reporte []byte
-		err = db.QueryRow(query, seccionID, alumnoID).Scan(&reporte)
+		reporte, err := dbService.GetStudentAttendanceRecords(seccionID, alumnoID)
 		if err != nil {
 			log.Printf("Error al obtener reporte de asistencia del estudiante: %v", err)
-			http.Error(w, fmt.Sprintf("Error al obtener reporte de asistencia: %v", err), http.StatusInternalServerError)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al obtener reporte de asistencia"})
 			return
 		}
 
-		// Verificar que el reporte no esté vacío
-		if len(reporte) == 0 {
-			http.Error(w, "No se encontraron datos de asistencia", http.StatusNotFound)
+		c.JSON(http.StatusOK, reporte)
+	})
+
+	// 6.3 Exportar la asistencia de la sección como planilla
+	// (/attendance/export/csv o /attendance/export/xlsx): una fila por
+	// alumno, una columna por clase y al final totales y porcentaje. Se
+	// escribe a medida que se leen los alumnos, sin armarla en memoria. El
+	// token va en el header como en el resto de la API: la app descarga el
+	// archivo con fetch, nunca con el JWT en la URL (quedaría en los logs y
+	// en el historial del navegador).
+	api.GET("/attendance/export/:format", authmw.RequireAuth(verifier),
+		authmw.RequireRole("profesor", "admin"), func(c *gin.Context) {
+			format := c.Param("format")
+			if format != "csv" && format != "xlsx" {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Formato no soportado, usa csv o xlsx"})
+				return
+			}
+			seccionID, ok := reportSection(c)
+			if !ok {
+				return
+			}
+			modulos, err := dbService.GetSectionModules(seccionID)
+			if err != nil {
+				log.Printf("Error al obtener las clases de la sección: %v", err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al exportar la asistencia"})
+				return
+			}
+
+			filename := fmt.Sprintf("asistencia_%d_%s.%s", seccionID, clk.Now().Format("2006-01-02"), format)
+			c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+			c.Header("Cache-Control", "no-store")
+			write := func() error {
+				var sheet export.Sheet
+				var err error
+				if format == "csv" {
+					c.Header("Content-Type", "text/csv; charset=utf-8")
+					sheet, err = export.NewCSV(c.Writer)
+				} else {
+					c.Header("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+					sheet, err = export.NewXLSX(c.Writer, fmt.Sprintf("Sección %d", seccionID))
+				}
+				if err != nil {
+					return err
+				}
+				w, err := export.NewWriter(sheet, modulos)
+				if err != nil {
+					return err
+				}
+				if err := dbService.StreamSectionAttendance(seccionID, w.WriteStudent); err != nil {
+					return err
+				}
+				return w.Close()
+			}
+
+			// Con la respuesta ya empezada no se puede cambiar el status: el
+			// archivo queda truncado y solo se deja en el log.
+			if err := write(); err != nil {
+				log.Printf("Error al exportar la asistencia de la sección %d: %v", seccionID, err)
+			}
+		})
+
+	// prepararCurso completa y valida los datos de curso de una importación:
+	// la sección externa (por defecto el código), el período (por defecto el
+	// que está en curso o el próximo), los días y el bloque, que puede venir
+	// completo ("8:30-10:00") porque los módulos se buscan por la hora de
+	// término. Si algo no sirve ya respondió el error y devuelve false.
+	prepararCurso := func(c *gin.Context, curso *models.CursoImportado, bloque, periodo string) bool {
+		if curso.SeccionExterna == "" {
+			curso.SeccionExterna = curso.Codigo
+		}
+		var err error
+		if periodo != "" {
+			if curso.PeriodoID, err = strconv.Atoi(periodo); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "ID de período inválido"})
+				return false
+			}
+		} else if curso.PeriodoID, err = dbService.CurrentTermID(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return false
+		}
+		for _, dia := range curso.Dias {
+			if !slices.Contains(diasHabiles, dia) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Día inválido: " + dia})
+				return false
+			}
+		}
+		if bloque != "" {
+			_, fin, _ := strings.Cut(bloque, "-")
+			if fin == "" {
+				fin = bloque
+			}
+			if _, err := time.Parse("15:04", fin); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Bloque inválido, formato esperado HH:MM"})
+				return false
+			}
+			curso.Bloque = fin
+		}
+		return true
+	}
+
+	// 7. Importar un curso desde el export de alumnos de Canvas. Recibe el
+	// CSV tal cual (multipart, campo "file") más los días y el bloque del
+	// curso; el código sale del nombre del archivo salvo que venga
+	// "codigo". La sección se identifica por "seccion_externa" (por defecto
+	// el código): si ya existe se sincroniza su roster en vez de crear otra.
+	// Sin ?commit=true solo devuelve la vista previa. Una sección nueva
+	// queda a nombre del profesor del token.
+	authed.POST("/imports/canvas", authmw.RequireRole("profesor"), func(c *gin.Context) {
+		profesorID, ok := ownID(c, authmw.Claims(c).ProfesorID)
+		if !ok {
 			return
 		}
 
-		// Establecer el tipo de contenido y enviar la respuesta
-		w.Header().Set("Content-Type", "application/json")
-		w.Write(reporte)
-	})
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportSize)
+		fileHeader, err := c.FormFile("file")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Falta el archivo CSV (campo file) o es demasiado grande"})
+			return
+		}
 
-	// 7. Procesar estudiantes en lotes
-	http.HandleFunc("/api/db/sections/students/batch", func(w http.ResponseWriter, r *http.Request) {
-		log.Printf("Recibida petición POST en /api/db/sections/students/batch")
+		curso := models.CursoImportado{
+			Codigo: strings.TrimSpace(c.PostForm("codigo")),
+			Dias:   c.PostFormArray("dias"),
+		}
+		if curso.Codigo == "" {
+			curso.Codigo = canvas.CodigoDesdeArchivo(fileHeader.Filename)
+		}
+		if curso.Codigo == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "El nombre del archivo debe contener el código en formato CIT1000_CA16"})
+			return
+		}
+		curso.SeccionExterna = strings.TrimSpace(c.PostForm("seccion_externa"))
+		if !prepararCurso(c, &curso, c.PostForm("bloque"), c.PostForm("periodo_id")) {
+			return
+		}
 
-		if r.Method != http.MethodPost {
-			log.Printf("Método no permitido: %s", r.Method)
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		file, err := fileHeader.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "No se pudo leer el archivo"})
+			return
+		}
+		defer file.Close()
+		libro, err := canvas.Parse(file)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		curso.Nombre = libro.Seccion
+		if curso.Nombre == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "No se pudo extraer el nombre del curso del CSV"})
 			return
 		}
 
-		// Leer el cuerpo de la petición
-		body, err := io.ReadAll(r.Body)
+		confirmar := c.Query("commit") == "true"
+		resumen, err := dbService.ImportarCurso(curso, libro.Alumnos, profesorID, confirmar)
+		if errors.Is(err, postgres.ErrSeccionAjena) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "La sección " + curso.SeccionExterna + " es de otro profesor"})
+			return
+		}
 		if err != nil {
-			log.Printf("Error al leer el cuerpo de la petición: %v", err)
-			http.Error(w, "Error reading request body", http.StatusBadRequest)
+			log.Printf("Error al importar %s: %v", curso.Codigo, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al importar el curso"})
 			return
 		}
-		log.Printf("Cuerpo de la petición recibido: %s", string(body))
+		resumen.Omitidas = libro.Omitidas
+		if resumen.Omitidas == nil {
+			resumen.Omitidas = []models.FilaOmitida{}
+		}
+		if confirmar {
+			log.Printf("Roster %s importado en la sección %d: %d altas, %d bajas, %d cambios (profesor %d)",
+				curso.SeccionExterna, resumen.SeccionID, len(resumen.Agregados), len(resumen.Eliminados), len(resumen.Modificados), profesorID)
+		}
+		c.JSON(http.StatusOK, resumen)
+	})
+
+	// 7.1. Formato anterior a /imports/canvas: la app parseaba el CSV y
+	// mandaba el lote de alumnos ya armado como JSON. Se mantiene mientras
+	// se actualizan las apps instaladas; hace lo mismo que /imports/canvas
+	// con commit=true (sincroniza el roster de la sección del código).
+	// Responde con Deprecation y Link al endpoint nuevo.
+	authed.POST("/sections/students/batch", authmw.RequireRole("profesor"), func(c *gin.Context) {
+		profesorID, ok := ownID(c, authmw.Claims(c).ProfesorID)
+		if !ok {
+			return
+		}
+		c.Header("Deprecation", "true")
+		c.Header("Link", `</api/db/imports/canvas>; rel="successor-version"`)
 
 		var request struct {
 			Students []struct {
@@ -328,7 +673,7 @@ func main() {
 				NombreCompleto string `json:"NombreCompleto"`
 				Rut            string `json:"Rut"`
 				Email          string `json:"Email"`
-			} `json:"students"`
+			} `json:"students" binding:"required"`
 			Curso struct {
 				Codigo string   `json:"codigo"`
 				Nombre string   `json:"nombre"`
@@ -336,71 +681,444 @@ func main() {
 				Bloque string   `json:"bloque"`
 			} `json:"curso"`
 		}
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Cuerpo de la solicitud inválido"})
+			return
+		}
+		log.Printf("Uso de /sections/students/batch (obsoleto) por el profesor %d", profesorID)
+
+		// Este endpoint antes solo agregaba alumnos; ahora sincroniza y da de
+		// baja a los que no vienen. Un lote vacío (una app vieja con un bug)
+		// dejaría la sección sin alumnos, así que se rechaza.
+		if len(request.Students) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "La lista de estudiantes está vacía"})
+			return
+		}
 
-		if err := json.Unmarshal(body, &request); err != nil {
-			log.Printf("Error al decodificar JSON: %v", err)
-			http.Error(w, "Invalid request body", http.StatusBadRequest)
+		curso := models.CursoImportado{
+			Codigo: strings.TrimSpace(request.Curso.Codigo),
+			Nombre: strings.TrimSpace(request.Curso.Nombre),
+			Dias:   request.Curso.Dias,
+		}
+		if curso.Codigo == "" || curso.Nombre == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Faltan el código o el nombre del curso"})
+			return
+		}
+		if !prepararCurso(c, &curso, request.Curso.Bloque, "") {
 			return
 		}
 
-		// Obtener el ID del profesor del token o header
-		profesorID := r.Header.Get("X-Profesor-ID")
-		if profesorID == "" {
-			http.Error(w, "ID de profesor no proporcionado", http.StatusBadRequest)
+		// Un ID repetido se toma una sola vez (el primero), igual que en el
+		// CSV de Canvas: ImportarCurso no acepta dos filas del mismo alumno.
+		alumnos := make([]models.AlumnoImportado, 0, len(request.Students))
+		vistos := make(map[int]bool, len(request.Students))
+		for _, st := range request.Students {
+			id, err := strconv.Atoi(strings.TrimSpace(st.ID))
+			if err != nil || id <= 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "ID de alumno inválido: " + st.ID})
+				return
+			}
+			if vistos[id] {
+				continue
+			}
+			vistos[id] = true
+			alumnos = append(alumnos, models.AlumnoImportado{
+				ID:             id,
+				Rut:            canvas.Rut(st.Rut),
+				Nombre:         strings.TrimSpace(st.Nombre),
+				NombreCompleto: strings.TrimSpace(st.NombreCompleto),
+				Email:          strings.TrimSpace(st.Email),
+			})
+		}
+
+		resumen, err := dbService.ImportarCurso(curso, alumnos, profesorID, true)
+		if errors.Is(err, postgres.ErrSeccionAjena) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "La sección " + curso.SeccionExterna + " es de otro profesor"})
 			return
 		}
+		if err != nil {
+			log.Printf("Error al procesar lote de estudiantes: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al procesar estudiantes"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Estudiantes procesados exitosamente", "summary": resumen})
+	})
 
-		profesorIDInt, err := strconv.Atoi(profesorID)
+	// 8. Calendario académico: períodos, plantilla de bloques y feriados. Lo
+	// lee cualquiera con sesión; solo el admin lo cambia y genera los
+	// módulos de un período.
+	admin := authmw.RequireRole("admin")
+
+	authed.GET("/calendar/terms", func(c *gin.Context) {
+		periodos, err := dbService.GetTerms()
 		if err != nil {
-			http.Error(w, "ID de profesor inválido", http.StatusBadRequest)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, periodos)
+	})
+
+	authed.POST("/calendar/terms", admin, func(c *gin.Context) {
+		var periodo models.Periodo
+		if err := c.ShouldBindJSON(&periodo); err != nil || periodo.Nombre == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Cuerpo de la solicitud inválido"})
+			return
+		}
+		inicio, errInicio := time.Parse(time.DateOnly, periodo.FechaInicio)
+		fin, errFin := time.Parse(time.DateOnly, periodo.FechaFin)
+		if errInicio != nil || errFin != nil || fin.Before(inicio) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Fechas inválidas, formato esperado AAAA-MM-DD y fin no antes del inicio"})
 			return
 		}
 
-		// Procesar el lote de estudiantes
-		err = dbService.ProcesarLoteEstudiantes(request.Students, request.Curso, profesorIDInt)
+		periodo, err := dbService.CreateTerm(periodo)
 		if err != nil {
-			log.Printf("Error al procesar lote de estudiantes: %v", err)
-			http.Error(w, fmt.Sprintf("Error al procesar estudiantes: %v", err), http.StatusInternalServerError)
+			c.JSON(http.StatusConflict, gin.H{"error": "No se pudo crear el período: " + err.Error()})
+			return
+		}
+		c.JSON(http.StatusCreated, periodo)
+	})
+
+	authed.POST("/calendar/terms/:id/generate", admin, func(c *gin.Context) {
+		periodoID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "ID de período inválido"})
+			return
+		}
+
+		resumen, err := dbService.GenerateModules(periodoID)
+		if err != nil {
+			log.Printf("Error al generar módulos del período %d: %v", periodoID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al generar los módulos"})
+			return
+		}
+		if resumen == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Período no encontrado"})
+			return
+		}
+		c.JSON(http.StatusOK, resumen)
+	})
+
+	authed.GET("/calendar/blocks", func(c *gin.Context) {
+		bloques, err := dbService.GetBlockTemplate()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, bloques)
+	})
+
+	// Reemplaza la plantilla completa; los bloques no pueden solaparse.
+	authed.PUT("/calendar/blocks", admin, func(c *gin.Context) {
+		var bloques []models.BloqueHorario
+		if err := c.ShouldBindJSON(&bloques); err != nil || len(bloques) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Se espera una lista de bloques {start_time, end_time}"})
+			return
+		}
+		if err := validarBloques(bloques); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := dbService.SetBlockTemplate(bloques); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, bloques)
+	})
+
+	authed.GET("/calendar/holidays", func(c *gin.Context) {
+		feriados, err := dbService.GetHolidays()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, feriados)
+	})
+
+	// Un feriado es un día (sin end_date) o un receso de varios.
+	authed.POST("/calendar/holidays", admin, func(c *gin.Context) {
+		var feriado models.Feriado
+		if err := c.ShouldBindJSON(&feriado); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Cuerpo de la solicitud inválido"})
+			return
+		}
+		if feriado.FechaFin == "" {
+			feriado.FechaFin = feriado.FechaInicio
+		}
+		inicio, errInicio := time.Parse(time.DateOnly, feriado.FechaInicio)
+		fin, errFin := time.Parse(time.DateOnly, feriado.FechaFin)
+		if errInicio != nil || errFin != nil || fin.Before(inicio) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Fechas inválidas, formato esperado AAAA-MM-DD y fin no antes del inicio"})
+			return
+		}
+
+		feriado, err := dbService.CreateHoliday(feriado)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusCreated, feriado)
+	})
+
+	authed.DELETE("/calendar/holidays/:id", admin, func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+			return
+		}
+		found, err := dbService.DeleteHoliday(id)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if !found {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Feriado no encontrado"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Feriado eliminado"})
+	})
+
+	// 9. Clases canceladas: la clase sigue programada pero no cuenta en
+	// reportes ni se puede abrir su sesión, y el feed iCal la muestra
+	// cancelada. Solo el profesor de la sección; restore la vuelve a dejar
+	// vigente.
+	professor.POST("/classes/cancel", func(c *gin.Context) {
+		var request struct {
+			SeccionID int    `json:"seccion_id" binding:"required"`
+			ModuloID  int    `json:"modulo_id" binding:"required"`
+			Motivo    string `json:"reason"`
+		}
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Cuerpo de la solicitud inválido"})
+			return
+		}
+		request.Motivo = strings.TrimSpace(request.Motivo)
+		if request.Motivo == "" || len(request.Motivo) > 500 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Indica el motivo de la cancelación (máximo 500 caracteres)"})
+			return
+		}
+		if !ownsSection(c, request.SeccionID) {
 			return
 		}
 
-		w.WriteHeader(http.StatusOK)
-		json.NewEncoder(w).Encode(map[string]string{"message": "Estudiantes procesados exitosamente"})
+		found, err := dbService.CancelClass(request.SeccionID, request.ModuloID, request.Motivo)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if !found {
+			c.JSON(http.StatusNotFound, gin.H{"error": "La sección no tiene clase en ese módulo"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Clase cancelada"})
+	})
+
+	professor.POST("/classes/restore", func(c *gin.Context) {
+		var request struct {
+			SeccionID int `json:"seccion_id" binding:"required"`
+			ModuloID  int `json:"modulo_id" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Cuerpo de la solicitud inválido"})
+			return
+		}
+		if !ownsSection(c, request.SeccionID) {
+			return
+		}
+
+		found, err := dbService.RestoreClass(request.SeccionID, request.ModuloID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if !found {
+			c.JSON(http.StatusNotFound, gin.H{"error": "La sección no tiene clase en ese módulo"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Clase restablecida"})
+	})
+
+	// 10. Feed iCal del horario. El profesor o alumno del token pide (o
+	// rota) su URL secreta con POST, la consulta con GET y la revoca con
+	// DELETE; la URL se puede pegar en cualquier app de calendario.
+	feedOwner := func(c *gin.Context) (rol string, id int, ok bool) {
+		claims := authmw.Claims(c)
+		switch {
+		case claims.Rol == "profesor" && claims.ProfesorID != nil:
+			return claims.Rol, *claims.ProfesorID, true
+		case claims.Rol == "alumno" && claims.AlumnoID != nil:
+			return claims.Rol, *claims.AlumnoID, true
+		}
+		c.JSON(http.StatusForbidden, gin.H{"error": "Solo profesores y alumnos tienen calendario"})
+		return "", 0, false
+	}
+
+	authed.GET("/calendar/feed", func(c *gin.Context) {
+		rol, id, ok := feedOwner(c)
+		if !ok {
+			return
+		}
+		creado, err := dbService.GetCalendarTokenDate(rol, id)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"active": creado != nil, "created_at": creado})
 	})
 
-	// Endpoint para registrar alumno
-	http.HandleFunc("/api/db/alumno/register", func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodPost {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	// El token solo se muestra en esta respuesta (la base guarda su hash):
+	// si se pierde, se pide otro, que revoca el anterior.
+	authed.POST("/calendar/feed", func(c *gin.Context) {
+		rol, id, ok := feedOwner(c)
+		if !ok {
+			return
+		}
+		token, err := dbService.CreateCalendarToken(rol, id)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
 
+		scheme := "http"
+		if c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https" {
+			scheme = "https"
+		}
+		path := "/api/db/calendar/feed/" + token + ".ics"
+		c.JSON(http.StatusCreated, gin.H{"token": token, "path": path, "url": scheme + "://" + c.Request.Host + path})
+	})
+
+	authed.DELETE("/calendar/feed", func(c *gin.Context) {
+		rol, id, ok := feedOwner(c)
+		if !ok {
+			return
+		}
+		found, err := dbService.RevokeCalendarToken(rol, id)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if !found {
+			c.JSON(http.StatusNotFound, gin.H{"error": "No tienes un calendario activo"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Calendario revocado"})
+	})
+
+	// El feed en sí no lleva JWT (una app de calendario no sabe mandarlo): lo
+	// autentica el token de la URL, que se puede revocar.
+	api.GET("/calendar/feed/:token", func(c *gin.Context) {
+		rol, id, err := dbService.CalendarTokenOwner(strings.TrimSuffix(c.Param("token"), ".ics"))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if rol == "" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Calendario no encontrado"})
+			return
+		}
+		clases, err := dbService.GetCalendarClasses(rol, id)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.Header("Content-Type", "text/calendar; charset=utf-8")
+		c.Header("Content-Disposition", `inline; filename="mysqr.ics"`)
+		c.Header("Cache-Control", "private, no-cache")
+		c.Status(http.StatusOK)
+		if err := ical.Write(c.Writer, "MysQR - Horario", clk.Zone(), clases, clk.Now()); err != nil {
+			log.Printf("Error al escribir el calendario (%s %d): %v", rol, id, err)
+		}
+	})
+
+	// Endpoint para registrar alumno. Es el alta pública desde la app, así
+	// que es el único sin token (aparte del feed iCal, que trae el suyo).
+	api.POST("/alumno/register", func(c *gin.Context) {
 		var req struct {
-			Username string `json:"username"`
-			Password string `json:"password"`
+			Username string `json:"username" binding:"required"`
+			Password string `json:"password" binding:"required"`
 			Nombre   string `json:"nombre"`
 		}
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, "Invalid request body", http.StatusBadRequest)
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Cuerpo de la solicitud inválido"})
 			return
 		}
 
-		err := dbService.RegistrarAlumno(req.Username, req.Password, req.Nombre)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+		if err := dbService.RegistrarAlumno(req.Username, req.Password, req.Nombre); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
 
-		w.WriteHeader(http.StatusOK)
-		json.NewEncoder(w).Encode(map[string]string{"message": "Alumno registrado correctamente"})
+		c.JSON(http.StatusOK, gin.H{"message": "Alumno registrado correctamente"})
 	})
 
 	log.Println("Server started on :8084")
-	corsHandler := handlers.CORS(
-		handlers.AllowedOrigins([]string{"http://localhost:8081", "http://localhost:8080", "http://localhost:8088", "http://192.168.206.9:8088"}),
-		handlers.AllowedMethods([]string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}),
-		handlers.AllowedHeaders([]string{"Content-Type", "Authorization", "X-Profesor-ID"}),
-		handlers.AllowCredentials(),
-	)
-	log.Fatal(http.ListenAndServe(":8084", corsHandler(http.DefaultServeMux)))
+	if err := r.Run(":8084"); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// ownID devuelve el ID propio del usuario autenticado (own, sacado de los
+// claims). Si la ruta trae :id, tiene que ser "me" o ese mismo ID; el admin
+// puede pedir cualquiera. Si no corresponde ya respondió el error y devuelve
+// ok=false.
+func ownID(c *gin.Context, own *int) (int, bool) {
+	idStr := c.Param("id")
+	if idStr == "" || idStr == "me" {
+		if own == nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "No tienes permisos para esta acción"})
+			return 0, false
+		}
+		return *own, true
+	}
+
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return 0, false
+	}
+	if authmw.Claims(c).Rol != "admin" && (own == nil || *own != id) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "No tienes permisos para esta acción"})
+		return 0, false
+	}
+	return id, true
+}
+
+// maxImportSize es el tamaño máximo del CSV de una importación. Un export
+// de Canvas con notas de un curso grande anda por los cientos de KB.
+const maxImportSize = 10 << 20
+
+// diasHabiles son los días que se pueden elegir para las clases de un curso.
+var diasHabiles = []string{"Lunes", "Martes", "Miércoles", "Jueves", "Viernes"}
+
+// validarBloques revisa que cada bloque sea HH:MM-HH:MM, termine después
+// de empezar y no se solape con otro. Los deja en orden.
+func validarBloques(bloques []models.BloqueHorario) error {
+	for i, b := range bloques {
+		inicio, errInicio := time.Parse("15:04", b.HoraInicio)
+		fin, errFin := time.Parse("15:04", b.HoraFin)
+		if errInicio != nil || errFin != nil || !fin.After(inicio) {
+			return fmt.Errorf("bloque %s-%s inválido, formato esperado HH:MM y fin después del inicio", b.HoraInicio, b.HoraFin)
+		}
+		// Normaliza "8:30" a "08:30" para que el orden de strings sirva.
+		bloques[i] = models.BloqueHorario{HoraInicio: inicio.Format("15:04"), HoraFin: fin.Format("15:04")}
+	}
+	slices.SortFunc(bloques, func(a, b models.BloqueHorario) int {
+		return strings.Compare(a.HoraInicio, b.HoraInicio)
+	})
+	for i := 1; i < len(bloques); i++ {
+		if bloques[i].HoraInicio < bloques[i-1].HoraFin {
+			return fmt.Errorf("los bloques %s-%s y %s-%s se solapan",
+				bloques[i-1].HoraInicio, bloques[i-1].HoraFin, bloques[i].HoraInicio, bloques[i].HoraFin)
+		}
+	}
+	return nil
+}
+
+func getEnv(key, defaultValue string) string {
+	if v, ok := os.LookupEnv(key); ok {
+		return v
+	}
+	return defaultValue
 }
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	"mysqr/database/pkg/migrate"
+	"mysqr/migrations"
+	"mysqr/pkg/clock"
+)
+
+const migrateUsage = `uso: database migrate <comando>
+
+  up            aplica las migraciones pendientes
+  down [n]      revierte las últimas n migraciones (default 1)
+  status        lista las migraciones y cuándo se aplicó cada una
+  force <v>     marca como aplicadas hasta la versión v sin ejecutarlas
+                (para adoptar una base creada antes de schema_migrations)
+  seed          carga los datos de ejemplo de migrations/seeds`
+
+// newMigrateRunner arma el runner con mysqr.institution_tz tomado de
+// INSTITUTION_TZ: las migraciones que convierten horas de pared (la 011) la
+// leen de ahí en vez de suponer una zona.
+func newMigrateRunner(db *sql.DB) (*migrate.Runner, error) {
+	all, err := migrate.Load(migrations.Schema)
+	if err != nil {
+		return nil, err
+	}
+	clk, err := clock.FromEnv()
+	if err != nil {
+		return nil, err
+	}
+	runner := migrate.NewRunner(db, all, log.Printf)
+	runner.Set("mysqr.institution_tz", clk.Location.String())
+	return runner, nil
+}
+
+// runMigrate atiende `database migrate ...` y termina el proceso.
+func runMigrate(db *sql.DB, args []string) {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		os.Exit(2)
+	}
+
+	runner, err := newMigrateRunner(db)
+	if err != nil {
+		log.Fatalf("Error al leer las migraciones: %v", err)
+	}
+	ctx := context.Background()
+
+	switch args[0] {
+	case "up":
+		n, err := runner.Up(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("%d migraciones aplicadas", n)
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				log.Fatalf("Cantidad inválida: %q", args[1])
+			}
+		}
+		n, err := runner.Down(ctx, steps)
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("%d migraciones revertidas", n)
+
+	case "status":
+		statuses, err := runner.Status(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, st := range statuses {
+			applied := "pendiente"
+			if st.AppliedAt != nil {
+				applied = st.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%03d  %-20s  %s\n", st.Version, st.Name, applied)
+		}
+
+	case "force":
+		if len(args) < 2 {
+			log.Fatal("Falta la versión: database migrate force <v>")
+		}
+		version, err := strconv.Atoi(args[1])
+		if err != nil {
+			log.Fatalf("Versión inválida: %q", args[1])
+		}
+		if err := runner.Force(ctx, version); err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("Migraciones hasta la %03d marcadas como aplicadas", version)
+
+	case "seed":
+		if err := runner.Seed(ctx, migrations.Seeds); err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("Datos de ejemplo cargados")
+
+	default:
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		os.Exit(2)
+	}
+}
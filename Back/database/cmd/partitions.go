@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"mysqr/database/pkg/models"
+	"mysqr/database/pkg/postgres"
+)
+
+const partitionsUsage = `uso: database partitions <comando>
+
+  check              lista las secciones sin partición en Asistencia y las
+                     filas que cayeron en la DEFAULT
+  repair             crea las particiones que falten y mueve esas filas
+  archive <fecha>    separa y mueve al esquema archivo las particiones de
+                     las secciones cuya última clase fue antes de <fecha>
+                     (AAAA-MM-DD)`
+
+// runPartitions atiende `database partitions ...` y termina el proceso.
+func runPartitions(dbService *postgres.DatabaseService, args []string) {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, partitionsUsage)
+		os.Exit(2)
+	}
+
+	switch args[0] {
+	case "check":
+		estados, err := dbService.CheckPartitions()
+		if err != nil {
+			log.Fatal(err)
+		}
+		problemas := 0
+		for _, e := range estados {
+			if e.Estado == models.ParticionOK {
+				continue
+			}
+			problemas++
+			fmt.Printf("sección %-6d %-18s %-8s %d filas en DEFAULT\n", e.SeccionID, e.Tabla, e.Estado, e.FilasDefault)
+		}
+		if problemas == 0 {
+			log.Printf("Todas las secciones tienen sus particiones")
+			return
+		}
+		// Código distinto de cero para poder usarlo en un cron o healthcheck.
+		os.Exit(1)
+
+	case "repair":
+		reparadas, err := dbService.RepairPartitions()
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("%d secciones reparadas %v", len(reparadas), reparadas)
+
+	case "archive":
+		if len(args) < 2 {
+			log.Fatal("Falta la fecha: database partitions archive AAAA-MM-DD")
+		}
+		before, err := time.Parse("2006-01-02", args[1])
+		if err != nil {
+			log.Fatalf("Fecha inválida: %q", args[1])
+		}
+		archivadas, err := dbService.ArchivePartitions(before)
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("%d secciones archivadas %v", len(archivadas), archivadas)
+
+	default:
+		fmt.Fprintln(os.Stderr, partitionsUsage)
+		os.Exit(2)
+	}
+}
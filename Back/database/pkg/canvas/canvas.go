@@ -0,0 +1,205 @@
+// Package canvas lee el export del libro de calificaciones de Canvas
+// ("Grades-CIT1000_CA16.csv") y saca de ahí el curso y sus alumnos.
+package canvas
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"mysqr/database/pkg/models"
+)
+
+// El nombre del archivo exportado por Canvas trae el código de la sección,
+// ej: "2026-08-18T1200_Grades-CIT1000_CA16.csv".
+var codigoRe = regexp.MustCompile(`CIT\d+_CA\d+`)
+
+// CodigoDesdeArchivo devuelve el código del curso que viene en el nombre
+// del archivo, o "" si no calza con el formato de Canvas.
+func CodigoDesdeArchivo(filename string) string {
+	return codigoRe.FindString(filename)
+}
+
+// Columnas del export que se usan; el resto (tareas, notas) se ignora.
+const (
+	colAlumno  = "Student"
+	colID      = "ID"
+	colRut     = "SIS User ID"
+	colEmail   = "SIS Login ID"
+	colSeccion = "Section"
+)
+
+// Libro es lo que se saca de un export de Canvas.
+type Libro struct {
+	// Seccion es el nombre del curso: no viene en ningún encabezado
+	// explícito, sale de la columna "Section" del primer alumno.
+	Seccion  string
+	Alumnos  []models.AlumnoImportado
+	Omitidas []models.FilaOmitida
+}
+
+// Parse lee un export de Canvas. Se salta en silencio la fila "Points
+// Possible" y las de totales (no tienen ID de alumno); los alumnos
+// repetidos, los IDs inválidos y los nombres que no vienen como
+// "Apellido, Nombre" quedan en Omitidas con su línea.
+func Parse(r io.Reader) (*Libro, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("error al leer el archivo: %w", err)
+	}
+	data = bytes.TrimPrefix(data, []byte("\ufeff"))
+	if !utf8.Valid(data) {
+		// Excel en Windows lo guarda en Latin-1 al reeditarlo.
+		data = latin1(data)
+	}
+
+	cr := csv.NewReader(bytes.NewReader(data))
+	cr.FieldsPerRecord = -1
+	cr.LazyQuotes = true
+
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil, errors.New("el archivo está vacío")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("encabezado inválido: %w", err)
+	}
+	cols := map[string]int{}
+	for i, name := range header {
+		cols[strings.TrimSpace(name)] = i
+	}
+	for _, name := range []string{colAlumno, colID, colRut, colEmail, colSeccion} {
+		if _, ok := cols[name]; !ok {
+			return nil, fmt.Errorf("falta la columna %q: no parece un export de Canvas", name)
+		}
+	}
+
+	libro := &Libro{}
+	vistos := map[int]bool{}
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("CSV inválido: %w", err)
+		}
+		linea, _ := cr.FieldPos(0)
+		campo := func(name string) string {
+			if i := cols[name]; i < len(record) {
+				return strings.TrimSpace(arreglarTexto(record[i]))
+			}
+			return ""
+		}
+		omitir := func(motivo string) {
+			libro.Omitidas = append(libro.Omitidas, models.FilaOmitida{Linea: linea, Motivo: motivo})
+		}
+
+		alumno := campo(colAlumno)
+		id, err := strconv.Atoi(campo(colID))
+		if err != nil || id <= 0 {
+			// "Points Possible" y las filas de totales no tienen ID. No
+			// son errores del archivo, así que no se informan.
+			if alumno != "" && alumno != "Points Possible" {
+				omitir("ID de alumno inválido")
+			}
+			continue
+		}
+		if vistos[id] {
+			omitir(fmt.Sprintf("alumno %d repetido", id))
+			continue
+		}
+
+		apellido, nombre, ok := strings.Cut(alumno, ",")
+		apellido, nombre = normalizar(apellido), normalizar(nombre)
+		if !ok || apellido == "" || nombre == "" {
+			omitir(fmt.Sprintf("nombre %q no viene como \"Apellido, Nombre\"", alumno))
+			continue
+		}
+		primerNombre, _, _ := strings.Cut(nombre, " ")
+
+		vistos[id] = true
+		libro.Alumnos = append(libro.Alumnos, models.AlumnoImportado{
+			ID:             id,
+			Rut:            Rut(campo(colRut)),
+			Nombre:         primerNombre,
+			NombreCompleto: nombre + " " + apellido,
+			Email:          campo(colEmail),
+		})
+		if libro.Seccion == "" {
+			libro.Seccion = campo(colSeccion)
+		}
+	}
+
+	if len(libro.Alumnos) == 0 {
+		return nil, errors.New("no se encontraron alumnos válidos en el archivo")
+	}
+	return libro, nil
+}
+
+// Rut saca el número del RUT ("12.345.678-9" o "12345678"), sin el dígito
+// verificador. Devuelve 0 si no hay uno válido.
+func Rut(s string) int {
+	s, _, _ = strings.Cut(s, "-")
+	s = strings.ReplaceAll(s, ".", "")
+	n, err := strconv.Atoi(s)
+	if err != nil || n <= 0 {
+		return 0
+	}
+	return n
+}
+
+// normalizar deja un nombre con un solo espacio entre palabras.
+func normalizar(s string) string {
+	return strings.Join(strings.Fields(strings.ReplaceAll(s, `"`, "")), " ")
+}
+
+// latin1 convierte texto ISO-8859-1 a UTF-8.
+func latin1(data []byte) []byte {
+	var b bytes.Buffer
+	b.Grow(len(data))
+	for _, c := range data {
+		b.WriteRune(rune(c))
+	}
+	return b.Bytes()
+}
+
+// Windows-1252 usa 0x80-0x9F para caracteres que en Unicode están más
+// arriba; hace falta para deshacer el "Ã‰" de una "É" doblemente
+// codificada.
+var cp1252 = map[rune]byte{
+	'€': 0x80, '‚': 0x82, 'ƒ': 0x83, '„': 0x84, '…': 0x85, '†': 0x86, '‡': 0x87,
+	'ˆ': 0x88, '‰': 0x89, 'Š': 0x8A, '‹': 0x8B, 'Œ': 0x8C, 'Ž': 0x8E,
+	'‘': 0x91, '’': 0x92, '“': 0x93, '”': 0x94, '•': 0x95, '–': 0x96, '—': 0x97,
+	'˜': 0x98, '™': 0x99, 'š': 0x9A, '›': 0x9B, 'œ': 0x9C, 'ž': 0x9E, 'Ÿ': 0x9F,
+}
+
+// arreglarTexto deshace el UTF-8 leído como Latin-1/Windows-1252 y vuelto
+// a guardar ("MuÃ±oz" -> "Muñoz"), que es como llegan los nombres cuando
+// el CSV pasó por Excel. Si el texto no tiene esa pinta, lo deja igual.
+func arreglarTexto(s string) string {
+	if !strings.ContainsAny(s, "ÃÂ") {
+		return s
+	}
+	b := make([]byte, 0, len(s))
+	for _, r := range s {
+		switch c, ok := cp1252[r]; {
+		case ok:
+			b = append(b, c)
+		case r < 0x100:
+			b = append(b, byte(r))
+		default:
+			return s
+		}
+	}
+	if !utf8.Valid(b) {
+		return s
+	}
+	return string(b)
+}
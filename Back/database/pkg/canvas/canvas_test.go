@@ -0,0 +1,155 @@
+package canvas
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"mysqr/database/pkg/models"
+)
+
+const header = "Student,ID,SIS User ID,SIS Login ID,Section,Tarea 1 (123)\n"
+
+func TestParse(t *testing.T) {
+	csv := header +
+		"    Points Possible,,,,,10\n" +
+		`"Muñoz, Ana  María",101,12.345.678-9,ana@uni.cl,CIT1000 Sección 16,7` + "\n" +
+		`"Pérez, Juan",102,9876543-K,juan@uni.cl,CIT1000 Sección 16,6` + "\n" +
+		`"Muñoz, Ana María",101,12.345.678-9,ana@uni.cl,CIT1000 Sección 16,7` + "\n" +
+		`Sin Coma,103,11111111-1,sc@uni.cl,CIT1000 Sección 16,5` + "\n" +
+		`"Rojas, Pedro",abc,22222222-2,pr@uni.cl,CIT1000 Sección 16,5` + "\n" +
+		",,,,,\n"
+
+	libro, err := Parse(strings.NewReader(csv))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if libro.Seccion != "CIT1000 Sección 16" {
+		t.Errorf("Seccion = %q", libro.Seccion)
+	}
+	wantAlumnos := []models.AlumnoImportado{
+		{ID: 101, Rut: 12345678, Nombre: "Ana", NombreCompleto: "Ana María Muñoz", Email: "ana@uni.cl"},
+		{ID: 102, Rut: 9876543, Nombre: "Juan", NombreCompleto: "Juan Pérez", Email: "juan@uni.cl"},
+	}
+	if !reflect.DeepEqual(libro.Alumnos, wantAlumnos) {
+		t.Errorf("Alumnos = %+v\nse esperaba %+v", libro.Alumnos, wantAlumnos)
+	}
+	wantOmitidas := []models.FilaOmitida{
+		{Linea: 5, Motivo: "alumno 101 repetido"},
+		{Linea: 6, Motivo: `nombre "Sin Coma" no viene como "Apellido, Nombre"`},
+		{Linea: 7, Motivo: "ID de alumno inválido"},
+	}
+	if !reflect.DeepEqual(libro.Omitidas, wantOmitidas) {
+		t.Errorf("Omitidas = %+v\nse esperaba %+v", libro.Omitidas, wantOmitidas)
+	}
+}
+
+func TestParseEncoding(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"UTF-8", []byte(header + `"Muñoz, José",101,1-9,j@uni.cl,Curso É,1` + "\n")},
+		{"UTF-8 con BOM", []byte("\ufeff" + header + `"Muñoz, José",101,1-9,j@uni.cl,Curso É,1` + "\n")},
+		{
+			// Excel en Windows: ñ = 0xF1, é = 0xE9, É = 0xC9.
+			"Latin-1",
+			[]byte(header + "\"Mu\xf1oz, Jos\xe9\",101,1-9,j@uni.cl,Curso \xc9,1\n"),
+		},
+		{
+			// UTF-8 leído como Windows-1252 y vuelto a guardar en UTF-8.
+			"doble codificación",
+			[]byte(header + `"MuÃ±oz, JosÃ©",101,1-9,j@uni.cl,Curso Ã‰,1` + "\n"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			libro, err := Parse(strings.NewReader(string(tt.data)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(libro.Alumnos) != 1 {
+				t.Fatalf("Alumnos = %+v", libro.Alumnos)
+			}
+			if got := libro.Alumnos[0].NombreCompleto; got != "José Muñoz" {
+				t.Errorf("NombreCompleto = %q, se esperaba %q", got, "José Muñoz")
+			}
+			if libro.Seccion != "Curso É" {
+				t.Errorf("Seccion = %q, se esperaba %q", libro.Seccion, "Curso É")
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		csv  string
+		want string
+	}{
+		{"vacío", "", "el archivo está vacío"},
+		{"sin columna ID", "Student,SIS User ID,SIS Login ID,Section\n", `falta la columna "ID"`},
+		{"sin alumnos válidos", header + "    Points Possible,,,,,10\n", "no se encontraron alumnos válidos"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(strings.NewReader(tt.csv))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Parse = %v, se esperaba un error con %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestArreglarTexto(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"MuÃ±oz", "Muñoz"},
+		{"Ã‰rika", "Érika"},
+		{"Muñoz", "Muñoz"},
+		{"Ángel", "Ángel"},
+		// Tiene una Ã pero no es doble codificación: se deja igual.
+		{"Ã y 日本", "Ã y 日本"},
+	}
+	for _, tt := range tests {
+		if got := arreglarTexto(tt.in); got != tt.want {
+			t.Errorf("arreglarTexto(%q) = %q, se esperaba %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestCodigoDesdeArchivo(t *testing.T) {
+	tests := []struct{ filename, want string }{
+		{"2026-08-18T1200_Grades-CIT1000_CA16.csv", "CIT1000_CA16"},
+		{"Grades-CIT2310_CA3.csv", "CIT2310_CA3"},
+		{"CIT1000_CA16 (1).csv", "CIT1000_CA16"},
+		{"Grades-MAT1000_CA16.csv", ""},
+		{"notas.csv", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := CodigoDesdeArchivo(tt.filename); got != tt.want {
+			t.Errorf("CodigoDesdeArchivo(%q) = %q, se esperaba %q", tt.filename, got, tt.want)
+		}
+	}
+}
+
+func TestRut(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int
+	}{
+		{"12.345.678-9", 12345678},
+		{"12345678-K", 12345678},
+		{"12345678", 12345678},
+		{"", 0},
+		{"abc", 0},
+		{"-5", 0},
+	}
+	for _, tt := range tests {
+		if got := Rut(tt.in); got != tt.want {
+			t.Errorf("Rut(%q) = %d, se esperaba %d", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+// Package export arma la planilla de asistencia de una sección (una fila
+// por alumno, una columna por clase, más totales y porcentaje) y la escribe
+// en CSV o XLSX a medida que llegan los alumnos.
+package export
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"mysqr/database/pkg/models"
+	"mysqr/pkg/xlsx"
+)
+
+// Sheet recibe las filas de la planilla una por una.
+type Sheet interface {
+	WriteRow(cells []any) error
+	Close() error
+}
+
+// NewCSV escribe la planilla como CSV. Parte con un BOM de UTF-8 para que
+// Excel no rompa los tildes al abrirlo.
+func NewCSV(w io.Writer) (Sheet, error) {
+	if _, err := io.WriteString(w, "\ufeff"); err != nil {
+		return nil, err
+	}
+	return &csvSheet{w: csv.NewWriter(w)}, nil
+}
+
+// NewXLSX escribe la planilla como .xlsx de una hoja.
+func NewXLSX(w io.Writer, sheetName string) (Sheet, error) {
+	return xlsx.NewWriter(w, sheetName)
+}
+
+type csvSheet struct {
+	w *csv.Writer
+}
+
+func (s *csvSheet) WriteRow(cells []any) error {
+	record := make([]string, len(cells))
+	for i, cell := range cells {
+		switch v := cell.(type) {
+		case nil:
+		case int, float64:
+			record[i] = fmt.Sprint(v)
+		default:
+			record[i] = neutralizeFormula(fmt.Sprint(v))
+		}
+	}
+	return s.w.Write(record)
+}
+
+// neutralizeFormula antepone una comilla a un texto que Excel o LibreOffice
+// leerían como fórmula al abrir el CSV (empieza con =, +, -, @, tabulación
+// o retorno de carro). Los nombres de alumnos y cursos vienen de imports
+// externos, así que no se puede confiar en que no traigan un =HYPERLINK(...).
+// En el .xlsx no hace falta: las celdas de texto se escriben como texto.
+func neutralizeFormula(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+func (s *csvSheet) Close() error {
+	s.w.Flush()
+	return s.w.Error()
+}
+
+// etiquetas son los nombres de cada estado en la planilla.
+var etiquetas = map[string]string{
+	models.AsistenciaPresente:    "Presente",
+	models.AsistenciaTarde:       "Atrasado",
+	models.AsistenciaJustificada: "Justificada",
+	models.AsistenciaExcusada:    "Excusada",
+	models.AsistenciaAusente:     "Ausente",
+}
+
+// totales son las columnas de conteo, en orden, después de las clases.
+var totales = []struct{ estado, titulo string }{
+	{models.AsistenciaPresente, "Presentes"},
+	{models.AsistenciaTarde, "Atrasos"},
+	{models.AsistenciaJustificada, "Justificadas"},
+	{models.AsistenciaExcusada, "Excusadas"},
+	{models.AsistenciaAusente, "Ausentes"},
+}
+
+// Writer escribe la planilla de una sección con las clases dadas como
+// columnas.
+type Writer struct {
+	sheet   Sheet
+	modulos []models.ModuloClase
+}
+
+// NewWriter escribe el encabezado: ID, nombre, fecha de baja (vacía si
+// sigue inscrito), una columna por clase
+// ("DD-MM-AAAA HH:MM-HH:MM"), los conteos por estado, clases dictadas y
+// porcentaje.
+func NewWriter(sheet Sheet, modulos []models.ModuloClase) (*Writer, error) {
+	header := []any{"ID", "Alumno", "Baja"}
+	for _, m := range modulos {
+		header = append(header, titulo(m))
+	}
+	for _, t := range totales {
+		header = append(header, t.titulo)
+	}
+	header = append(header, "Dictadas", "% Asistencia")
+	if err := sheet.WriteRow(header); err != nil {
+		return nil, err
+	}
+	return &Writer{sheet: sheet, modulos: modulos}, nil
+}
+
+// WriteStudent escribe la fila de un alumno. Las clases que todavía no se
+// dictan, y las posteriores a la baja de un alumno, quedan en blanco.
+func (w *Writer) WriteStudent(a models.RegistrosAlumno) error {
+	porModulo := make(map[int]models.RegistroAsistencia, len(a.Registros))
+	for _, r := range a.Registros {
+		porModulo[r.ModuloID] = r
+	}
+	row := []any{a.AlumnoID, a.Nombre, nil}
+	if a.FechaBaja != nil {
+		row[2] = a.FechaBaja.Format("02-01-2006")
+	}
+	for _, m := range w.modulos {
+		r, ok := porModulo[m.ModuloID]
+		if !ok || (!r.Dictada && r.Origen == "") {
+			row = append(row, nil)
+			continue
+		}
+		row = append(row, etiquetas[r.Estado])
+	}
+	for _, t := range totales {
+		row = append(row, a.Resumen.PorEstado[t.estado])
+	}
+	row = append(row, a.Resumen.Dictadas, a.Resumen.Porcentaje)
+	return w.sheet.WriteRow(row)
+}
+
+// Close termina la planilla.
+func (w *Writer) Close() error {
+	return w.sheet.Close()
+}
+
+func titulo(m models.ModuloClase) string {
+	fecha := m.Fecha
+	if t, err := time.Parse("2006-01-02", m.Fecha); err == nil {
+		fecha = t.Format("02-01-2006")
+	}
+	return fmt.Sprintf("%s %s-%s", fecha, m.HoraInicio, m.HoraFin)
+}
@@ -0,0 +1,61 @@
+package export
+
+import (
+	"bytes"
+	"encoding/csv"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestCSVNeutralizesFormulas(t *testing.T) {
+	var buf bytes.Buffer
+	sheet, err := NewCSV(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	row := []any{
+		`=HYPERLINK("http://x","y")`,
+		"+56 9 1234",
+		"-Muñoz",
+		"@SUM(A1)",
+		"\tTab",
+		"\rCR",
+		"Pérez, Ana",
+		"a=b",
+		-3,
+		-1.5,
+		nil,
+	}
+	if err := sheet.WriteRow(row); err != nil {
+		t.Fatal(err)
+	}
+	if err := sheet.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	out := strings.TrimPrefix(buf.String(), "\ufeff")
+	if len(out) == buf.Len() {
+		t.Error("el CSV no parte con BOM")
+	}
+	got, err := csv.NewReader(strings.NewReader(out)).Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		`'=HYPERLINK("http://x","y")`,
+		"'+56 9 1234",
+		"'-Muñoz",
+		"'@SUM(A1)",
+		"'\tTab",
+		"'\rCR",
+		"Pérez, Ana",
+		"a=b",
+		"-3",
+		"-1.5",
+		"",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("fila = %q\nse esperaba %q", got, want)
+	}
+}
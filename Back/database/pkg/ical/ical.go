@@ -0,0 +1,125 @@
+// Package ical escribe el horario de clases de un usuario como calendario
+// iCalendar (RFC 5545), para suscribirse desde Google Calendar, Outlook o
+// el calendario del teléfono.
+package ical
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"mysqr/database/pkg/models"
+)
+
+// Las horas van en UTC (sufijo Z): así no hace falta un VTIMEZONE y cada
+// app las muestra en la zona del usuario.
+const utcLayout = "20060102T150405Z"
+
+// largoLinea es el máximo de octetos por línea antes de doblarla.
+const largoLinea = 75
+
+// Write escribe el calendario con una VEVENT por clase. Una clase cancelada
+// o en un feriado sale con STATUS:CANCELLED (y el motivo en la
+// descripción), así la app que ya la tenía la marca en vez de dejarla
+// como si se dictara. El UID es estable por sección y módulo para que cada
+// actualización del feed reemplace el evento. zona (IANA) solo es la
+// sugerencia de X-WR-TIMEZONE; ahora va en DTSTAMP.
+func Write(w io.Writer, nombre, zona string, clases []models.ClaseCalendario, ahora time.Time) error {
+	bw := bufio.NewWriter(w)
+	l := &lineas{w: bw}
+
+	l.prop("BEGIN", "VCALENDAR")
+	l.prop("VERSION", "2.0")
+	l.prop("PRODID", "-//MysQR//Horario de clases//ES")
+	l.prop("CALSCALE", "GREGORIAN")
+	l.prop("METHOD", "PUBLISH")
+	l.prop("X-WR-CALNAME", texto(nombre))
+	l.prop("X-WR-TIMEZONE", zona)
+	// Cada cuánto sugerimos a la app volver a leer el feed.
+	l.prop("REFRESH-INTERVAL;VALUE=DURATION", "PT1H")
+	l.prop("X-PUBLISHED-TTL", "PT1H")
+
+	stamp := ahora.UTC().Format(utcLayout)
+	for _, c := range clases {
+		cancelada := c.Cancelada || c.Feriado != ""
+		resumen := fmt.Sprintf("%s (%s)", c.Nombre, c.Codigo)
+		descripcion := fmt.Sprintf("Sección %d", c.SeccionID)
+		switch {
+		case c.Cancelada:
+			resumen = "Cancelada: " + resumen
+			if c.Motivo != "" {
+				descripcion += "\nClase cancelada: " + c.Motivo
+			} else {
+				descripcion += "\nClase cancelada"
+			}
+		case c.Feriado != "":
+			resumen = "Sin clases: " + resumen
+			descripcion += "\nFeriado: " + c.Feriado
+		}
+
+		l.prop("BEGIN", "VEVENT")
+		l.prop("UID", fmt.Sprintf("clase-%d-%d@mysqr", c.SeccionID, c.ModuloID))
+		l.prop("DTSTAMP", stamp)
+		l.prop("DTSTART", c.Inicio.UTC().Format(utcLayout))
+		l.prop("DTEND", c.Fin.UTC().Format(utcLayout))
+		l.prop("SUMMARY", texto(resumen))
+		if c.Ubicacion != "" {
+			l.prop("LOCATION", texto(c.Ubicacion))
+		}
+		l.prop("DESCRIPTION", texto(descripcion))
+		if cancelada {
+			l.prop("STATUS", "CANCELLED")
+		} else {
+			l.prop("STATUS", "CONFIRMED")
+		}
+		l.prop("TRANSP", "OPAQUE")
+		l.prop("END", "VEVENT")
+	}
+	l.prop("END", "VCALENDAR")
+
+	if l.err != nil {
+		return l.err
+	}
+	return bw.Flush()
+}
+
+// lineas escribe las propiedades con CRLF, doblando las que pasan de
+// largoLinea octetos sin cortar un carácter UTF-8. Guarda el primer error.
+type lineas struct {
+	w   *bufio.Writer
+	err error
+}
+
+func (l *lineas) prop(nombre, valor string) {
+	if l.err != nil {
+		return
+	}
+	linea := nombre + ":" + valor
+	limite := largoLinea
+	for len(linea) > limite {
+		corte := limite
+		for corte > 0 && !utf8.RuneStart(linea[corte]) {
+			corte--
+		}
+		if _, l.err = l.w.WriteString(linea[:corte] + "\r\n "); l.err != nil {
+			return
+		}
+		linea = linea[corte:]
+		// La continuación empieza con un espacio, que cuenta en el largo.
+		limite = largoLinea - 1
+	}
+	_, l.err = l.w.WriteString(linea + "\r\n")
+}
+
+// texto escapa un valor TEXT: barra invertida, punto y coma, coma y saltos
+// de línea.
+var texto = strings.NewReplacer(
+	`\`, `\\`,
+	";", `\;`,
+	",", `\,`,
+	"\r\n", `\n`,
+	"\n", `\n`,
+).Replace
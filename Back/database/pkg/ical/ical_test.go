@@ -0,0 +1,199 @@
+package ical
+
+import (
+	"bufio"
+	"bytes"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"mysqr/database/pkg/models"
+)
+
+// desdoblar junta las líneas dobladas y separa las propiedades.
+func desdoblar(t *testing.T, out string) []string {
+	t.Helper()
+	if !strings.HasSuffix(out, "\r\n") {
+		t.Fatal("el calendario no termina en CRLF")
+	}
+	for _, fisica := range strings.Split(strings.TrimSuffix(out, "\r\n"), "\r\n") {
+		if len(fisica) > largoLinea {
+			t.Errorf("línea de %d octetos: %q", len(fisica), fisica)
+		}
+		if !utf8.ValidString(fisica) {
+			t.Errorf("línea cortada en medio de un carácter: %q", fisica)
+		}
+		if strings.Contains(fisica, "\n") {
+			t.Errorf("salto de línea sin CR: %q", fisica)
+		}
+	}
+	return strings.Split(strings.ReplaceAll(strings.TrimSuffix(out, "\r\n"), "\r\n ", ""), "\r\n")
+}
+
+// eventos devuelve las propiedades de cada VEVENT, sin BEGIN/END.
+func eventos(lineas []string) [][]string {
+	var evs [][]string
+	var actual []string
+	for _, l := range lineas {
+		switch l {
+		case "BEGIN:VEVENT":
+			actual = []string{}
+		case "END:VEVENT":
+			evs = append(evs, actual)
+			actual = nil
+		default:
+			if actual != nil {
+				actual = append(actual, l)
+			}
+		}
+	}
+	return evs
+}
+
+func TestWrite(t *testing.T) {
+	santiago, err := time.LoadLocation("America/Santiago")
+	if err != nil {
+		t.Fatal(err)
+	}
+	inicio := time.Date(2026, 3, 9, 8, 30, 0, 0, santiago)
+	clase := models.ClaseCalendario{
+		SeccionID: 50, ModuloID: 7, Codigo: "CIT1000", Nombre: "Programación",
+		Ubicacion: "Sala 101, Edificio A", Inicio: inicio, Fin: inicio.Add(90 * time.Minute),
+	}
+	cancelada := clase
+	cancelada.ModuloID, cancelada.Cancelada, cancelada.Motivo = 8, true, "Paro; sin transporte"
+	cancelada.Ubicacion = ""
+	feriado := clase
+	feriado.ModuloID, feriado.Feriado = 9, "Viernes Santo"
+
+	var buf bytes.Buffer
+	ahora := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	if err := Write(&buf, "Horario, Ana", "America/Santiago", []models.ClaseCalendario{clase, cancelada, feriado}, ahora); err != nil {
+		t.Fatal(err)
+	}
+	lineas := desdoblar(t, buf.String())
+
+	if lineas[0] != "BEGIN:VCALENDAR" || lineas[len(lineas)-1] != "END:VCALENDAR" {
+		t.Errorf("calendario sin BEGIN/END: %q … %q", lineas[0], lineas[len(lineas)-1])
+	}
+	for _, want := range []string{"VERSION:2.0", `X-WR-CALNAME:Horario\, Ana`, "X-WR-TIMEZONE:America/Santiago"} {
+		if !slices.Contains(lineas, want) {
+			t.Errorf("falta %q", want)
+		}
+	}
+
+	evs := eventos(lineas)
+	want := [][]string{
+		{
+			"UID:clase-50-7@mysqr",
+			"DTSTAMP:20260301T120000Z",
+			// 08:30 en Santiago en marzo (UTC-3).
+			"DTSTART:20260309T113000Z",
+			"DTEND:20260309T130000Z",
+			"SUMMARY:Programación (CIT1000)",
+			`LOCATION:Sala 101\, Edificio A`,
+			"DESCRIPTION:Sección 50",
+			"STATUS:CONFIRMED",
+			"TRANSP:OPAQUE",
+		},
+		{
+			"UID:clase-50-8@mysqr",
+			"DTSTAMP:20260301T120000Z",
+			"DTSTART:20260309T113000Z",
+			"DTEND:20260309T130000Z",
+			"SUMMARY:Cancelada: Programación (CIT1000)",
+			`DESCRIPTION:Sección 50\nClase cancelada: Paro\; sin transporte`,
+			"STATUS:CANCELLED",
+			"TRANSP:OPAQUE",
+		},
+		{
+			"UID:clase-50-9@mysqr",
+			"DTSTAMP:20260301T120000Z",
+			"DTSTART:20260309T113000Z",
+			"DTEND:20260309T130000Z",
+			"SUMMARY:Sin clases: Programación (CIT1000)",
+			`LOCATION:Sala 101\, Edificio A`,
+			`DESCRIPTION:Sección 50\nFeriado: Viernes Santo`,
+			"STATUS:CANCELLED",
+			"TRANSP:OPAQUE",
+		},
+	}
+	if len(evs) != len(want) {
+		t.Fatalf("%d eventos, se esperaban %d", len(evs), len(want))
+	}
+	for i := range want {
+		if !slices.Equal(evs[i], want[i]) {
+			t.Errorf("evento %d:\n%q\nse esperaba\n%q", i, evs[i], want[i])
+		}
+	}
+}
+
+func TestWriteVacio(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, "Horario", "UTC", nil, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	lineas := desdoblar(t, buf.String())
+	if len(eventos(lineas)) != 0 || lineas[len(lineas)-1] != "END:VCALENDAR" {
+		t.Errorf("calendario vacío = %q", lineas)
+	}
+}
+
+func TestProp(t *testing.T) {
+	tests := []struct {
+		name  string
+		valor string
+	}{
+		{"corta", "Programación"},
+		{"justo 75", strings.Repeat("a", largoLinea-len("SUMMARY:"))},
+		{"76", strings.Repeat("a", largoLinea-len("SUMMARY:")+1)},
+		{"larga ascii", strings.Repeat("abcdefghij", 30)},
+		{"ñ en el corte", strings.Repeat("ñ", 100)},
+		{"emoji", strings.Repeat("a", 66) + strings.Repeat("📚", 40)},
+		{"vacía", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			bw := bufio.NewWriter(&buf)
+			l := &lineas{w: bw}
+			l.prop("SUMMARY", tt.valor)
+			if l.err != nil {
+				t.Fatal(l.err)
+			}
+			bw.Flush()
+
+			got := desdoblar(t, buf.String())
+			if len(got) != 1 || got[0] != "SUMMARY:"+tt.valor {
+				t.Errorf("desdoblada = %q", got)
+			}
+			dobleces := strings.Count(buf.String(), "\r\n ")
+			if largo := len("SUMMARY:" + tt.valor); largo <= largoLinea && dobleces != 0 {
+				t.Errorf("se dobló una línea de %d octetos", largo)
+			} else if largo > largoLinea && dobleces == 0 {
+				t.Errorf("no se dobló una línea de %d octetos", largo)
+			}
+		})
+	}
+}
+
+func TestTexto(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"Sala 101", "Sala 101"},
+		{"a,b;c", `a\,b\;c`},
+		{`C:\ruta`, `C:\\ruta`},
+		{"línea 1\nlínea 2", `línea 1\nlínea 2`},
+		{"línea 1\r\nlínea 2", `línea 1\nlínea 2`},
+		{`\n literal`, `\\n literal`},
+		{"dos puntos: sí", "dos puntos: sí"},
+	}
+	for _, tt := range tests {
+		if got := texto(tt.in); got != tt.want {
+			t.Errorf("texto(%q) = %q, se esperaba %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,341 @@
+// Package migrate aplica las migraciones de esquema de Back/migrations.
+//
+// Cada migración es un par de archivos NNN_nombre.up.sql y
+// NNN_nombre.down.sql; la versión es el número NNN. Las aplicadas se
+// registran en la tabla schema_migrations. Cada migración corre en su propia
+// transacción y todo el proceso toma un advisory lock de Postgres, así dos
+// servicios que arrancan a la vez no aplican la misma migración dos veces.
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// lockKey identifica el advisory lock de las migraciones. Es un número
+// arbitrario, solo tiene que ser el mismo en todos los procesos.
+const lockKey int64 = 4_702_214_337
+
+var fileName = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// Migration es una versión del esquema con sus scripts de subida y bajada.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status es el estado de una migración en la base.
+type Status struct {
+	Version   int
+	Name      string
+	AppliedAt *time.Time
+}
+
+// Load lee las migraciones de fsys (archivos en la raíz), ordenadas por
+// versión. Falla si una versión no tiene su .up.sql o su .down.sql, o si dos
+// archivos usan la misma versión con nombres distintos.
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		m := fileName.FindStringSubmatch(entry.Name())
+		if m == nil {
+			continue
+		}
+		version, _ := strconv.Atoi(m[1])
+		content, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = mig
+		}
+		if mig.Name != m[2] {
+			return nil, fmt.Errorf("migrate: la versión %d aparece como %q y %q", version, mig.Name, m[2])
+		}
+		if m[3] == "up" {
+			mig.Up = string(content)
+		} else {
+			mig.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		if mig.Up == "" || mig.Down == "" {
+			return nil, fmt.Errorf("migrate: a la versión %d (%s) le falta el .up.sql o el .down.sql", mig.Version, mig.Name)
+		}
+		migrations = append(migrations, *mig)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Runner aplica y revierte migraciones sobre una base.
+type Runner struct {
+	db         *sql.DB
+	migrations []Migration
+	logf       func(format string, args ...interface{})
+	settings   map[string]string
+}
+
+func NewRunner(db *sql.DB, migrations []Migration, logf func(format string, args ...interface{})) *Runner {
+	if logf == nil {
+		logf = func(string, ...interface{}) {}
+	}
+	return &Runner{db: db, migrations: migrations, logf: logf, settings: map[string]string{}}
+}
+
+// Set define un parámetro de Postgres (por ejemplo mysqr.institution_tz)
+// que cada migración puede leer con current_setting. Vale solo dentro de la
+// transacción de la migración, así los scripts no dependen de valores
+// escritos a mano que podrían no coincidir con la configuración del
+// backend.
+func (r *Runner) Set(name, value string) {
+	r.settings[name] = value
+}
+
+// begin abre la transacción de una migración con los parámetros de Set.
+func (r *Runner) begin(ctx context.Context, conn *sql.Conn) (*sql.Tx, error) {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range r.settings {
+		if _, err := tx.ExecContext(ctx, `SELECT set_config($1, $2, true)`, name, value); err != nil {
+			tx.Rollback()
+			return nil, fmt.Errorf("migrate: no se pudo definir %s: %w", name, err)
+		}
+	}
+	return tx, nil
+}
+
+// withLock corre fn con el advisory lock tomado, sobre una conexión fija
+// (el lock es de sesión) y con schema_migrations ya creada.
+func (r *Runner) withLock(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := r.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, lockKey); err != nil {
+		return fmt.Errorf("migrate: no se pudo tomar el lock: %w", err)
+	}
+	defer conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, lockKey)
+
+	if _, err := conn.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			Version int PRIMARY KEY,
+			Nombre varchar NOT NULL,
+			FechaAplicacion timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)`); err != nil {
+		return err
+	}
+	return fn(conn)
+}
+
+func applied(ctx context.Context, conn *sql.Conn) (map[int]time.Time, error) {
+	rows, err := conn.QueryContext(ctx, `SELECT Version, FechaAplicacion FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	versions := map[int]time.Time{}
+	for rows.Next() {
+		var version int
+		var at time.Time
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, err
+		}
+		versions[version] = at
+	}
+	return versions, rows.Err()
+}
+
+// run ejecuta script sentencia por sentencia dentro de tx, para que un
+// error diga cuál falló.
+func run(ctx context.Context, tx *sql.Tx, script string) error {
+	for _, stmt := range SplitStatements(script) {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("%w\nen la sentencia:\n%s", err, stmt)
+		}
+	}
+	return nil
+}
+
+// Up aplica, en orden, todas las migraciones pendientes. Devuelve cuántas
+// aplicó.
+func (r *Runner) Up(ctx context.Context) (int, error) {
+	count := 0
+	err := r.withLock(ctx, func(conn *sql.Conn) error {
+		done, err := applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, mig := range r.migrations {
+			if _, ok := done[mig.Version]; ok {
+				continue
+			}
+			if err := r.apply(ctx, conn, mig); err != nil {
+				return err
+			}
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+func (r *Runner) apply(ctx context.Context, conn *sql.Conn, mig Migration) error {
+	r.logf("migrate: aplicando %03d_%s", mig.Version, mig.Name)
+	tx, err := r.begin(ctx, conn)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := run(ctx, tx, mig.Up); err != nil {
+		return fmt.Errorf("migrate: %03d_%s: %w", mig.Version, mig.Name, err)
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (Version, Nombre) VALUES ($1, $2)`,
+		mig.Version, mig.Name); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Down revierte las últimas steps migraciones aplicadas, de la más nueva a
+// la más vieja. Devuelve cuántas revirtió.
+func (r *Runner) Down(ctx context.Context, steps int) (int, error) {
+	count := 0
+	err := r.withLock(ctx, func(conn *sql.Conn) error {
+		done, err := applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for i := len(r.migrations) - 1; i >= 0 && count < steps; i-- {
+			mig := r.migrations[i]
+			if _, ok := done[mig.Version]; !ok {
+				continue
+			}
+			if err := r.revert(ctx, conn, mig); err != nil {
+				return err
+			}
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+func (r *Runner) revert(ctx context.Context, conn *sql.Conn, mig Migration) error {
+	r.logf("migrate: revirtiendo %03d_%s", mig.Version, mig.Name)
+	tx, err := r.begin(ctx, conn)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := run(ctx, tx, mig.Down); err != nil {
+		return fmt.Errorf("migrate: %03d_%s: %w", mig.Version, mig.Name, err)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM schema_migrations WHERE Version = $1`, mig.Version); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Force marca como aplicadas todas las migraciones hasta version, sin
+// ejecutarlas. Sirve para adoptar una base creada antes de que existiera
+// schema_migrations.
+func (r *Runner) Force(ctx context.Context, version int) error {
+	return r.withLock(ctx, func(conn *sql.Conn) error {
+		for _, mig := range r.migrations {
+			if mig.Version > version {
+				break
+			}
+			if _, err := conn.ExecContext(ctx, `
+				INSERT INTO schema_migrations (Version, Nombre) VALUES ($1, $2)
+				ON CONFLICT (Version) DO NOTHING`, mig.Version, mig.Name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Status lista todas las migraciones conocidas y cuándo se aplicó cada una
+// (nil si está pendiente).
+func (r *Runner) Status(ctx context.Context) ([]Status, error) {
+	var statuses []Status
+	err := r.withLock(ctx, func(conn *sql.Conn) error {
+		done, err := applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, mig := range r.migrations {
+			st := Status{Version: mig.Version, Name: mig.Name}
+			if at, ok := done[mig.Version]; ok {
+				st.AppliedAt = &at
+			}
+			statuses = append(statuses, st)
+		}
+		return nil
+	})
+	return statuses, err
+}
+
+// Seed ejecuta, en orden alfabético y en una sola transacción, los .sql de
+// fsys. Los datos de ejemplo no son parte del esquema: no se registran en
+// schema_migrations y cada archivo tiene que poder correr más de una vez.
+func (r *Runner) Seed(ctx context.Context, fsys fs.FS) error {
+	files, err := fs.Glob(fsys, "*.sql")
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return errors.New("migrate: no hay archivos de seed")
+	}
+
+	return r.withLock(ctx, func(conn *sql.Conn) error {
+		tx, err := conn.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		for _, file := range files {
+			r.logf("migrate: seed %s", path.Base(file))
+			content, err := fs.ReadFile(fsys, file)
+			if err != nil {
+				return err
+			}
+			if err := run(ctx, tx, string(content)); err != nil {
+				return fmt.Errorf("migrate: seed %s: %w", file, err)
+			}
+		}
+		return tx.Commit()
+	})
+}
@@ -0,0 +1,144 @@
+package migrate
+
+import "strings"
+
+// SplitStatements parte un script SQL en sentencias por ";", sin cortar
+// dentro de strings ('...', y E'...' con escapes de backslash como \'),
+// identificadores entre comillas ("..."), comentarios (-- y /* */, que en
+// Postgres se anidan) ni cuerpos con dollar quoting ($$...$$, $tag$...$tag$)
+// como los de las funciones PL/pgSQL. Las sentencias vacías o que solo
+// tienen comentarios se omiten.
+func SplitStatements(script string) []string {
+	var statements []string
+	start := 0
+	hasCode := false
+
+	flush := func(end int) {
+		if hasCode {
+			statements = append(statements, strings.TrimSpace(script[start:end]))
+		}
+		start = end + 1
+		hasCode = false
+	}
+
+	for i := 0; i < len(script); i++ {
+		ch := script[i]
+		switch {
+		case ch == '-' && strings.HasPrefix(script[i:], "--"):
+			if end := strings.IndexByte(script[i:], '\n'); end >= 0 {
+				i += end
+			} else {
+				i = len(script)
+			}
+
+		case ch == '/' && strings.HasPrefix(script[i:], "/*"):
+			depth := 0
+			for ; i < len(script); i++ {
+				if strings.HasPrefix(script[i:], "/*") {
+					depth++
+					i++
+				} else if strings.HasPrefix(script[i:], "*/") {
+					depth--
+					i++
+					if depth == 0 {
+						break
+					}
+				}
+			}
+
+		case ch == '\'' && isEscapeStringPrefix(script, i):
+			hasCode = true
+			i = escapeStringEnd(script, i)
+
+		case ch == '\'' || ch == '"':
+			hasCode = true
+			// Una comilla duplicada ('' o "") es una comilla escapada: el
+			// cierre y la reapertura se leen como dos strings seguidos.
+			if end := strings.IndexByte(script[i+1:], ch); end >= 0 {
+				i += end + 1
+			} else {
+				i = len(script)
+			}
+
+		case ch == '$':
+			hasCode = true
+			// Dentro de un identificador (a$b) el "$" es parte del nombre.
+			if i > 0 && isIdentChar(script[i-1]) {
+				break
+			}
+			if tag, ok := dollarTag(script[i:]); ok {
+				if end := strings.Index(script[i+len(tag):], tag); end >= 0 {
+					i += len(tag) + end + len(tag) - 1
+				} else {
+					i = len(script)
+				}
+			}
+
+		case ch == ';':
+			flush(i)
+
+		case !isSpace(ch):
+			hasCode = true
+		}
+	}
+	if start < len(script) {
+		flush(len(script))
+	}
+	return statements
+}
+
+// isEscapeStringPrefix indica si la comilla en script[i] abre un string con
+// escapes de backslash: E'...' o e'...', con la E como palabra suelta (no
+// el final de un identificador como "name'").
+func isEscapeStringPrefix(script string, i int) bool {
+	if i == 0 || (script[i-1] != 'E' && script[i-1] != 'e') {
+		return false
+	}
+	return i == 1 || !isIdentChar(script[i-2])
+}
+
+// escapeStringEnd devuelve la posición de la comilla que cierra el E'...'
+// que abre en script[i], o el final del script si no cierra. Adentro, "\"
+// escapa el carácter siguiente y dos comillas seguidas siguen siendo una
+// comilla escapada.
+func escapeStringEnd(script string, i int) int {
+	for j := i + 1; j < len(script); j++ {
+		switch script[j] {
+		case '\\':
+			j++
+		case '\'':
+			if j+1 < len(script) && script[j+1] == '\'' {
+				j++
+				continue
+			}
+			return j
+		}
+	}
+	return len(script)
+}
+
+func isIdentChar(c byte) bool {
+	return c == '_' || c == '$' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c >= 0x80
+}
+
+// dollarTag reconoce el delimitador de apertura de un dollar quote al
+// comienzo de s: "$$" o "$tag$", con tag de letras, dígitos y "_" que no
+// empieza con dígito (así "$1" de un parámetro no se confunde).
+func dollarTag(s string) (string, bool) {
+	for j := 1; j < len(s); j++ {
+		c := s[j]
+		switch {
+		case c == '$':
+			return s[:j+1], true
+		case c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80:
+		case c >= '0' && c <= '9' && j > 1:
+		default:
+			return "", false
+		}
+	}
+	return "", false
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f' || c == '\v'
+}
@@ -0,0 +1,97 @@
+package migrate
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"mysqr/migrations"
+)
+
+func TestSplitStatements(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		want   []string
+	}{
+		{"simple", "SELECT 1; SELECT 2;", []string{"SELECT 1", "SELECT 2"}},
+		{"sin ; final", "SELECT 1;\nSELECT 2\n", []string{"SELECT 1", "SELECT 2"}},
+		{"vacías", ";; SELECT 1;;", []string{"SELECT 1"}},
+		{"solo comentarios", "-- nada;\n/* tampoco; */;\n", nil},
+		{"comilla duplicada", "SELECT 'it''s; x'; SELECT 2", []string{"SELECT 'it''s; x'", "SELECT 2"}},
+		{"E-string", `SELECT E'it\'s; x'; SELECT 2`, []string{`SELECT E'it\'s; x'`, "SELECT 2"}},
+		{"e-string minúscula", `SELECT e'\'; x'; SELECT 2`, []string{`SELECT e'\'; x'`, "SELECT 2"}},
+		{"E-string con backslash final", `SELECT E'a\\'; SELECT 2`, []string{`SELECT E'a\\'`, "SELECT 2"}},
+		{"E-string con comilla duplicada", `SELECT E'a''b\'; c'; SELECT 2`, []string{`SELECT E'a''b\'; c'`, "SELECT 2"}},
+		{"backslash en string normal", `SELECT 'a\'; SELECT 2`, []string{`SELECT 'a\'`, "SELECT 2"}},
+		{"identificador terminado en e", `SELECT some'a\'; SELECT 2`, []string{`SELECT some'a\'`, "SELECT 2"}},
+		{"identificador entre comillas", `SELECT "a;b" FROM t; SELECT 2`, []string{`SELECT "a;b" FROM t`, "SELECT 2"}},
+		{"comentario de línea", "SELECT 1 -- a;b\n; SELECT 2", []string{"SELECT 1 -- a;b", "SELECT 2"}},
+		{"comentarios anidados", "SELECT 1 /* a /* b; */ c; */; SELECT 2", []string{"SELECT 1 /* a /* b; */ c; */", "SELECT 2"}},
+		{
+			"dollar quoting",
+			"CREATE FUNCTION f() RETURNS int AS $$ BEGIN RETURN 1; END; $$ LANGUAGE plpgsql; SELECT 2",
+			[]string{"CREATE FUNCTION f() RETURNS int AS $$ BEGIN RETURN 1; END; $$ LANGUAGE plpgsql", "SELECT 2"},
+		},
+		{
+			"$tag$ con $$ adentro",
+			"DO $body$ BEGIN PERFORM $$x;y$$; END $body$; SELECT 2",
+			[]string{"DO $body$ BEGIN PERFORM $$x;y$$; END $body$", "SELECT 2"},
+		},
+		{"parámetro $1", "PREPARE p AS SELECT $1; EXECUTE p(1)", []string{"PREPARE p AS SELECT $1", "EXECUTE p(1)"}},
+		{"$ dentro de un identificador", "SELECT a$b$c; SELECT 2", []string{"SELECT a$b$c", "SELECT 2"}},
+		{"string sin cerrar", "SELECT 'abc; SELECT 2", []string{"SELECT 'abc; SELECT 2"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SplitStatements(tt.script); !slices.Equal(got, tt.want) {
+				t.Errorf("SplitStatements(%q)\n got: %q\nwant: %q", tt.script, got, tt.want)
+			}
+		})
+	}
+}
+
+// Las migraciones reales se parten en sentencias completas: cada una empieza
+// con un comando conocido (un corte a mitad de string o de cuerpo dejaría un
+// fragmento que no) y no se vuelve a partir.
+func TestSplitStatementsMigrations(t *testing.T) {
+	migs, err := Load(migrations.Schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	commands := []string{"ALTER", "COMMENT", "CREATE", "DELETE", "DO", "DROP", "INSERT", "SELECT", "UPDATE"}
+
+	for _, mig := range migs {
+		for direction, script := range map[string]string{"up": mig.Up, "down": mig.Down} {
+			statements := SplitStatements(script)
+			if len(statements) == 0 {
+				t.Errorf("%03d %s: sin sentencias", mig.Version, direction)
+			}
+			for _, stmt := range statements {
+				if cmd := firstWord(stmt); !slices.Contains(commands, strings.ToUpper(cmd)) {
+					t.Errorf("%03d %s: sentencia que empieza con %q:\n%s", mig.Version, direction, cmd, stmt)
+				}
+				if strings.Count(stmt, "$$")%2 != 0 {
+					t.Errorf("%03d %s: $$ sin cerrar:\n%s", mig.Version, direction, stmt)
+				}
+				if again := SplitStatements(stmt); len(again) != 1 {
+					t.Errorf("%03d %s: la sentencia se vuelve a partir en %d:\n%s", mig.Version, direction, len(again), stmt)
+				}
+			}
+		}
+	}
+}
+
+// firstWord es la primera palabra de la sentencia fuera de los comentarios
+// de línea con que empiece.
+func firstWord(stmt string) string {
+	for _, line := range strings.Split(stmt, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "--") {
+			continue
+		}
+		return strings.Fields(line)[0]
+	}
+	return ""
+}
@@ -1,11 +1,16 @@
 package models
 
+import "time"
+
 // SeccionAsignatura es una sección junto al nombre y código de su asignatura.
 type SeccionAsignatura struct {
 	SeccionID    int    `json:"seccion_id"`
 	AsignaturaID int    `json:"asignatura_id"`
 	Nombre       string `json:"nombre"`
 	Codigo       string `json:"codigo"`
+	// FechaBaja viene en las secciones de un alumno que ya no está inscrito
+	// en ella: su asistencia hasta la baja se sigue pudiendo consultar.
+	FechaBaja *time.Time `json:"fecha_baja,omitempty"`
 }
 
 // ModuloSeccion representa el módulo horario actual y la sección que se dicta en él.
@@ -13,3 +18,337 @@ type ModuloSeccion struct {
 	ModuloID  int `json:"modulo_id"`
 	SeccionID int `json:"seccion_id"`
 }
+
+// Fases de una clase respecto de la hora actual: en curso, por empezar
+// (dentro de la tolerancia antes de HoraInicio) o recién terminada (dentro
+// de la tolerancia después de HoraFin).
+const (
+	FaseEnCurso   = "in_progress"
+	FaseProxima   = "upcoming"
+	FaseTerminada = "ended"
+)
+
+// ClaseActual es una clase programada del profesor cuyo módulo puede ser el
+// actual, con lo necesario para que elija entre varias.
+type ClaseActual struct {
+	ModuloID   int    `json:"module_id"`
+	SeccionID  int    `json:"section_id"`
+	Codigo     string `json:"course_code"`
+	Nombre     string `json:"course_name"`
+	Fecha      string `json:"date"`
+	HoraInicio string `json:"start_time"`
+	HoraFin    string `json:"end_time"`
+	Fase       string `json:"phase"`
+}
+
+// TotalesAsistencia resume la asistencia de una sección en un módulo.
+type TotalesAsistencia struct {
+	SeccionID int `json:"section_id"`
+	ModuloID  int `json:"module_id"`
+	Presentes int `json:"present"`
+	Inscritos int `json:"enrolled"`
+}
+
+// Estados de una SesionClase.
+const (
+	SesionAbierta = "open"
+	SesionPausada = "paused"
+	SesionCerrada = "closed"
+)
+
+// SesionClase es la toma de asistencia de una sección en un módulo: se abre
+// al emitir el primer QR y el profesor la puede extender, pausar o cerrar.
+// Vencida indica que ya pasó CierreProgramado (se calcula en la base).
+type SesionClase struct {
+	ID               int64      `json:"id"`
+	SeccionID        int        `json:"section_id"`
+	ModuloID         int        `json:"module_id"`
+	ProfesorID       int        `json:"professor_id"`
+	Estado           string     `json:"status"`
+	FechaApertura    time.Time  `json:"opened_at"`
+	CierreProgramado time.Time  `json:"closes_at"`
+	FechaCierre      *time.Time `json:"closed_at,omitempty"`
+	Vencida          bool       `json:"expired"`
+}
+
+// Estados de la partición de una sección en Asistencia.
+const (
+	ParticionOK        = "ok"
+	ParticionFaltante  = "missing"
+	ParticionArchivada = "archived"
+)
+
+// EstadoParticion es la partición de una sección en una tabla particionada
+// por SeccionID (hoy solo Asistencia). FilasDefault son las filas de la sección que cayeron en la
+// partición DEFAULT por no tener la suya.
+type EstadoParticion struct {
+	Tabla        string `json:"table"`
+	SeccionID    int    `json:"section_id"`
+	Estado       string `json:"status"`
+	FilasDefault int    `json:"default_rows"`
+}
+
+// Estados del reporte de asistencia tal como los dibuja la app.
+const (
+	EstadoPresente = "🟢"
+	EstadoAusente  = "🔴"
+)
+
+// AsistenciaModulo es una celda del reporte de una sección: el estado del
+// alumno en la clase de una fecha.
+type AsistenciaModulo struct {
+	Estado   string `json:"estado"`
+	AlumnoID int    `json:"alumno_id"`
+	ModuloID int    `json:"modulo_id"`
+}
+
+// ReporteAlumno es una fila del reporte de una sección: un alumno y su
+// asistencia indexada por fecha ("MM-DD"). FechaBaja viene si el alumno ya
+// no está inscrito; su asistencia llega hasta la baja.
+type ReporteAlumno struct {
+	EstudianteID int                         `json:"estudiante_id"`
+	Estudiante   string                      `json:"estudiante"`
+	FechaBaja    *time.Time                  `json:"fecha_baja,omitempty"`
+	Asistencia   map[string]AsistenciaModulo `json:"asistencia"`
+}
+
+// ReporteEstudiante es el reporte de un alumno en una sección: solo el
+// estado por fecha ("MM-DD").
+type ReporteEstudiante struct {
+	Estudiante string            `json:"estudiante"`
+	Asistencia map[string]string `json:"asistencia"`
+}
+
+// VersionReporte es la versión del reporte estructurado (/api/db/v2).
+const VersionReporte = 2
+
+// Estado de un alumno en una clase (Asistencia.Estado). Sin fila en
+// Asistencia, el alumno está ausente.
+const (
+	AsistenciaPresente    = "present"
+	AsistenciaTarde       = "late"
+	AsistenciaJustificada = "justified"
+	AsistenciaExcusada    = "excused"
+	AsistenciaAusente     = "absent"
+)
+
+// EstadoAsistenciaValido indica si estado es uno de los de arriba.
+func EstadoAsistenciaValido(estado string) bool {
+	switch estado {
+	case AsistenciaPresente, AsistenciaTarde, AsistenciaJustificada, AsistenciaExcusada, AsistenciaAusente:
+		return true
+	}
+	return false
+}
+
+// PoliticaAsistencia define cómo cuenta cada estado en el porcentaje de
+// asistencia de una sección. Los créditos son el porcentaje de la clase
+// que vale un estado (present vale 100 y absent 0, siempre); una clase
+// excused no entra al total salvo que ExcusadaCuenta sea true, y entonces
+// vale 100.
+type PoliticaAsistencia struct {
+	SeccionID          int  `json:"section_id"`
+	MinutosGracia      int  `json:"grace_minutes"`
+	CreditoTarde       int  `json:"late_credit"`
+	CreditoJustificada int  `json:"justified_credit"`
+	ExcusadaCuenta     bool `json:"excused_counts"`
+}
+
+// PoliticaPorDefecto es la política de una sección que no definió la suya
+// (los mismos defaults que la tabla PoliticasAsistencia).
+func PoliticaPorDefecto(seccionID int) PoliticaAsistencia {
+	return PoliticaAsistencia{
+		SeccionID:          seccionID,
+		MinutosGracia:      10,
+		CreditoTarde:       100,
+		CreditoJustificada: 100,
+	}
+}
+
+// ResumenAsistencia son los totales de un alumno en una sección. Solo
+// cuentan las clases que ya empezaron (Dictadas); Porcentaje aplica la
+// política de la sección.
+type ResumenAsistencia struct {
+	Dictadas   int            `json:"held"`
+	PorEstado  map[string]int `json:"by_status"`
+	Porcentaje float64        `json:"percentage"`
+}
+
+// Origen de un registro de asistencia: escaneo del QR o alta manual del
+// profesor.
+const (
+	OrigenQR     = "qr"
+	OrigenManual = "manual"
+)
+
+// ModuloClase es una clase programada de una sección: módulo, fecha
+// (AAAA-MM-DD) y bloque horario (HH:MM).
+type ModuloClase struct {
+	ModuloID   int    `json:"module_id"`
+	Fecha      string `json:"date"`
+	HoraInicio string `json:"start_time"`
+	HoraFin    string `json:"end_time"`
+}
+
+// RegistroAsistencia es el estado de un alumno en una clase programada.
+// Dictada indica que la clase ya empezó (antes de eso, "absent" solo
+// significa que todavía no hay registro). Origen y FechaRegistro solo vienen si hay fila en Asistencia, y Motivo si
+// el profesor cambió el estado a mano.
+type RegistroAsistencia struct {
+	ModuloID      int        `json:"module_id"`
+	Fecha         string     `json:"date"`
+	HoraInicio    string     `json:"start_time"`
+	HoraFin       string     `json:"end_time"`
+	Dictada       bool       `json:"held"`
+	Estado        string     `json:"status"`
+	Origen        string     `json:"source,omitempty"`
+	FechaRegistro *time.Time `json:"registered_at,omitempty"`
+	Motivo        string     `json:"reason,omitempty"`
+}
+
+// RegistrosAlumno son los registros de un alumno en una sección, en orden
+// de fecha y hora, con su resumen. Un alumno dado de baja trae FechaBaja y
+// solo las clases hasta la baja.
+type RegistrosAlumno struct {
+	AlumnoID  int                  `json:"student_id"`
+	Nombre    string               `json:"name"`
+	FechaBaja *time.Time           `json:"dropped_at,omitempty"`
+	Registros []RegistroAsistencia `json:"records"`
+	Resumen   ResumenAsistencia    `json:"summary"`
+}
+
+// ReporteSeccionV2 es el reporte estructurado de una sección.
+type ReporteSeccionV2 struct {
+	Version   int                `json:"version"`
+	SeccionID int                `json:"section_id"`
+	Politica  PoliticaAsistencia `json:"policy"`
+	Alumnos   []RegistrosAlumno  `json:"students"`
+}
+
+// ReporteEstudianteV2 es el reporte estructurado de un alumno en una sección.
+type ReporteEstudianteV2 struct {
+	Version   int                `json:"version"`
+	SeccionID int                `json:"section_id"`
+	Politica  PoliticaAsistencia `json:"policy"`
+	RegistrosAlumno
+}
+
+// AlumnoImportado es un alumno leído de un archivo de Canvas. Rut 0 indica
+// que el archivo no lo traía.
+type AlumnoImportado struct {
+	ID             int    `json:"id"`
+	Rut            int    `json:"rut,omitempty"`
+	Nombre         string `json:"first_name"`
+	NombreCompleto string `json:"full_name"`
+	Email          string `json:"email"`
+}
+
+// FilaOmitida es una fila del archivo que no se importó, con su número de
+// línea y el motivo.
+type FilaOmitida struct {
+	Linea  int    `json:"line"`
+	Motivo string `json:"reason"`
+}
+
+// CursoImportado son los datos del curso que trae una importación: código
+// y nombre de la asignatura, el identificador de la sección en Canvas, y
+// los días (Lunes..Viernes) y bloque (hora de término, HH:MM) en que tiene
+// clases dentro del período académico PeriodoID (0: sin período, todos
+// los módulos).
+type CursoImportado struct {
+	Codigo         string   `json:"code"`
+	Nombre         string   `json:"name"`
+	SeccionExterna string   `json:"external_section_id"`
+	PeriodoID      int      `json:"term_id,omitempty"`
+	Dias           []string `json:"days"`
+	Bloque         string   `json:"block"`
+}
+
+// Datos de un alumno que una importación puede cambiar.
+const (
+	CampoNombre = "name"
+	CampoEmail  = "email"
+	CampoRut    = "rut"
+)
+
+// CambioAlumno es un alumno cuyos datos cambian con la importación: trae
+// los datos nuevos y qué campos cambiaron.
+type CambioAlumno struct {
+	Campos []string `json:"fields"`
+	AlumnoImportado
+}
+
+// ResumenImportacion es la diferencia entre el roster importado y la
+// sección: alumnos que se inscriben (nuevos o que vuelven), los que se dan
+// de baja, los que cambian de datos y cuántos quedan igual. Con Confirmada
+// en false es solo la vista previa: no se escribió nada (y SeccionID es 0
+// si la sección todavía no existe).
+type ResumenImportacion struct {
+	Confirmada         bool              `json:"committed"`
+	Curso              CursoImportado    `json:"course"`
+	SeccionID          int               `json:"section_id,omitempty"`
+	SeccionNueva       bool              `json:"new_section"`
+	AsignaturaNueva    bool              `json:"new_course"`
+	ModulosProgramados int               `json:"scheduled_modules"`
+	Agregados          []AlumnoImportado `json:"added"`
+	Eliminados         []AlumnoImportado `json:"removed"`
+	Modificados        []CambioAlumno    `json:"changed"`
+	SinCambios         int               `json:"unchanged"`
+	Omitidas           []FilaOmitida     `json:"skipped"`
+}
+
+// Periodo es un período académico (semestre, trimestre) con sus fechas de
+// inicio y fin (AAAA-MM-DD), ambas inclusive.
+type Periodo struct {
+	ID          int    `json:"id"`
+	Nombre      string `json:"name"`
+	FechaInicio string `json:"start_date"`
+	FechaFin    string `json:"end_date"`
+}
+
+// BloqueHorario es un bloque de la plantilla con que se generan los
+// módulos de cada día (HH:MM).
+type BloqueHorario struct {
+	HoraInicio string `json:"start_time"`
+	HoraFin    string `json:"end_time"`
+}
+
+// Feriado es un día o rango de días (receso) sin clases (AAAA-MM-DD, ambas
+// inclusive).
+type Feriado struct {
+	ID          int    `json:"id"`
+	FechaInicio string `json:"start_date"`
+	FechaFin    string `json:"end_date"`
+	Descripcion string `json:"description"`
+}
+
+// ResumenGeneracion es el resultado de generar los módulos de un período:
+// cuántos días hábiles tuvo y cuántos se saltaron por feriado, cuántos
+// módulos se crearon y cuántos ya existían. EnFeriado son módulos del
+// período que ya existían y quedaron en un feriado agregado después; no se
+// borran porque pueden tener clases programadas.
+type ResumenGeneracion struct {
+	PeriodoID   int `json:"term_id"`
+	DiasHabiles int `json:"weekdays"`
+	DiasFeriado int `json:"holidays_skipped"`
+	Bloques     int `json:"blocks"`
+	Creados     int `json:"created"`
+	Existentes  int `json:"existing"`
+	EnFeriado   int `json:"on_holidays"`
+}
+
+// ClaseCalendario es una clase programada tal como sale en el feed iCal:
+// curso, sala, inicio y fin como instantes, y si no se dicta (cancelada a
+// mano, con su motivo, o en un feriado, con su descripción).
+type ClaseCalendario struct {
+	SeccionID int       `json:"section_id"`
+	ModuloID  int       `json:"module_id"`
+	Codigo    string    `json:"course_code"`
+	Nombre    string    `json:"course_name"`
+	Ubicacion string    `json:"location"`
+	Inicio    time.Time `json:"start"`
+	Fin       time.Time `json:"end"`
+	Cancelada bool      `json:"cancelled"`
+	Motivo    string    `json:"reason,omitempty"`
+	Feriado   string    `json:"holiday,omitempty"`
+}
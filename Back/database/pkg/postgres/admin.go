@@ -0,0 +1,38 @@
+package postgres
+
+import (
+	"fmt"
+
+	passwd "mysqr/pkg/password"
+)
+
+// CreateAdmin crea una cuenta con rol admin (no ligada a profesor ni
+// alumno, ver migrations/002_admin_role.up.sql).
+func (s *DatabaseService) CreateAdmin(username, password string) error {
+	hash, err := passwd.Hash(password)
+	if err != nil {
+		return fmt.Errorf("error al hashear contraseña: %v", err)
+	}
+	_, err = s.db.Exec(`
+		INSERT INTO AUTH (username, password_hash, rol, AlumnoID, ProfesorID, Rut)
+		VALUES ($1, $2, 'admin', NULL, NULL, 0)`, username, hash)
+	if err != nil {
+		return fmt.Errorf("error al crear el admin %q: %v", username, err)
+	}
+	return nil
+}
+
+// SetAdminPassword cambia la contraseña de un admin. Devuelve false si no
+// existe un admin con ese username.
+func (s *DatabaseService) SetAdminPassword(username, password string) (bool, error) {
+	hash, err := passwd.Hash(password)
+	if err != nil {
+		return false, fmt.Errorf("error al hashear contraseña: %v", err)
+	}
+	res, err := s.db.Exec(`UPDATE AUTH SET password_hash = $2 WHERE username = $1 AND rol = 'admin'`, username, hash)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
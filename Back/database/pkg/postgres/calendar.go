@@ -0,0 +1,226 @@
+package postgres
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"mysqr/database/pkg/models"
+)
+
+// GetTerms devuelve los períodos académicos, del más reciente al más
+// antiguo.
+func (s *DatabaseService) GetTerms() ([]models.Periodo, error) {
+	rows, err := s.db.Query(`
+		SELECT ID, Nombre, to_char(FechaInicio, 'YYYY-MM-DD'), to_char(FechaFin, 'YYYY-MM-DD')
+		FROM Periodos
+		ORDER BY FechaInicio DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	periodos := []models.Periodo{}
+	for rows.Next() {
+		var p models.Periodo
+		if err := rows.Scan(&p.ID, &p.Nombre, &p.FechaInicio, &p.FechaFin); err != nil {
+			return nil, err
+		}
+		periodos = append(periodos, p)
+	}
+	return periodos, rows.Err()
+}
+
+// CreateTerm crea un período. Nombre repetido o fin antes del inicio los
+// rechaza la tabla.
+func (s *DatabaseService) CreateTerm(p models.Periodo) (models.Periodo, error) {
+	err := s.db.QueryRow(`
+		INSERT INTO Periodos (Nombre, FechaInicio, FechaFin)
+		VALUES ($1, $2, $3)
+		RETURNING ID`, p.Nombre, p.FechaInicio, p.FechaFin).Scan(&p.ID)
+	return p, err
+}
+
+// CurrentTermID devuelve el período en curso (según la fecha de la
+// institución) o, entre vacaciones, el próximo en empezar. Devuelve 0 si
+// no hay ninguno.
+func (s *DatabaseService) CurrentTermID() (int, error) {
+	var id int
+	err := s.db.QueryRow(`
+		SELECT ID FROM Periodos
+		WHERE FechaFin >= $1::date
+		ORDER BY FechaInicio <= $1::date DESC, FechaInicio
+		LIMIT 1`, s.clock.Now().Format(time.DateOnly)).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return id, err
+}
+
+// GetBlockTemplate devuelve la plantilla de bloques en orden.
+func (s *DatabaseService) GetBlockTemplate() ([]models.BloqueHorario, error) {
+	rows, err := s.db.Query(`
+		SELECT to_char(HoraInicio, 'HH24:MI'), to_char(HoraFin, 'HH24:MI')
+		FROM BloquesHorario
+		ORDER BY HoraInicio`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	bloques := []models.BloqueHorario{}
+	for rows.Next() {
+		var b models.BloqueHorario
+		if err := rows.Scan(&b.HoraInicio, &b.HoraFin); err != nil {
+			return nil, err
+		}
+		bloques = append(bloques, b)
+	}
+	return bloques, rows.Err()
+}
+
+// SetBlockTemplate reemplaza la plantilla de bloques. Solo afecta a los
+// módulos que se generen después.
+func (s *DatabaseService) SetBlockTemplate(bloques []models.BloqueHorario) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM BloquesHorario`); err != nil {
+		return err
+	}
+	for _, b := range bloques {
+		_, err := tx.Exec(`INSERT INTO BloquesHorario (HoraInicio, HoraFin) VALUES ($1, $2)`, b.HoraInicio, b.HoraFin)
+		if err != nil {
+			return fmt.Errorf("bloque %s-%s: %v", b.HoraInicio, b.HoraFin, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// GetHolidays devuelve los feriados y recesos en orden de fecha.
+func (s *DatabaseService) GetHolidays() ([]models.Feriado, error) {
+	rows, err := s.db.Query(`
+		SELECT ID, to_char(FechaInicio, 'YYYY-MM-DD'), to_char(FechaFin, 'YYYY-MM-DD'), Descripcion
+		FROM Feriados
+		ORDER BY FechaInicio`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	feriados := []models.Feriado{}
+	for rows.Next() {
+		var f models.Feriado
+		if err := rows.Scan(&f.ID, &f.FechaInicio, &f.FechaFin, &f.Descripcion); err != nil {
+			return nil, err
+		}
+		feriados = append(feriados, f)
+	}
+	return feriados, rows.Err()
+}
+
+// CreateHoliday agrega un feriado (o un receso si abarca varios días).
+func (s *DatabaseService) CreateHoliday(f models.Feriado) (models.Feriado, error) {
+	err := s.db.QueryRow(`
+		INSERT INTO Feriados (FechaInicio, FechaFin, Descripcion)
+		VALUES ($1, $2, $3)
+		RETURNING ID`, f.FechaInicio, f.FechaFin, f.Descripcion).Scan(&f.ID)
+	return f, err
+}
+
+// DeleteHoliday borra un feriado. Devuelve false si no existía.
+func (s *DatabaseService) DeleteHoliday(id int) (bool, error) {
+	res, err := s.db.Exec(`DELETE FROM Feriados WHERE ID = $1`, id)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// diasPeriodo son los días hábiles (lunes a viernes) del período $1, cada
+// uno marcado si cae en un feriado.
+const diasPeriodo = `
+	SELECT d::date AS fecha,
+		EXISTS (SELECT 1 FROM Feriados f WHERE d::date BETWEEN f.FechaInicio AND f.FechaFin) AS feriado
+	FROM Periodos p, generate_series(p.FechaInicio::timestamp, p.FechaFin::timestamp, interval '1 day') d
+	WHERE p.ID = $1 AND EXTRACT(ISODOW FROM d) <= 5`
+
+// GenerateModules crea los Modulos del período: uno por bloque de la
+// plantilla en cada día hábil que no sea feriado. Es idempotente: un
+// módulo que ya existe con la misma fecha y horas no se duplica (si no
+// tenía período, queda asociado a este). Devuelve nil si el período no
+// existe.
+func (s *DatabaseService) GenerateModules(periodoID int) (*models.ResumenGeneracion, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Dos generaciones a la vez (de este u otro período) podrían crear el
+	// mismo módulo dos veces: no hay índice único sobre Modulos porque los
+	// datos cargados a mano pueden tener repetidos.
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock(hashtext('generar_modulos'))`); err != nil {
+		return nil, err
+	}
+
+	var existe bool
+	if err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM Periodos WHERE ID = $1)`, periodoID).Scan(&existe); err != nil {
+		return nil, err
+	}
+	if !existe {
+		return nil, nil
+	}
+
+	resumen := &models.ResumenGeneracion{PeriodoID: periodoID}
+	err = tx.QueryRow(`
+		SELECT COUNT(*) FILTER (WHERE NOT feriado), COUNT(*) FILTER (WHERE feriado),
+			(SELECT COUNT(*) FROM BloquesHorario)
+		FROM (`+diasPeriodo+`) dias`, periodoID).Scan(&resumen.DiasHabiles, &resumen.DiasFeriado, &resumen.Bloques)
+	if err != nil {
+		return nil, fmt.Errorf("error al contar días del período: %v", err)
+	}
+
+	_, err = tx.Exec(`
+		UPDATE Modulos m SET PeriodoID = $1
+		FROM (`+diasPeriodo+`) dias, BloquesHorario b
+		WHERE m.PeriodoID IS NULL AND NOT dias.feriado
+			AND m.Fecha = dias.fecha AND m.HoraInicio = b.HoraInicio AND m.HoraFin = b.HoraFin`, periodoID)
+	if err != nil {
+		return nil, fmt.Errorf("error al asociar módulos existentes: %v", err)
+	}
+
+	res, err := tx.Exec(`
+		INSERT INTO Modulos (Fecha, HoraInicio, HoraFin, PeriodoID)
+		SELECT dias.fecha, b.HoraInicio, b.HoraFin, $1
+		FROM (`+diasPeriodo+`) dias, BloquesHorario b
+		WHERE NOT dias.feriado
+			AND NOT EXISTS (
+				SELECT 1 FROM Modulos m
+				WHERE m.Fecha = dias.fecha AND m.HoraInicio = b.HoraInicio AND m.HoraFin = b.HoraFin
+			)
+		ORDER BY dias.fecha, b.HoraInicio`, periodoID)
+	if err != nil {
+		return nil, fmt.Errorf("error al crear módulos: %v", err)
+	}
+	creados, err := res.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	resumen.Creados = int(creados)
+	resumen.Existentes = resumen.DiasHabiles*resumen.Bloques - resumen.Creados
+
+	err = tx.QueryRow(`
+		SELECT COUNT(*) FROM Modulos m
+		JOIN (`+diasPeriodo+`) dias ON dias.fecha = m.Fecha
+		WHERE m.PeriodoID = $1 AND dias.feriado`, periodoID).Scan(&resumen.EnFeriado)
+	if err != nil {
+		return nil, fmt.Errorf("error al contar módulos en feriado: %v", err)
+	}
+
+	return resumen, tx.Commit()
+}
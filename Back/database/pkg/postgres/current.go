@@ -0,0 +1,94 @@
+package postgres
+
+import (
+	"errors"
+
+	"mysqr/database/pkg/models"
+)
+
+// ErrClaseAmbigua indica que el profesor tiene más de una sección
+// programada en el mismo horario y hay que elegir cuál.
+var ErrClaseAmbigua = errors.New("hay más de una clase programada en este momento")
+
+// modulosActuales son los módulos dentro de la ventana de tolerancia de la
+// hora de pared $1 ($2 segundos antes de HoraInicio, $3 después de
+// HoraFin), con su fase (0 en curso, 1 por empezar, 2 terminado) y la
+// distancia en segundos a la hora actual. El orden con que se elige uno es
+// fase, distancia, ID:
+//   - un módulo en curso gana sobre el siguiente que ya entró en su
+//     tolerancia, y el que empieza a las 10:00 gana sobre el que termina a
+//     las 10:00 (HoraFin no se incluye);
+//   - entre módulos en curso que se solapan gana el que empezó más tarde;
+//   - entre los por empezar, el más próximo; entre los terminados, el que
+//     terminó último.
+const modulosActuales = `
+	SELECT m.ID, m.Fecha, m.HoraInicio, m.HoraFin, f.fase,
+		abs(extract(epoch FROM CASE f.fase WHEN 2 THEN m.Fecha + m.HoraFin ELSE m.Fecha + m.HoraInicio END - $1::timestamp)) AS distancia
+	FROM Modulos m,
+	LATERAL (SELECT CASE
+		WHEN m.Fecha + m.HoraInicio > $1::timestamp THEN 1
+		WHEN m.Fecha + m.HoraFin <= $1::timestamp THEN 2
+		ELSE 0 END AS fase) f
+	WHERE m.Fecha BETWEEN $1::timestamp::date - 1 AND $1::timestamp::date + 1
+		AND m.Fecha + m.HoraInicio - make_interval(secs => $2) <= $1::timestamp
+		AND m.Fecha + m.HoraFin + make_interval(secs => $3) >= $1::timestamp`
+
+var fases = [...]string{models.FaseEnCurso, models.FaseProxima, models.FaseTerminada}
+
+// GetCurrentClasses devuelve las clases programadas del profesor en los
+// módulos que pueden ser el actual, de la más a la menos probable (el orden
+// de modulosActuales y luego SeccionID).
+func (s *DatabaseService) GetCurrentClasses(profesorID int) ([]models.ClaseActual, error) {
+	rows, err := s.db.Query(`
+		SELECT ma.ID, sec.ID, a.Codigo, a.Nombre, to_char(ma.Fecha, 'YYYY-MM-DD'),
+			to_char(ma.HoraInicio, 'HH24:MI'), to_char(ma.HoraFin, 'HH24:MI'), ma.fase
+		FROM (`+modulosActuales+`) ma
+		CROSS JOIN Secciones sec
+		JOIN Asignaturas a ON a.ID = sec.AsignaturaID
+		WHERE sec.ProfesorID = $4
+			AND EXISTS (
+				SELECT 1 FROM ProgramacionClases pc
+				WHERE pc.SeccionID = sec.ID AND pc.ModuloID = ma.ID AND pc.FechaCancelacion IS NULL
+			)
+		ORDER BY ma.fase, ma.distancia, ma.ID, sec.ID`,
+		s.clock.Wall(), s.clock.Antes.Seconds(), s.clock.Despues.Seconds(), profesorID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	clases := []models.ClaseActual{}
+	for rows.Next() {
+		var c models.ClaseActual
+		var fase int
+		if err := rows.Scan(&c.ModuloID, &c.SeccionID, &c.Codigo, &c.Nombre, &c.Fecha,
+			&c.HoraInicio, &c.HoraFin, &fase); err != nil {
+			return nil, err
+		}
+		c.Fase = fases[fase]
+		clases = append(clases, c)
+	}
+	return clases, rows.Err()
+}
+
+// GetCurrentClass busca entre las clases actuales del profesor la de la
+// sección (y el módulo, si moduloID no es 0). Devuelve nil si esa sección
+// no tiene clase ahora.
+func (s *DatabaseService) GetCurrentClass(profesorID, seccionID, moduloID int) (*models.ClaseActual, error) {
+	clases, err := s.GetCurrentClasses(profesorID)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range clases {
+		if c.SeccionID == seccionID && (moduloID == 0 || c.ModuloID == moduloID) {
+			return &c, nil
+		}
+	}
+	return nil, nil
+}
+
+// mismoHorario indica si dos clases caen en el mismo horario y, por lo
+// tanto, ninguna de las dos es preferible a la otra.
+func mismoHorario(a, b models.ClaseActual) bool {
+	return a.Fase == b.Fase && a.Fecha == b.Fecha && a.HoraInicio == b.HoraInicio && a.HoraFin == b.HoraFin
+}
@@ -4,10 +4,11 @@ import (
 	"database/sql"
 	"fmt"
 	"mysqr/database/pkg/models"
+	"mysqr/pkg/clock"
+	passwd "mysqr/pkg/password"
 	"os"
 	"strconv"
 
-	"github.com/lib/pq"
 	_ "github.com/lib/pq"
 )
 
@@ -36,7 +37,8 @@ func getEnvAsInt(key string, defaultValue int) int {
 }
 
 type DatabaseService struct {
-	db *sql.DB
+	db    *sql.DB
+	clock *clock.Clock
 }
 
 func CreateConnection() (*sql.DB, error) {
@@ -55,21 +57,21 @@ func CreateConnection() (*sql.DB, error) {
 	return db, nil
 }
 
-func NewDatabaseService(db *sql.DB) *DatabaseService {
-	return &DatabaseService{db: db}
+// NewDatabaseService arma el servicio sobre la conexión. clk es la hora de
+// la institución con la que se resuelve el módulo actual y se compara
+// contra los horarios de Modulos.
+func NewDatabaseService(db *sql.DB, clk *clock.Clock) *DatabaseService {
+	return &DatabaseService{db: db, clock: clk}
 }
 
-// 1. Obtener moduloID basado en la fecha y hora actual
+// 1. Obtener moduloID basado en la fecha y hora actual de la institución.
+// Un módulo es candidato desde clock.Antes antes de su HoraInicio hasta
+// clock.Despues después de su HoraFin; si hay varios (bloques seguidos o
+// solapados) se elige según las reglas de modulosActuales.
 func (s *DatabaseService) GetCurrentModuleID() (int, error) {
-	query := `
-		SELECT ID 
-		FROM Modulos 
-		WHERE Fecha = CURRENT_DATE
-		AND HoraInicio <= CURRENT_TIME
-		AND HoraFin >= CURRENT_TIME;
-	`
+	query := `SELECT ID FROM (` + modulosActuales + `) ma ORDER BY fase, distancia, ID LIMIT 1`
 	var moduleID int
-	err := s.db.QueryRow(query).Scan(&moduleID)
+	err := s.db.QueryRow(query, s.clock.Wall(), s.clock.Antes.Seconds(), s.clock.Despues.Seconds()).Scan(&moduleID)
 	if err != nil {
 		return 0, err
 	}
@@ -122,18 +124,57 @@ func (s *DatabaseService) GetSectionsByProfessor(profesorID int) ([]models.Secci
 	return sections, nil
 }
 
-// 4. Registro en Asistencia (QR)
-func (s *DatabaseService) RegisterAttendance(alumnoID, seccionID, moduloID int) error {
-	query := `
-		INSERT INTO Asistencia (AlumnoID, SeccionID, ModuloID, FechaRegistro, ManualInd)
-		VALUES ($1, $2, $3, CURRENT_TIMESTAMP, 0)
-	`
-	_, err := s.db.Exec(query, alumnoID, seccionID, moduloID)
-	return err
+// 4. Registro en Asistencia (QR). Queda "late" si el escaneo llega pasado
+// el inicio del módulo más los minutos de gracia de la sección, según la
+// hora de la institución. Un escaneo real pasa a presente (o atrasado) una
+// clase que el profesor había marcado como ausente, conservando su motivo y
+// quién la marcó; una justificada o excusada es decisión del profesor y no
+// se toca. Devuelve el estado con que quedó y el que tenía antes ("" si no
+// había fila). Si no registró nada, estado es "" y anterior el estado que ya
+// tenía la clase (presente, atrasado, justificada o excusada).
+func (s *DatabaseService) RegisterAttendance(alumnoID, seccionID, moduloID int) (estado, anterior string, err error) {
+	var previo sql.NullString
+	err = s.db.QueryRow(`
+		WITH previo AS (
+			SELECT Estado FROM Asistencia WHERE AlumnoID = $1 AND SeccionID = $2 AND ModuloID = $3
+		)
+		INSERT INTO Asistencia (AlumnoID, SeccionID, ModuloID, FechaRegistro, ManualInd, Estado)
+		SELECT $1, $2, $3, CURRENT_TIMESTAMP, 0,
+			CASE WHEN $7::timestamp > m.Fecha + m.HoraInicio + make_interval(mins => COALESCE(p.MinutosGracia, $4))
+				THEN $5 ELSE $6 END
+		FROM Modulos m
+		LEFT JOIN PoliticasAsistencia p ON p.SeccionID = $2
+		WHERE m.ID = $3
+		ON CONFLICT (SeccionID, AlumnoID, ModuloID) DO UPDATE SET
+			Estado = EXCLUDED.Estado, FechaRegistro = EXCLUDED.FechaRegistro, ManualInd = 0
+		WHERE Asistencia.Estado = $8
+		RETURNING Estado, (SELECT Estado FROM previo)`,
+		alumnoID, seccionID, moduloID, models.PoliticaPorDefecto(seccionID).MinutosGracia,
+		models.AsistenciaTarde, models.AsistenciaPresente, s.clock.Wall(), models.AsistenciaAusente).Scan(&estado, &previo)
+	if err == sql.ErrNoRows {
+		err = s.db.QueryRow(`
+			SELECT Estado FROM Asistencia WHERE AlumnoID = $1 AND SeccionID = $2 AND ModuloID = $3`,
+			alumnoID, seccionID, moduloID).Scan(&anterior)
+		return "", anterior, err
+	}
+	return estado, previo.String, err
 }
 
-// IsEnrolled indica si un alumno está inscrito en una sección.
+// IsEnrolled indica si un alumno está inscrito en una sección (y no fue
+// dado de baja).
 func (s *DatabaseService) IsEnrolled(alumnoID, seccionID int) (bool, error) {
+	var exists bool
+	err := s.db.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM Inscripciones
+			WHERE AlumnoID = $1 AND SeccionID = $2 AND FechaBaja IS NULL
+		)`, alumnoID, seccionID).Scan(&exists)
+	return exists, err
+}
+
+// WasEnrolled indica si el alumno está o estuvo inscrito en la sección
+// (aunque lo hayan dado de baja): alcanza para consultar su historial.
+func (s *DatabaseService) WasEnrolled(alumnoID, seccionID int) (bool, error) {
 	var exists bool
 	err := s.db.QueryRow(`
 		SELECT EXISTS (
@@ -142,43 +183,96 @@ func (s *DatabaseService) IsEnrolled(alumnoID, seccionID int) (bool, error) {
 	return exists, err
 }
 
-// HasAttendance indica si ya existe un registro de asistencia (QR o manual)
-// para ese alumno en ese módulo de esa sección.
+// HasAttendance indica si el alumno ya quedó presente o atrasado (por QR o
+// manual) en ese módulo de esa sección. Una fila ausente, justificada o
+// excusada no cuenta: un escaneo la reemplaza.
 func (s *DatabaseService) HasAttendance(alumnoID, seccionID, moduloID int) (bool, error) {
 	var exists bool
 	err := s.db.QueryRow(`
 		SELECT EXISTS (
 			SELECT 1 FROM Asistencia
 			WHERE AlumnoID = $1 AND SeccionID = $2 AND ModuloID = $3
+				AND Estado IN ('present', 'late')
 		)`, alumnoID, seccionID, moduloID).Scan(&exists)
 	return exists, err
 }
 
-// 4.1. Registro en Asistencia manual
+// IsSectionProfessor indica si la sección la dicta ese profesor.
+func (s *DatabaseService) IsSectionProfessor(seccionID, profesorID int) (bool, error) {
+	var exists bool
+	err := s.db.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM Secciones WHERE ID = $1 AND ProfesorID = $2
+		)`, seccionID, profesorID).Scan(&exists)
+	return exists, err
+}
+
+// GetAttendanceTotals cuenta cuántos alumnos inscritos en la sección quedaron
+// presentes o atrasados (QR o manual) en el módulo, contra el total de
+// inscritos.
+func (s *DatabaseService) GetAttendanceTotals(seccionID, moduloID int) (*models.TotalesAsistencia, error) {
+	totals := &models.TotalesAsistencia{SeccionID: seccionID, ModuloID: moduloID}
+	err := s.db.QueryRow(`
+		SELECT
+			COUNT(DISTINCT i.AlumnoID),
+			COUNT(DISTINCT a.AlumnoID)
+		FROM Inscripciones i
+		LEFT JOIN Asistencia a
+			ON a.AlumnoID = i.AlumnoID AND a.SeccionID = i.SeccionID AND a.ModuloID = $2
+			AND a.Estado IN ('present', 'late')
+		WHERE i.SeccionID = $1 AND i.FechaBaja IS NULL`, seccionID, moduloID).Scan(&totals.Inscritos, &totals.Presentes)
+	if err != nil {
+		return nil, err
+	}
+	return totals, nil
+}
+
+// GetStudentName devuelve el nombre para mostrar de un alumno, o "" si no existe.
+func (s *DatabaseService) GetStudentName(alumnoID int) (string, error) {
+	var nombre string
+	err := s.db.QueryRow(`
+		SELECT COALESCE(NULLIF(NombreCompleto, ''), Nombre, '')
+		FROM Alumnos WHERE ID = $1`, alumnoID).Scan(&nombre)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return nombre, err
+}
+
+// 4.1. Registro en Asistencia manual. Si el alumno ya tenía la clase como
+// ausente, justificada o excusada, pasa a presente; si ya estaba presente o
+// atrasado no se toca.
 func (s *DatabaseService) RegisterManualAttendance(alumnoID, seccionID, moduloID int) error {
 	insertQuery := `
 		INSERT INTO Asistencia (AlumnoID, SeccionID, ModuloID, FechaRegistro, ManualInd)
 		VALUES ($1, $2, $3, CURRENT_TIMESTAMP, 1)
+		ON CONFLICT (SeccionID, AlumnoID, ModuloID) DO UPDATE SET
+			Estado = 'present', Motivo = NULL, ModificadoPor = NULL, FechaModificacion = CURRENT_TIMESTAMP
+		WHERE Asistencia.Estado NOT IN ('present', 'late')
 	`
 	_, err := s.db.Exec(insertQuery, alumnoID, seccionID, moduloID)
 	return err
 }
 
-// 4.2. Eliminar registros manuales de una sección
+// 4.2. Eliminar registros manuales de una sección. Los estados cambiados
+// con motivo (SetAttendanceStatus) se conservan.
 func (s *DatabaseService) DeleteManualAttendanceBySection(seccionID int) error {
-	deleteQuery := `DELETE FROM Asistencia WHERE SeccionID = $1 AND ManualInd = 1`
+	deleteQuery := `DELETE FROM Asistencia WHERE SeccionID = $1 AND ManualInd = 1 AND Motivo IS NULL`
 	_, err := s.db.Exec(deleteQuery, seccionID)
 	return err
 }
 
-// 5. Obtener SeccionesID y nombre de asignaturas con el AlumnoId
+// 5. Obtener SeccionesID y nombre de asignaturas con el AlumnoId. Incluye
+// las secciones de las que el alumno fue dado de baja (con FechaBaja, al
+// final) para que pueda seguir viendo su asistencia.
 func (s *DatabaseService) GetSectionsByStudent(alumnoID int) ([]models.SeccionAsignatura, error) {
 	query := `
-		SELECT s.ID, s.AsignaturaID, a.Nombre, a.Codigo
+		SELECT s.ID, s.AsignaturaID, a.Nombre, a.Codigo, i.FechaBaja
 		FROM Secciones s
 		JOIN Asignaturas a ON s.AsignaturaID = a.ID
 		JOIN Inscripciones i ON s.ID = i.SeccionID
 		WHERE i.AlumnoID = $1
+		ORDER BY i.FechaBaja IS NOT NULL, s.ID
 	`
 	rows, err := s.db.Query(query, alumnoID)
 	if err != nil {
@@ -189,7 +283,7 @@ func (s *DatabaseService) GetSectionsByStudent(alumnoID int) ([]models.SeccionAs
 	var sections []models.SeccionAsignatura
 	for rows.Next() {
 		var sec models.SeccionAsignatura
-		err := rows.Scan(&sec.SeccionID, &sec.AsignaturaID, &sec.Nombre, &sec.Codigo)
+		err := rows.Scan(&sec.SeccionID, &sec.AsignaturaID, &sec.Nombre, &sec.Codigo, &sec.FechaBaja)
 		if err != nil {
 			return nil, err
 		}
@@ -198,155 +292,34 @@ func (s *DatabaseService) GetSectionsByStudent(alumnoID int) ([]models.SeccionAs
 	return sections, nil
 }
 
-// 3.1 Obtener ModuloID actual y SeccionID de ProgramacionClases para un profesor
+// 3.1 Obtener ModuloID actual y SeccionID de ProgramacionClases para un
+// profesor: la primera de GetCurrentClasses. Si tiene más de una sección en
+// ese mismo horario devuelve ErrClaseAmbigua y el profesor tiene que elegir.
 func (s *DatabaseService) GetCurrentModuleAndSection(profesorID int) (*models.ModuloSeccion, error) {
-	// Primero obtenemos el módulo actual
-	moduleID, err := s.GetCurrentModuleID()
+	clases, err := s.GetCurrentClasses(profesorID)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, nil // No hay módulo actual, lo cual es válido
-		}
-		return nil, fmt.Errorf("error getting current module: %w", err)
+		return nil, fmt.Errorf("error getting current classes: %w", err)
 	}
-
-	// Luego obtenemos la sección programada para este módulo y profesor
-	query := `
-		SELECT pc.SeccionID
-		FROM ProgramacionClases pc
-		JOIN Secciones s ON pc.SeccionID = s.ID
-		WHERE pc.ModuloID = $1 AND s.ProfesorID = $2;
-	`
-	var seccionID int
-	err = s.db.QueryRow(query, moduleID, profesorID).Scan(&seccionID)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, nil // No hay clase programada, lo cual es válido
-		}
-		return nil, fmt.Errorf("error getting section: %w", err)
+	if len(clases) == 0 {
+		return nil, nil // No hay clase programada, lo cual es válido
+	}
+	if len(clases) > 1 && mismoHorario(clases[0], clases[1]) {
+		return nil, ErrClaseAmbigua
 	}
 
 	return &models.ModuloSeccion{
-		ModuloID:  moduleID,
-		SeccionID: seccionID,
+		ModuloID:  clases[0].ModuloID,
+		SeccionID: clases[0].SeccionID,
 	}, nil
 }
 
-// ProcesarLoteEstudiantes procesa un lote de estudiantes y crea el curso
-func (s *DatabaseService) ProcesarLoteEstudiantes(estudiantes []struct {
-	ID             string `json:"id"`
-	Nombre         string `json:"Nombre"`
-	NombreCompleto string `json:"NombreCompleto"`
-	Rut            string `json:"Rut"`
-	Email          string `json:"Email"`
-}, curso struct {
-	Codigo string   `json:"codigo"`
-	Nombre string   `json:"nombre"`
-	Dias   []string `json:"dias"`
-	Bloque string   `json:"bloque"`
-}, profesorID int) error {
-	// Iniciar transacción
-	tx, err := s.db.Begin()
-	if err != nil {
-		return fmt.Errorf("error al iniciar transacción: %v", err)
-	}
-	defer tx.Rollback()
-
-	// 1. Insertar o actualizar asignatura
-	var asignaturaID int
-	err = tx.QueryRow(`
-		INSERT INTO Asignaturas (Codigo, Nombre)
-		VALUES ($1, $2)
-		ON CONFLICT (Codigo) DO UPDATE
-		SET Nombre = $2
-		RETURNING ID`, curso.Codigo, curso.Nombre).Scan(&asignaturaID)
-	if err != nil {
-		return fmt.Errorf("error al insertar asignatura: %v", err)
-	}
-
-	// 2. Crear sección
-	var seccionID int
-	err = tx.QueryRow(`
-		INSERT INTO Secciones (AsignaturaID, ProfesorID)
-		VALUES ($1, $2)
-		RETURNING ID`, asignaturaID, profesorID).Scan(&seccionID)
-	if err != nil {
-		return fmt.Errorf("error al crear sección: %v", err)
-	}
-
-	// 3. Obtener módulos que coinciden con los días y bloque
-	rows, err := tx.Query(`
-		SELECT ID FROM Modulos 
-		WHERE HoraFin = $1 
-		AND EXTRACT(DOW FROM Fecha) IN (
-			SELECT CASE 
-				WHEN dia = 'Lunes' THEN 1
-				WHEN dia = 'Martes' THEN 2
-				WHEN dia = 'Miércoles' THEN 3
-				WHEN dia = 'Jueves' THEN 4
-				WHEN dia = 'Viernes' THEN 5
-			END
-			FROM unnest($2::text[]) as dia
-		)`, curso.Bloque, pq.Array(curso.Dias))
+// RegistrarAlumno crea un alumno, sus credenciales y lo inscribe en una sección
+func (s *DatabaseService) RegistrarAlumno(username, password, nombre string) error {
+	hash, err := passwd.Hash(password)
 	if err != nil {
-		return fmt.Errorf("error al obtener módulos: %v", err)
-	}
-	defer rows.Close()
-
-	var moduloIDs []int
-	for rows.Next() {
-		var id int
-		if err := rows.Scan(&id); err != nil {
-			return fmt.Errorf("error al escanear módulo: %v", err)
-		}
-		moduloIDs = append(moduloIDs, id)
-	}
-
-	// 4. Crear programación de clases
-	for _, moduloID := range moduloIDs {
-		_, err = tx.Exec(`
-			INSERT INTO ProgramacionClases (SeccionID, ModuloID)
-			VALUES ($1, $2)`, seccionID, moduloID)
-		if err != nil {
-			return fmt.Errorf("error al crear programación: %v", err)
-		}
+		return fmt.Errorf("error al hashear contraseña: %v", err)
 	}
 
-	// 5. Insertar o actualizar estudiantes
-	for _, estudiante := range estudiantes {
-		// Insertar estudiante
-		_, err = tx.Exec(`
-			INSERT INTO Alumnos (ID, Rut, Nombre, NombreCompleto, Email)
-			VALUES ($1, $2, $3, $4, $5)
-			ON CONFLICT (ID) DO UPDATE
-			SET Rut = $2, Nombre = $3, NombreCompleto = $4, Email = $5`,
-			estudiante.ID, estudiante.Rut, estudiante.Nombre, estudiante.NombreCompleto, estudiante.Email)
-		if err != nil {
-			return fmt.Errorf("error al insertar estudiante %s: %v", estudiante.ID, err)
-		}
-
-		// Insertar inscripción
-		_, err = tx.Exec(`
-			INSERT INTO Inscripciones (AlumnoID, SeccionID)
-			SELECT $1, $2
-			WHERE NOT EXISTS (
-				SELECT 1 FROM Inscripciones 
-				WHERE AlumnoID = $1 AND SeccionID = $2
-			)`, estudiante.ID, seccionID)
-		if err != nil {
-			return fmt.Errorf("error al inscribir estudiante %s: %v", estudiante.ID, err)
-		}
-	}
-
-	// Confirmar transacción
-	if err = tx.Commit(); err != nil {
-		return fmt.Errorf("error al confirmar transacción: %v", err)
-	}
-
-	return nil
-}
-
-// RegistrarAlumno crea un alumno, sus credenciales y lo inscribe en una sección
-func (s *DatabaseService) RegistrarAlumno(username, password, nombre string) error {
 	tx, err := s.db.Begin()
 	if err != nil {
 		return err
@@ -366,7 +339,7 @@ func (s *DatabaseService) RegistrarAlumno(username, password, nombre string) err
 	_, err = tx.Exec(`
 		INSERT INTO AUTH (username, password_hash, rol, AlumnoID, ProfesorID, Rut)
 		VALUES ($1, $2, 'alumno', $3, NULL, 0)
-	`, username, password, alumnoID)
+	`, username, hash, alumnoID)
 	if err != nil {
 		return fmt.Errorf("error al crear credenciales: %v", err)
 	}
@@ -0,0 +1,220 @@
+package postgres
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"mysqr/database/pkg/models"
+)
+
+// diasPasadosCalendario es cuánto hacia atrás trae el feed iCal: lo
+// suficiente para ver la semana pasada sin cargar el período completo.
+const diasPasadosCalendario = 30
+
+// columnaDueno es la columna de TokensCalendario para cada rol con feed.
+var columnaDueno = map[string]string{
+	"profesor": "ProfesorID",
+	"alumno":   "AlumnoID",
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateCalendarToken crea el token del feed iCal del usuario (rol
+// "profesor" o "alumno" con su ID) y revoca el que tuviera. El token solo
+// se puede ver ahora: en la base queda su hash.
+func (s *DatabaseService) CreateCalendarToken(rol string, id int) (string, error) {
+	columna, ok := columnaDueno[rol]
+	if !ok {
+		return "", fmt.Errorf("el rol %q no tiene calendario", rol)
+	}
+
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(raw)
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(fmt.Sprintf(`
+		UPDATE TokensCalendario SET FechaRevocacion = CURRENT_TIMESTAMP
+		WHERE %s = $1 AND FechaRevocacion IS NULL`, columna), id)
+	if err != nil {
+		return "", err
+	}
+	_, err = tx.Exec(fmt.Sprintf(`INSERT INTO TokensCalendario (TokenHash, %s) VALUES ($1, $2)`, columna),
+		hashToken(token), id)
+	if err != nil {
+		return "", err
+	}
+	return token, tx.Commit()
+}
+
+// RevokeCalendarToken revoca el token vigente del usuario. Devuelve false
+// si no tenía.
+func (s *DatabaseService) RevokeCalendarToken(rol string, id int) (bool, error) {
+	columna, ok := columnaDueno[rol]
+	if !ok {
+		return false, nil
+	}
+	res, err := s.db.Exec(fmt.Sprintf(`
+		UPDATE TokensCalendario SET FechaRevocacion = CURRENT_TIMESTAMP
+		WHERE %s = $1 AND FechaRevocacion IS NULL`, columna), id)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// GetCalendarTokenDate devuelve cuándo se creó el token vigente del
+// usuario, o nil si no tiene.
+func (s *DatabaseService) GetCalendarTokenDate(rol string, id int) (*time.Time, error) {
+	columna, ok := columnaDueno[rol]
+	if !ok {
+		return nil, nil
+	}
+	var fecha time.Time
+	err := s.db.QueryRow(fmt.Sprintf(`
+		SELECT FechaCreacion FROM TokensCalendario
+		WHERE %s = $1 AND FechaRevocacion IS NULL`, columna), id).Scan(&fecha)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &fecha, nil
+}
+
+// CalendarTokenOwner devuelve de quién es un token vigente (rol e ID), o
+// rol "" si no existe o fue revocado.
+func (s *DatabaseService) CalendarTokenOwner(token string) (rol string, id int, err error) {
+	var profesorID, alumnoID sql.NullInt64
+	err = s.db.QueryRow(`
+		SELECT ProfesorID, AlumnoID FROM TokensCalendario
+		WHERE TokenHash = $1 AND FechaRevocacion IS NULL`, hashToken(token)).Scan(&profesorID, &alumnoID)
+	switch {
+	case err == sql.ErrNoRows:
+		return "", 0, nil
+	case err != nil:
+		return "", 0, err
+	case profesorID.Valid:
+		return "profesor", int(profesorID.Int64), nil
+	default:
+		return "alumno", int(alumnoID.Int64), nil
+	}
+}
+
+// GetCalendarClasses devuelve las clases del usuario (las secciones que
+// dicta el profesor o en que está inscrito el alumno) desde hace
+// diasPasadosCalendario días, incluidas las canceladas y las que caen en un
+// feriado. Inicio y Fin son instantes: la hora de pared del módulo en la
+// zona de la institución.
+func (s *DatabaseService) GetCalendarClasses(rol string, id int) ([]models.ClaseCalendario, error) {
+	var filtro string
+	switch rol {
+	case "profesor":
+		filtro = `sec.ProfesorID = $1`
+	case "alumno":
+		filtro = `EXISTS (
+			SELECT 1 FROM Inscripciones i
+			WHERE i.SeccionID = sec.ID AND i.AlumnoID = $1 AND i.FechaBaja IS NULL
+		)`
+	default:
+		return nil, fmt.Errorf("el rol %q no tiene calendario", rol)
+	}
+
+	rows, err := s.db.Query(`
+		SELECT DISTINCT ON (m.Fecha, m.HoraInicio, sec.ID, m.ID)
+			sec.ID, m.ID, a.Codigo, a.Nombre, COALESCE(sec.Ubicacion, ''),
+			(m.Fecha + m.HoraInicio) AT TIME ZONE $2, (m.Fecha + m.HoraFin) AT TIME ZONE $2,
+			pc.FechaCancelacion IS NOT NULL, COALESCE(pc.MotivoCancelacion, ''),
+			COALESCE((
+				SELECT f.Descripcion FROM Feriados f
+				WHERE m.Fecha BETWEEN f.FechaInicio AND f.FechaFin
+				ORDER BY f.FechaInicio LIMIT 1
+			), '')
+		FROM ProgramacionClases pc
+		JOIN Secciones sec ON sec.ID = pc.SeccionID
+		JOIN Asignaturas a ON a.ID = sec.AsignaturaID
+		JOIN Modulos m ON m.ID = pc.ModuloID
+		WHERE `+filtro+`
+			AND m.Fecha >= $3::date - $4::int
+		ORDER BY m.Fecha, m.HoraInicio, sec.ID, m.ID, pc.FechaCancelacion NULLS FIRST`,
+		id, s.clock.Zone(), s.clock.Now().Format(time.DateOnly), diasPasadosCalendario)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	clases := []models.ClaseCalendario{}
+	for rows.Next() {
+		var c models.ClaseCalendario
+		if err := rows.Scan(&c.SeccionID, &c.ModuloID, &c.Codigo, &c.Nombre, &c.Ubicacion,
+			&c.Inicio, &c.Fin, &c.Cancelada, &c.Motivo, &c.Feriado); err != nil {
+			return nil, err
+		}
+		clases = append(clases, c)
+	}
+	return clases, rows.Err()
+}
+
+// CancelClass marca como cancelada la clase de la sección en el módulo, con
+// su motivo, y en la misma transacción cierra su sesión de clase si estaba
+// abierta o pausada: los QR que ya circulan dejan de valer. Cancelarla de
+// nuevo solo cambia el motivo. Devuelve false si la sección no tiene clase
+// en ese módulo.
+func (s *DatabaseService) CancelClass(seccionID, moduloID int, motivo string) (bool, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`
+		UPDATE ProgramacionClases
+		SET FechaCancelacion = COALESCE(FechaCancelacion, CURRENT_TIMESTAMP), MotivoCancelacion = $3
+		WHERE SeccionID = $1 AND ModuloID = $2`, seccionID, moduloID, motivo)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil || n == 0 {
+		return false, err
+	}
+
+	if _, err := tx.Exec(`
+		UPDATE SesionesClase SET Estado = 'closed', FechaCierre = CURRENT_TIMESTAMP
+		WHERE SeccionID = $1 AND ModuloID = $2 AND Estado <> 'closed'`, seccionID, moduloID); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// RestoreClass deshace la cancelación de una clase. La sesión que cerró la
+// cancelación sigue cerrada (una sesión cerrada no se reabre): la
+// asistencia de esa clase se corrige a mano. Devuelve false si la sección
+// no tiene clase en ese módulo.
+func (s *DatabaseService) RestoreClass(seccionID, moduloID int) (bool, error) {
+	res, err := s.db.Exec(`
+		UPDATE ProgramacionClases SET FechaCancelacion = NULL, MotivoCancelacion = NULL
+		WHERE SeccionID = $1 AND ModuloID = $2`, seccionID, moduloID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
@@ -0,0 +1,333 @@
+package postgres
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+
+	"mysqr/database/pkg/models"
+
+	"github.com/lib/pq"
+)
+
+// ErrSeccionAjena indica que la sección que identifica la importación es de
+// otro profesor.
+var ErrSeccionAjena = errors.New("la sección es de otro profesor")
+
+// ImportarCurso sincroniza una sección con el roster importado. La sección
+// se busca por su identificador externo (curso.SeccionExterna) y se crea,
+// con sus clases programadas, solo si no existe. Los alumnos que no estaban
+// se inscriben, los que ya no vienen se dan de baja (la inscripción queda
+// con FechaBaja y su asistencia se conserva) y los que cambiaron de datos
+// se actualizan. Reimportar el mismo archivo no cambia nada. Sin confirmar
+// es una vista previa: calcula el mismo resumen sin escribir nada.
+func (s *DatabaseService) ImportarCurso(curso models.CursoImportado, alumnos []models.AlumnoImportado, profesorID int, confirmar bool) (*models.ResumenImportacion, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("error al iniciar transacción: %v", err)
+	}
+	defer tx.Rollback()
+
+	resumen := &models.ResumenImportacion{
+		Curso:       curso,
+		Agregados:   []models.AlumnoImportado{},
+		Eliminados:  []models.AlumnoImportado{},
+		Modificados: []models.CambioAlumno{},
+	}
+
+	var existe bool
+	err = tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM Asignaturas WHERE Codigo = $1)`, curso.Codigo).Scan(&existe)
+	if err != nil {
+		return nil, fmt.Errorf("error al buscar asignatura: %v", err)
+	}
+	resumen.AsignaturaNueva = !existe
+
+	seccionID, err := buscarSeccionExterna(tx, curso, profesorID)
+	if err != nil {
+		return nil, err
+	}
+	resumen.SeccionID = seccionID
+	resumen.SeccionNueva = seccionID == 0
+
+	moduloIDs, err := modulosDelBloque(tx, seccionID, curso.PeriodoID, curso.Dias, curso.Bloque)
+	if err != nil {
+		return nil, err
+	}
+	resumen.ModulosProgramados = len(moduloIDs)
+
+	actuales, err := alumnosActuales(tx, alumnos)
+	if err != nil {
+		return nil, err
+	}
+	inscritos, err := inscritosVigentes(tx, seccionID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Diferencia entre el archivo y la sección, en el orden del archivo.
+	var escribir []models.AlumnoImportado
+	enArchivo := make(map[int]bool, len(alumnos))
+	for _, a := range alumnos {
+		enArchivo[a.ID] = true
+		actual, ok := actuales[a.ID]
+		campos := camposDistintos(actual, a)
+		if !ok || len(campos) > 0 {
+			escribir = append(escribir, a)
+		}
+		if ok && len(campos) > 0 {
+			resumen.Modificados = append(resumen.Modificados, models.CambioAlumno{Campos: campos, AlumnoImportado: a})
+		}
+		if _, ok := inscritos[a.ID]; !ok {
+			resumen.Agregados = append(resumen.Agregados, a)
+		} else if len(campos) == 0 {
+			resumen.SinCambios++
+		}
+	}
+	for _, id := range ordenados(inscritos) {
+		if !enArchivo[id] {
+			resumen.Eliminados = append(resumen.Eliminados, inscritos[id])
+		}
+	}
+
+	if !confirmar {
+		return resumen, nil
+	}
+
+	// 1. Insertar o actualizar asignatura
+	var asignaturaID int
+	err = tx.QueryRow(`
+		INSERT INTO Asignaturas (Codigo, Nombre)
+		VALUES ($1, $2)
+		ON CONFLICT (Codigo) DO UPDATE
+		SET Nombre = $2
+		RETURNING ID`, curso.Codigo, curso.Nombre).Scan(&asignaturaID)
+	if err != nil {
+		return nil, fmt.Errorf("error al insertar asignatura: %v", err)
+	}
+
+	// 2. Crear la sección si es la primera importación
+	if seccionID == 0 {
+		err = tx.QueryRow(`
+			INSERT INTO Secciones (AsignaturaID, ProfesorID, IDExterno, PeriodoID)
+			VALUES ($1, $2, $3, NULLIF($4, 0))
+			RETURNING ID`, asignaturaID, profesorID, curso.SeccionExterna, curso.PeriodoID).Scan(&seccionID)
+		if err != nil {
+			return nil, fmt.Errorf("error al crear sección: %v", err)
+		}
+		if err := createSectionPartitions(tx, seccionID); err != nil {
+			return nil, err
+		}
+		resumen.SeccionID = seccionID
+	}
+
+	// 3. Programar las clases que falten
+	_, err = tx.Exec(`
+		INSERT INTO ProgramacionClases (SeccionID, ModuloID)
+		SELECT $1, unnest($2::int[])`, seccionID, pq.Array(moduloIDs))
+	if err != nil {
+		return nil, fmt.Errorf("error al crear programación: %v", err)
+	}
+
+	// 4. Insertar o actualizar los alumnos nuevos o con datos distintos
+	for _, a := range escribir {
+		_, err = tx.Exec(`
+			INSERT INTO Alumnos (ID, Rut, Nombre, NombreCompleto, Email)
+			VALUES ($1, NULLIF($2, 0), $3, $4, $5)
+			ON CONFLICT (ID) DO UPDATE
+			SET Rut = COALESCE(EXCLUDED.Rut, Alumnos.Rut), Nombre = EXCLUDED.Nombre,
+				NombreCompleto = EXCLUDED.NombreCompleto, Email = EXCLUDED.Email`,
+			a.ID, a.Rut, a.Nombre, a.NombreCompleto, a.Email)
+		if err != nil {
+			return nil, fmt.Errorf("error al insertar estudiante %d: %v", a.ID, err)
+		}
+	}
+
+	// 5. Inscribir a los que llegan (o reactivar a los que habían salido)
+	_, err = tx.Exec(`
+		INSERT INTO Inscripciones (AlumnoID, SeccionID)
+		SELECT unnest($1::int[]), $2
+		ON CONFLICT (AlumnoID, SeccionID) DO UPDATE SET FechaBaja = NULL`,
+		pq.Array(idsDe(resumen.Agregados)), seccionID)
+	if err != nil {
+		return nil, fmt.Errorf("error al inscribir estudiantes: %v", err)
+	}
+
+	// 6. Dar de baja a los que ya no vienen en el roster
+	_, err = tx.Exec(`
+		UPDATE Inscripciones SET FechaBaja = CURRENT_TIMESTAMP
+		WHERE SeccionID = $1 AND AlumnoID = ANY($2) AND FechaBaja IS NULL`,
+		seccionID, pq.Array(idsDe(resumen.Eliminados)))
+	if err != nil {
+		return nil, fmt.Errorf("error al dar de baja estudiantes: %v", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error al confirmar transacción: %v", err)
+	}
+	resumen.Confirmada = true
+	return resumen, nil
+}
+
+// buscarSeccionExterna devuelve la sección con ese identificador externo en
+// el período de la importación (curso.PeriodoID), o 0 si todavía no existe,
+// y la deja bloqueada hasta el fin de la transacción. El código de Canvas se
+// repite cada período, así que una sección de otro período nunca se
+// reutiliza. Las secciones creadas antes de que existiera IDExterno se
+// adoptan: si el profesor tiene una de la misma asignatura y el mismo
+// período sin IDExterno, se le asigna (la más antigua).
+func buscarSeccionExterna(tx *sql.Tx, curso models.CursoImportado, profesorID int) (int, error) {
+	var seccionID, dueno int
+	err := tx.QueryRow(`
+		SELECT ID, COALESCE(ProfesorID, 0) FROM Secciones
+		WHERE IDExterno = $1 AND COALESCE(PeriodoID, 0) = $2
+		FOR UPDATE`, curso.SeccionExterna, curso.PeriodoID).Scan(&seccionID, &dueno)
+	if err == nil {
+		if dueno != profesorID {
+			return 0, ErrSeccionAjena
+		}
+		return seccionID, nil
+	}
+	if err != sql.ErrNoRows {
+		return 0, fmt.Errorf("error al buscar sección: %v", err)
+	}
+
+	err = tx.QueryRow(`
+		SELECT s.ID FROM Secciones s
+		JOIN Asignaturas a ON a.ID = s.AsignaturaID
+		WHERE a.Codigo = $1 AND s.ProfesorID = $2 AND s.IDExterno IS NULL
+			AND COALESCE(s.PeriodoID, 0) = $3
+		ORDER BY s.ID
+		LIMIT 1
+		FOR UPDATE OF s`, curso.Codigo, profesorID, curso.PeriodoID).Scan(&seccionID)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("error al buscar sección: %v", err)
+	}
+	_, err = tx.Exec(`UPDATE Secciones SET IDExterno = $1 WHERE ID = $2`, curso.SeccionExterna, seccionID)
+	if err != nil {
+		return 0, fmt.Errorf("error al asignar IDExterno: %v", err)
+	}
+	return seccionID, nil
+}
+
+// diasSemana traduce los días que se eligen en la app a ISODOW.
+var diasSemana = map[string]int{
+	"Lunes":     1,
+	"Martes":    2,
+	"Miércoles": 3,
+	"Jueves":    4,
+	"Viernes":   5,
+}
+
+// modulosDelBloque devuelve los módulos del período que caen en los días
+// (Lunes a Viernes) y terminan a la hora del bloque (HH:MM), sin los que la
+// sección ya tiene programados. Con periodoID 0 considera todos los
+// módulos. Sin días o sin bloque no hay módulos.
+func modulosDelBloque(tx *sql.Tx, seccionID, periodoID int, dias []string, bloque string) ([]int, error) {
+	if len(dias) == 0 || bloque == "" {
+		return []int{}, nil
+	}
+	isodow := make([]int, 0, len(dias))
+	for _, dia := range dias {
+		if n, ok := diasSemana[dia]; ok {
+			isodow = append(isodow, n)
+		}
+	}
+	rows, err := tx.Query(`
+		SELECT m.ID FROM Modulos m
+		WHERE m.HoraFin = $1
+		AND EXTRACT(ISODOW FROM m.Fecha) = ANY($2::int[])
+		AND ($4 = 0 OR m.PeriodoID = $4)
+		AND NOT EXISTS (
+			SELECT 1 FROM ProgramacionClases pc
+			WHERE pc.SeccionID = $3 AND pc.ModuloID = m.ID
+		)
+		ORDER BY m.Fecha, m.HoraInicio`, bloque, pq.Array(isodow), seccionID, periodoID)
+	if err != nil {
+		return nil, fmt.Errorf("error al obtener módulos: %v", err)
+	}
+	defer rows.Close()
+
+	moduloIDs := []int{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("error al escanear módulo: %v", err)
+		}
+		moduloIDs = append(moduloIDs, id)
+	}
+	return moduloIDs, rows.Err()
+}
+
+// alumnosActuales devuelve los datos que hay en Alumnos de los alumnos
+// importados que ya existen, por ID.
+func alumnosActuales(tx *sql.Tx, alumnos []models.AlumnoImportado) (map[int]models.AlumnoImportado, error) {
+	return scanAlumnos(tx.Query(`
+		SELECT ID, COALESCE(Rut, 0), COALESCE(Nombre, ''), COALESCE(NombreCompleto, ''), COALESCE(Email, '')
+		FROM Alumnos WHERE ID = ANY($1)`, pq.Array(idsDe(alumnos))))
+}
+
+// inscritosVigentes devuelve los alumnos inscritos en la sección que no
+// fueron dados de baja, por ID.
+func inscritosVigentes(tx *sql.Tx, seccionID int) (map[int]models.AlumnoImportado, error) {
+	return scanAlumnos(tx.Query(`
+		SELECT al.ID, COALESCE(al.Rut, 0), COALESCE(al.Nombre, ''), COALESCE(al.NombreCompleto, ''), COALESCE(al.Email, '')
+		FROM Inscripciones i
+		JOIN Alumnos al ON al.ID = i.AlumnoID
+		WHERE i.SeccionID = $1 AND i.FechaBaja IS NULL`, seccionID))
+}
+
+func scanAlumnos(rows *sql.Rows, err error) (map[int]models.AlumnoImportado, error) {
+	if err != nil {
+		return nil, fmt.Errorf("error al buscar alumnos: %v", err)
+	}
+	defer rows.Close()
+
+	alumnos := map[int]models.AlumnoImportado{}
+	for rows.Next() {
+		var a models.AlumnoImportado
+		if err := rows.Scan(&a.ID, &a.Rut, &a.Nombre, &a.NombreCompleto, &a.Email); err != nil {
+			return nil, fmt.Errorf("error al escanear alumno: %v", err)
+		}
+		alumnos[a.ID] = a
+	}
+	return alumnos, rows.Err()
+}
+
+// camposDistintos lista qué datos del alumno cambian de actual a nuevo.
+func camposDistintos(actual, nuevo models.AlumnoImportado) []string {
+	var campos []string
+	if actual.Nombre != nuevo.Nombre || actual.NombreCompleto != nuevo.NombreCompleto {
+		campos = append(campos, models.CampoNombre)
+	}
+	if actual.Email != nuevo.Email {
+		campos = append(campos, models.CampoEmail)
+	}
+	// Un archivo sin RUT no borra el que ya estaba.
+	if nuevo.Rut != 0 && actual.Rut != nuevo.Rut {
+		campos = append(campos, models.CampoRut)
+	}
+	return campos
+}
+
+func idsDe(alumnos []models.AlumnoImportado) []int {
+	ids := make([]int, len(alumnos))
+	for i, a := range alumnos {
+		ids[i] = a.ID
+	}
+	return ids
+}
+
+// ordenados devuelve los IDs del mapa en orden, para que el resumen no
+// cambie de una llamada a otra.
+func ordenados(alumnos map[int]models.AlumnoImportado) []int {
+	ids := make([]int, 0, len(alumnos))
+	for id := range alumnos {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	return ids
+}
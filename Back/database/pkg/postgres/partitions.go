@@ -0,0 +1,115 @@
+package postgres
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"mysqr/database/pkg/models"
+)
+
+// createSectionPartitions crea la partición de Asistencia de una sección
+// dentro de tx, para que la sección nunca quede visible sin ella.
+func createSectionPartitions(tx *sql.Tx, seccionID int) error {
+	if _, err := tx.Exec(`SELECT crear_particiones_seccion($1)`, seccionID); err != nil {
+		return fmt.Errorf("error al crear particiones de la sección %d: %v", seccionID, err)
+	}
+	return nil
+}
+
+// CheckPartitions revisa, para cada sección, si tiene su partición en
+// Asistencia y cuántas filas suyas hay en la DEFAULT.
+func (s *DatabaseService) CheckPartitions() ([]models.EstadoParticion, error) {
+	rows, err := s.db.Query(`
+		WITH adjuntas AS (
+			SELECT c.relname AS nombre
+			FROM pg_inherits i
+			JOIN pg_class c ON c.oid = i.inhrelid
+			WHERE i.inhparent = 'asistencia'::regclass
+		)
+		SELECT 'asistencia', s.ID,
+			CASE
+				WHEN EXISTS (SELECT 1 FROM adjuntas a WHERE a.nombre = 'asistencia_' || s.ID) THEN $1
+				WHEN to_regclass('archivo.asistencia_' || s.ID) IS NOT NULL THEN $2
+				ELSE $3
+			END,
+			(SELECT COUNT(*) FROM asistencia_default d WHERE d.SeccionID = s.ID)
+		FROM Secciones s
+		ORDER BY s.ID`,
+		models.ParticionOK, models.ParticionArchivada, models.ParticionFaltante)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var estados []models.EstadoParticion
+	for rows.Next() {
+		var e models.EstadoParticion
+		if err := rows.Scan(&e.Tabla, &e.SeccionID, &e.Estado, &e.FilasDefault); err != nil {
+			return nil, err
+		}
+		estados = append(estados, e)
+	}
+	return estados, rows.Err()
+}
+
+// RepairPartitions crea las particiones que falten y mueve a ellas las
+// filas que estaban en la DEFAULT. Devuelve las secciones reparadas.
+func (s *DatabaseService) RepairPartitions() ([]int, error) {
+	estados, err := s.CheckPartitions()
+	if err != nil {
+		return nil, err
+	}
+
+	var reparadas []int
+	for _, e := range estados {
+		if e.Estado != models.ParticionFaltante {
+			continue
+		}
+		if _, err := s.db.Exec(`SELECT crear_particiones_seccion($1)`, e.SeccionID); err != nil {
+			return reparadas, fmt.Errorf("error al reparar la sección %d: %v", e.SeccionID, err)
+		}
+		reparadas = append(reparadas, e.SeccionID)
+	}
+	return reparadas, nil
+}
+
+// ArchivePartitions separa y mueve al esquema archivo las particiones de las
+// secciones cuya última clase programada fue antes de before. Sus filas
+// dejan de aparecer en Asistencia pero no se borran.
+// Devuelve las secciones archivadas.
+func (s *DatabaseService) ArchivePartitions(before time.Time) ([]int, error) {
+	rows, err := s.db.Query(`
+		SELECT pc.SeccionID
+		FROM ProgramacionClases pc
+		JOIN Modulos m ON m.ID = pc.ModuloID
+		WHERE to_regclass('public.asistencia_' || pc.SeccionID) IS NOT NULL
+		GROUP BY pc.SeccionID
+		HAVING MAX(m.Fecha) < $1
+		ORDER BY pc.SeccionID`, before)
+	if err != nil {
+		return nil, err
+	}
+	var secciones []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		secciones = append(secciones, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var archivadas []int
+	for _, id := range secciones {
+		if _, err := s.db.Exec(`SELECT archivar_particiones_seccion($1)`, id); err != nil {
+			return archivadas, fmt.Errorf("error al archivar la sección %d: %v", id, err)
+		}
+		archivadas = append(archivadas, id)
+	}
+	return archivadas, nil
+}
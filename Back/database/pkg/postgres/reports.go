@@ -0,0 +1,315 @@
+package postgres
+
+import (
+	"math"
+	"time"
+
+	"mysqr/database/pkg/models"
+)
+
+// claseAlumno es una clase programada de la sección para un alumno inscrito
+// (o que lo estuvo, con FechaBaja), con su fila de Asistencia si la tiene
+// (Origen vacío si no). Dictada indica que la clase ya empezó.
+type claseAlumno struct {
+	AlumnoID      int
+	Nombre        string
+	FechaBaja     *time.Time
+	ModuloID      int
+	Fecha         time.Time
+	HoraInicio    string
+	HoraFin       string
+	Dictada       bool
+	Estado        string
+	Origen        string
+	FechaRegistro *time.Time
+	Motivo        string
+}
+
+// eachSectionClass cruza inscritos × clases programadas (no canceladas) de
+// la sección con Asistencia y llama a fn por cada clase, sin cargarlas todas
+// en memoria. Los alumnos dados de baja siguen apareciendo con las clases
+// que empezaron antes de la baja (y cualquiera en que tengan registro), para
+// no perder su historial. Es solo lectura: dos profesores mirando el mismo
+// reporte no se pisan. Con alumnoID > 0 se limita a ese alumno. Viene
+// ordenado por alumno y, dentro de cada uno, por fecha y hora del módulo.
+func (s *DatabaseService) eachSectionClass(seccionID, alumnoID int, fn func(claseAlumno) error) error {
+	rows, err := s.db.Query(`
+		SELECT al.ID, COALESCE(al.NombreCompleto, ''), i.FechaBaja, m.ID, m.Fecha,
+			to_char(m.HoraInicio, 'HH24:MI'), to_char(m.HoraFin, 'HH24:MI'),
+			m.Fecha + m.HoraInicio <= $6::timestamp,
+			COALESCE(a.Estado, $5),
+			CASE a.ManualInd WHEN 1 THEN $3 WHEN 0 THEN $4 ELSE '' END,
+			a.FechaRegistro, COALESCE(a.Motivo, '')
+		FROM Inscripciones i
+		JOIN Alumnos al ON al.ID = i.AlumnoID
+		JOIN ProgramacionClases pc ON pc.SeccionID = i.SeccionID AND pc.FechaCancelacion IS NULL
+		JOIN Modulos m ON m.ID = pc.ModuloID
+		LEFT JOIN Asistencia a
+			ON a.SeccionID = i.SeccionID AND a.AlumnoID = i.AlumnoID AND a.ModuloID = m.ID
+		WHERE i.SeccionID = $1 AND ($2 = 0 OR i.AlumnoID = $2)
+			AND (i.FechaBaja IS NULL OR a.AlumnoID IS NOT NULL
+				OR m.Fecha + m.HoraInicio < i.FechaBaja AT TIME ZONE $7::text)
+		ORDER BY al.NombreCompleto, al.ID, m.Fecha, m.HoraInicio, m.ID`,
+		seccionID, alumnoID, models.OrigenManual, models.OrigenQR, models.AsistenciaAusente, s.clock.Wall(),
+		s.clock.Location.String())
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var c claseAlumno
+		if err := rows.Scan(&c.AlumnoID, &c.Nombre, &c.FechaBaja, &c.ModuloID, &c.Fecha, &c.HoraInicio, &c.HoraFin,
+			&c.Dictada, &c.Estado, &c.Origen, &c.FechaRegistro, &c.Motivo); err != nil {
+			return err
+		}
+		if err := fn(c); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// sectionAttendance es eachSectionClass juntando todo en un slice.
+func (s *DatabaseService) sectionAttendance(seccionID, alumnoID int) ([]claseAlumno, error) {
+	var clases []claseAlumno
+	err := s.eachSectionClass(seccionID, alumnoID, func(c claseAlumno) error {
+		clases = append(clases, c)
+		return nil
+	})
+	return clases, err
+}
+
+// clave es la llave "MM-DD" de los reportes v1. Dos módulos del mismo día
+// comparten llave y gana el último.
+func (c claseAlumno) clave() string {
+	return c.Fecha.Format("01-02")
+}
+
+// estado es el emoji de los reportes v1: 🟢 si asistió (a tiempo o
+// atrasado), 🔴 en cualquier otro caso.
+func (c claseAlumno) estado() string {
+	if c.Estado == models.AsistenciaPresente || c.Estado == models.AsistenciaTarde {
+		return models.EstadoPresente
+	}
+	return models.EstadoAusente
+}
+
+func (c claseAlumno) registro() models.RegistroAsistencia {
+	return models.RegistroAsistencia{
+		ModuloID:      c.ModuloID,
+		Fecha:         c.Fecha.Format("2006-01-02"),
+		HoraInicio:    c.HoraInicio,
+		HoraFin:       c.HoraFin,
+		Dictada:       c.Dictada,
+		Estado:        c.Estado,
+		Origen:        c.Origen,
+		FechaRegistro: c.FechaRegistro,
+		Motivo:        c.Motivo,
+	}
+}
+
+// resumir calcula los totales de un alumno sobre las clases ya dictadas,
+// con los créditos de la política de la sección.
+func resumir(registros []claseAlumno, p models.PoliticaAsistencia) models.ResumenAsistencia {
+	r := models.ResumenAsistencia{PorEstado: map[string]int{}}
+	total, credito := 0, 0
+	for _, c := range registros {
+		if !c.Dictada {
+			continue
+		}
+		r.Dictadas++
+		r.PorEstado[c.Estado]++
+
+		switch c.Estado {
+		case models.AsistenciaPresente:
+			credito += 100
+		case models.AsistenciaTarde:
+			credito += p.CreditoTarde
+		case models.AsistenciaJustificada:
+			credito += p.CreditoJustificada
+		case models.AsistenciaExcusada:
+			if !p.ExcusadaCuenta {
+				continue
+			}
+			credito += 100
+		}
+		total++
+	}
+	if total > 0 {
+		r.Porcentaje = math.Round(float64(credito)/float64(total)*10) / 10
+	}
+	return r
+}
+
+// GetSectionAttendanceReport arma el reporte de asistencia de una sección:
+// una fila por alumno inscrito (o dado de baja, hasta la baja) con su
+// estado en cada fecha de clase.
+func (s *DatabaseService) GetSectionAttendanceReport(seccionID int) ([]models.ReporteAlumno, error) {
+	clases, err := s.sectionAttendance(seccionID, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	reporte := []models.ReporteAlumno{}
+	for _, c := range clases {
+		if n := len(reporte); n == 0 || reporte[n-1].EstudianteID != c.AlumnoID {
+			reporte = append(reporte, models.ReporteAlumno{
+				EstudianteID: c.AlumnoID,
+				Estudiante:   c.Nombre,
+				FechaBaja:    c.FechaBaja,
+				Asistencia:   map[string]models.AsistenciaModulo{},
+			})
+		}
+		reporte[len(reporte)-1].Asistencia[c.clave()] = models.AsistenciaModulo{
+			Estado:   c.estado(),
+			AlumnoID: c.AlumnoID,
+			ModuloID: c.ModuloID,
+		}
+	}
+	return reporte, nil
+}
+
+// GetStudentAttendanceReport arma el reporte de un alumno en una sección.
+// Si la sección no tiene clases programadas, la asistencia viene vacía.
+func (s *DatabaseService) GetStudentAttendanceReport(seccionID, alumnoID int) (*models.ReporteEstudiante, error) {
+	clases, err := s.sectionAttendance(seccionID, alumnoID)
+	if err != nil {
+		return nil, err
+	}
+
+	reporte := &models.ReporteEstudiante{Asistencia: map[string]string{}}
+	if len(clases) == 0 {
+		reporte.Estudiante, err = s.GetStudentName(alumnoID)
+		return reporte, err
+	}
+	reporte.Estudiante = clases[0].Nombre
+	for _, c := range clases {
+		reporte.Asistencia[c.clave()] = c.estado()
+	}
+	return reporte, nil
+}
+
+// GetSectionAttendanceRecords es el reporte v2 de una sección: por alumno,
+// un registro tipado por cada clase programada (sin colapsar módulos del
+// mismo día) y su resumen según la política de la sección.
+func (s *DatabaseService) GetSectionAttendanceRecords(seccionID int) (*models.ReporteSeccionV2, error) {
+	politica, err := s.GetAttendancePolicy(seccionID)
+	if err != nil {
+		return nil, err
+	}
+	clases, err := s.sectionAttendance(seccionID, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	reporte := &models.ReporteSeccionV2{
+		Version:   models.VersionReporte,
+		SeccionID: seccionID,
+		Politica:  politica,
+		Alumnos:   []models.RegistrosAlumno{},
+	}
+	for desde := 0; desde < len(clases); {
+		hasta := desde
+		for hasta < len(clases) && clases[hasta].AlumnoID == clases[desde].AlumnoID {
+			hasta++
+		}
+		reporte.Alumnos = append(reporte.Alumnos, registrosAlumno(clases[desde:hasta], politica))
+		desde = hasta
+	}
+	return reporte, nil
+}
+
+// GetStudentAttendanceRecords es el reporte v2 de un alumno en una sección.
+func (s *DatabaseService) GetStudentAttendanceRecords(seccionID, alumnoID int) (*models.ReporteEstudianteV2, error) {
+	politica, err := s.GetAttendancePolicy(seccionID)
+	if err != nil {
+		return nil, err
+	}
+	clases, err := s.sectionAttendance(seccionID, alumnoID)
+	if err != nil {
+		return nil, err
+	}
+
+	reporte := &models.ReporteEstudianteV2{
+		Version:         models.VersionReporte,
+		SeccionID:       seccionID,
+		Politica:        politica,
+		RegistrosAlumno: registrosAlumno(clases, politica),
+	}
+	if len(clases) == 0 {
+		reporte.AlumnoID = alumnoID
+		reporte.Nombre, err = s.GetStudentName(alumnoID)
+	}
+	return reporte, err
+}
+
+// registrosAlumno arma los registros y el resumen de las clases de un mismo
+// alumno.
+func registrosAlumno(clases []claseAlumno, p models.PoliticaAsistencia) models.RegistrosAlumno {
+	a := models.RegistrosAlumno{
+		Registros: make([]models.RegistroAsistencia, 0, len(clases)),
+		Resumen:   resumir(clases, p),
+	}
+	if len(clases) > 0 {
+		a.AlumnoID, a.Nombre, a.FechaBaja = clases[0].AlumnoID, clases[0].Nombre, clases[0].FechaBaja
+	}
+	for _, c := range clases {
+		a.Registros = append(a.Registros, c.registro())
+	}
+	return a
+}
+
+// GetSectionModules lista las clases programadas (no canceladas) de la
+// sección, en orden de fecha y hora.
+func (s *DatabaseService) GetSectionModules(seccionID int) ([]models.ModuloClase, error) {
+	rows, err := s.db.Query(`
+		SELECT DISTINCT m.ID, m.Fecha, to_char(m.HoraInicio, 'HH24:MI'), to_char(m.HoraFin, 'HH24:MI'), m.HoraInicio
+		FROM ProgramacionClases pc
+		JOIN Modulos m ON m.ID = pc.ModuloID
+		WHERE pc.SeccionID = $1 AND pc.FechaCancelacion IS NULL
+		ORDER BY m.Fecha, m.HoraInicio, m.ID`, seccionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var modulos []models.ModuloClase
+	for rows.Next() {
+		var m models.ModuloClase
+		var fecha time.Time
+		var horaInicio string
+		if err := rows.Scan(&m.ModuloID, &fecha, &m.HoraInicio, &m.HoraFin, &horaInicio); err != nil {
+			return nil, err
+		}
+		m.Fecha = fecha.Format("2006-01-02")
+		modulos = append(modulos, m)
+	}
+	return modulos, rows.Err()
+}
+
+// StreamSectionAttendance llama a fn con los registros y el resumen de cada
+// alumno de la sección, uno a la vez y en orden de nombre, sin armar el
+// reporte completo en memoria.
+func (s *DatabaseService) StreamSectionAttendance(seccionID int, fn func(models.RegistrosAlumno) error) error {
+	politica, err := s.GetAttendancePolicy(seccionID)
+	if err != nil {
+		return err
+	}
+
+	var alumno []claseAlumno
+	err = s.eachSectionClass(seccionID, 0, func(c claseAlumno) error {
+		if len(alumno) > 0 && alumno[0].AlumnoID != c.AlumnoID {
+			if err := fn(registrosAlumno(alumno, politica)); err != nil {
+				return err
+			}
+			alumno = alumno[:0]
+		}
+		alumno = append(alumno, c)
+		return nil
+	})
+	if err != nil || len(alumno) == 0 {
+		return err
+	}
+	return fn(registrosAlumno(alumno, politica))
+}
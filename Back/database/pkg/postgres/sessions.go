@@ -0,0 +1,117 @@
+package postgres
+
+import (
+	"database/sql"
+	"time"
+
+	"mysqr/database/pkg/models"
+)
+
+const classSessionColumns = `
+	ID, SeccionID, ModuloID, ProfesorID, Estado, FechaApertura, CierreProgramado,
+	FechaCierre, CierreProgramado <= CURRENT_TIMESTAMP`
+
+func scanClassSession(row interface{ Scan(...interface{}) error }) (*models.SesionClase, error) {
+	var sesion models.SesionClase
+	var cierre sql.NullTime
+	err := row.Scan(&sesion.ID, &sesion.SeccionID, &sesion.ModuloID, &sesion.ProfesorID, &sesion.Estado,
+		&sesion.FechaApertura, &sesion.CierreProgramado, &cierre, &sesion.Vencida)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if cierre.Valid {
+		sesion.FechaCierre = &cierre.Time
+	}
+	return &sesion, nil
+}
+
+// cancelada es la condición SQL "la clase de la sesión fue cancelada", para
+// consultas sobre SesionesClase sin alias.
+const cancelada = `EXISTS (
+	SELECT 1 FROM ProgramacionClases pc
+	WHERE pc.SeccionID = SesionesClase.SeccionID AND pc.ModuloID = SesionesClase.ModuloID
+		AND pc.FechaCancelacion IS NOT NULL)`
+
+// OpenClassSession abre la sesión de la sección en el módulo, con cierre
+// programado al final del módulo más la tolerancia (clock.Despues), en la
+// zona de la institución. Si ya existía (en cualquier estado) la devuelve
+// tal cual. Devuelve nil si la clase está cancelada: no se abre ni se
+// devuelve su sesión.
+func (s *DatabaseService) OpenClassSession(profesorID, seccionID, moduloID int) (*models.SesionClase, error) {
+	return scanClassSession(s.db.QueryRow(`
+		INSERT INTO SesionesClase (SeccionID, ModuloID, ProfesorID, CierreProgramado)
+		SELECT $1, m.ID, $3, (m.Fecha + m.HoraFin + make_interval(secs => $5)) AT TIME ZONE $4
+		FROM Modulos m
+		WHERE m.ID = $2 AND NOT EXISTS (
+			SELECT 1 FROM ProgramacionClases pc
+			WHERE pc.SeccionID = $1 AND pc.ModuloID = m.ID AND pc.FechaCancelacion IS NOT NULL
+		)
+		ON CONFLICT (SeccionID, ModuloID) DO UPDATE SET SeccionID = EXCLUDED.SeccionID
+		RETURNING`+classSessionColumns, seccionID, moduloID, profesorID, s.clock.Zone(), s.clock.Despues.Seconds()))
+}
+
+// GetClassSession devuelve una sesión por ID, o nil si no existe.
+func (s *DatabaseService) GetClassSession(id int64) (*models.SesionClase, error) {
+	return scanClassSession(s.db.QueryRow(`SELECT`+classSessionColumns+` FROM SesionesClase WHERE ID = $1`, id))
+}
+
+// GetActiveClassSession devuelve la sesión abierta o pausada más reciente
+// del profesor que todavía no vence, o nil si no tiene ninguna. Así una
+// clase extendida más allá de su módulo sigue siendo "la clase actual".
+// Las sesiones de clases canceladas no cuentan.
+func (s *DatabaseService) GetActiveClassSession(profesorID int) (*models.SesionClase, error) {
+	return scanClassSession(s.db.QueryRow(`
+		SELECT`+classSessionColumns+`
+		FROM SesionesClase
+		WHERE ProfesorID = $1 AND Estado <> 'closed' AND CierreProgramado > CURRENT_TIMESTAMP
+			AND NOT `+cancelada+`
+		ORDER BY FechaApertura DESC
+		LIMIT 1`, profesorID))
+}
+
+// GetClassSessionByModule devuelve la sesión de la sección en el módulo, o
+// nil si todavía no se abrió.
+func (s *DatabaseService) GetClassSessionByModule(seccionID, moduloID int) (*models.SesionClase, error) {
+	return scanClassSession(s.db.QueryRow(`
+		SELECT`+classSessionColumns+` FROM SesionesClase WHERE SeccionID = $1 AND ModuloID = $2`,
+		seccionID, moduloID))
+}
+
+// ExtendClassSession corre el cierre programado d más allá de lo que sea
+// más tarde entre el cierre actual y ahora. Devuelve nil si la sesión ya
+// está cerrada.
+func (s *DatabaseService) ExtendClassSession(id int64, d time.Duration) (*models.SesionClase, error) {
+	return scanClassSession(s.db.QueryRow(`
+		UPDATE SesionesClase
+		SET CierreProgramado = GREATEST(CierreProgramado, CURRENT_TIMESTAMP) + make_interval(secs => $2)
+		WHERE ID = $1 AND Estado <> 'closed'
+		RETURNING`+classSessionColumns, id, d.Seconds()))
+}
+
+// PauseClassSession pausa una sesión abierta. Devuelve nil si no estaba abierta.
+func (s *DatabaseService) PauseClassSession(id int64) (*models.SesionClase, error) {
+	return scanClassSession(s.db.QueryRow(`
+		UPDATE SesionesClase SET Estado = 'paused'
+		WHERE ID = $1 AND Estado = 'open'
+		RETURNING`+classSessionColumns, id))
+}
+
+// ResumeClassSession reanuda una sesión pausada. Devuelve nil si no estaba pausada.
+func (s *DatabaseService) ResumeClassSession(id int64) (*models.SesionClase, error) {
+	return scanClassSession(s.db.QueryRow(`
+		UPDATE SesionesClase SET Estado = 'open'
+		WHERE ID = $1 AND Estado = 'paused'
+		RETURNING`+classSessionColumns, id))
+}
+
+// CloseClassSession cierra una sesión abierta o pausada. Devuelve nil si ya
+// estaba cerrada.
+func (s *DatabaseService) CloseClassSession(id int64) (*models.SesionClase, error) {
+	return scanClassSession(s.db.QueryRow(`
+		UPDATE SesionesClase SET Estado = 'closed', FechaCierre = CURRENT_TIMESTAMP
+		WHERE ID = $1 AND Estado <> 'closed'
+		RETURNING`+classSessionColumns, id))
+}
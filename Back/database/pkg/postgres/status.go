@@ -0,0 +1,64 @@
+package postgres
+
+import (
+	"database/sql"
+
+	"mysqr/database/pkg/models"
+)
+
+// GetAttendancePolicy devuelve la política de asistencia de la sección, o
+// la por defecto si no definió una.
+func (s *DatabaseService) GetAttendancePolicy(seccionID int) (models.PoliticaAsistencia, error) {
+	p := models.PoliticaAsistencia{SeccionID: seccionID}
+	err := s.db.QueryRow(`
+		SELECT MinutosGracia, CreditoTarde, CreditoJustificada, ExcusadaCuenta
+		FROM PoliticasAsistencia WHERE SeccionID = $1`, seccionID).
+		Scan(&p.MinutosGracia, &p.CreditoTarde, &p.CreditoJustificada, &p.ExcusadaCuenta)
+	if err == sql.ErrNoRows {
+		return models.PoliticaPorDefecto(seccionID), nil
+	}
+	return p, err
+}
+
+// SetAttendancePolicy crea o reemplaza la política de asistencia de la
+// sección. Los rangos los valida la tabla.
+func (s *DatabaseService) SetAttendancePolicy(p models.PoliticaAsistencia) error {
+	_, err := s.db.Exec(`
+		INSERT INTO PoliticasAsistencia (SeccionID, MinutosGracia, CreditoTarde, CreditoJustificada, ExcusadaCuenta)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (SeccionID) DO UPDATE SET
+			MinutosGracia = EXCLUDED.MinutosGracia,
+			CreditoTarde = EXCLUDED.CreditoTarde,
+			CreditoJustificada = EXCLUDED.CreditoJustificada,
+			ExcusadaCuenta = EXCLUDED.ExcusadaCuenta`,
+		p.SeccionID, p.MinutosGracia, p.CreditoTarde, p.CreditoJustificada, p.ExcusadaCuenta)
+	return err
+}
+
+// IsScheduled indica si la sección tiene clase programada (y no cancelada)
+// en ese módulo.
+func (s *DatabaseService) IsScheduled(seccionID, moduloID int) (bool, error) {
+	var exists bool
+	err := s.db.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM ProgramacionClases
+			WHERE SeccionID = $1 AND ModuloID = $2 AND FechaCancelacion IS NULL
+		)`, seccionID, moduloID).Scan(&exists)
+	return exists, err
+}
+
+// SetAttendanceStatus fija a mano el estado de un alumno en una clase, con
+// el motivo y quién lo cambió (AUTH.id). Si no había fila en Asistencia,
+// la crea como manual.
+func (s *DatabaseService) SetAttendanceStatus(alumnoID, seccionID, moduloID int, estado, motivo string, authID int) error {
+	_, err := s.db.Exec(`
+		INSERT INTO Asistencia (AlumnoID, SeccionID, ModuloID, FechaRegistro, ManualInd, Estado, Motivo, ModificadoPor, FechaModificacion)
+		VALUES ($1, $2, $3, CURRENT_TIMESTAMP, 1, $4, $5, $6, CURRENT_TIMESTAMP)
+		ON CONFLICT (SeccionID, AlumnoID, ModuloID) DO UPDATE SET
+			Estado = EXCLUDED.Estado,
+			Motivo = EXCLUDED.Motivo,
+			ModificadoPor = EXCLUDED.ModificadoPor,
+			FechaModificacion = EXCLUDED.FechaModificacion`,
+		alumnoID, seccionID, moduloID, estado, motivo, authID)
+	return err
+}
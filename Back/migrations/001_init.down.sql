@@ -0,0 +1,17 @@
+DROP FUNCTION IF EXISTS obtener_asistencia_estudiante_seccion(INT, INT);
+DROP FUNCTION IF EXISTS obtener_asistencia_por_seccion(INT);
+
+-- Las particiones de Asistencia y ReporteAsistencia caen con su tabla.
+DROP TABLE IF EXISTS AUTH;
+DROP TABLE IF EXISTS MACs;
+DROP TABLE IF EXISTS LogIn;
+DROP TABLE IF EXISTS QRGenerado;
+DROP TABLE IF EXISTS ReporteAsistencia;
+DROP TABLE IF EXISTS Asistencia;
+DROP TABLE IF EXISTS Inscripciones;
+DROP TABLE IF EXISTS ProgramacionClases;
+DROP TABLE IF EXISTS Modulos;
+DROP TABLE IF EXISTS Secciones;
+DROP TABLE IF EXISTS Asignaturas;
+DROP TABLE IF EXISTS Alumnos;
+DROP TABLE IF EXISTS Profesores;
@@ -0,0 +1,264 @@
+CREATE TABLE IF NOT EXISTS Profesores (
+                                          ID int PRIMARY KEY,
+                                          Rut int,
+                                          Nombre varchar,
+                                          Apellido varchar,
+                                          Rol int
+);
+
+CREATE TABLE IF NOT EXISTS Alumnos (
+    ID int PRIMARY KEY,
+    Rut int,
+    Nombre varchar,
+    NombreCompleto varchar,
+    Email varchar
+);
+
+CREATE TABLE IF NOT EXISTS Asignaturas (
+    ID SERIAL PRIMARY KEY,
+    Codigo VARCHAR(20) UNIQUE NOT NULL,
+    Nombre VARCHAR(100) NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS Secciones (
+                                         ID SERIAL PRIMARY KEY,
+                                         AsignaturaID int,
+                                         ProfesorID int,
+                                         Ubicacion varchar
+);
+
+CREATE TABLE IF NOT EXISTS Modulos (
+    ID int PRIMARY KEY,
+    Fecha date,
+    HoraInicio time,
+    HoraFin time
+);
+
+CREATE TABLE IF NOT EXISTS ProgramacionClases (
+    ID int PRIMARY KEY,
+    SeccionID int,
+    ModuloID int,
+    TipoSesion int
+);
+
+CREATE TABLE IF NOT EXISTS Inscripciones (
+    ID int PRIMARY KEY,
+    AlumnoID int,
+    SeccionID int
+);
+
+CREATE TABLE IF NOT EXISTS Asistencia (
+                                          ID bigserial,
+                                          AlumnoID int NOT NULL,
+                                          SeccionID int NOT NULL,
+                                          ModuloID int NOT NULL,
+                                          FechaRegistro timestamp NOT NULL,
+                                          ManualInd int NOT NULL,
+                                          PRIMARY KEY (ID, SeccionID)
+    ) PARTITION BY LIST (SeccionID);
+
+CREATE TABLE IF NOT EXISTS ReporteAsistencia (
+                                                 ID bigserial,
+                                                 AlumnoID int NOT NULL,
+                                                 SeccionID int NOT NULL,
+                                                 ModuloID int NOT NULL,
+                                                 EstadoSesion varchar,
+                                                 PRIMARY KEY (ID, SeccionID)
+    ) PARTITION BY LIST (SeccionID);
+
+CREATE TABLE IF NOT EXISTS QRGenerado (
+                                          ID int PRIMARY KEY,
+                                          ProfesorID int,
+                                          ModuloID int,
+                                          FechaRegistro timestamp,
+                                          MAC varchar
+);
+
+CREATE TABLE IF NOT EXISTS LogIn (
+                                     ID int PRIMARY KEY,
+                                     Rol varchar,
+                                     FechaRegistro timestamp,
+                                     Rut int,
+                                     MAC varchar
+);
+
+CREATE TABLE IF NOT EXISTS MACs (
+                                    ID int PRIMARY KEY,
+                                    AlumnoID int,
+                                    FechaRegistro timestamp,
+                                    MAC varchar
+);
+
+CREATE TABLE IF NOT EXISTS AUTH (
+                                    id SERIAL PRIMARY KEY,
+                                    username VARCHAR(50) UNIQUE NOT NULL,
+    password_hash VARCHAR(255) NOT NULL,
+    rol VARCHAR(20) NOT NULL,
+    ProfesorID int REFERENCES Profesores(ID),
+    AlumnoID int REFERENCES Alumnos(ID),
+    Rut int NOT NULL,
+    CONSTRAINT check_rol_id CHECK (
+(rol = 'profesor' AND ProfesorID IS NOT NULL AND AlumnoID IS NULL) OR
+(rol = 'alumno' AND AlumnoID IS NOT NULL AND ProfesorID IS NULL)
+    )
+    );
+
+ALTER TABLE Secciones ADD FOREIGN KEY (AsignaturaID) REFERENCES Asignaturas(ID);
+ALTER TABLE Secciones ADD FOREIGN KEY (ProfesorID) REFERENCES Profesores(ID);
+ALTER TABLE ProgramacionClases ADD FOREIGN KEY (SeccionID) REFERENCES Secciones(ID);
+ALTER TABLE ProgramacionClases ADD FOREIGN KEY (ModuloID) REFERENCES Modulos(ID);
+ALTER TABLE Inscripciones ADD FOREIGN KEY (AlumnoID) REFERENCES Alumnos(ID);
+ALTER TABLE Inscripciones ADD FOREIGN KEY (SeccionID) REFERENCES Secciones(ID);
+ALTER TABLE Asistencia ADD FOREIGN KEY (AlumnoID) REFERENCES Alumnos(ID);
+ALTER TABLE Asistencia ADD FOREIGN KEY (SeccionID) REFERENCES Secciones(ID);
+ALTER TABLE Asistencia ADD FOREIGN KEY (ModuloID) REFERENCES Modulos(ID);
+ALTER TABLE QRGenerado ADD FOREIGN KEY (ProfesorID) REFERENCES Profesores(ID);
+ALTER TABLE QRGenerado ADD FOREIGN KEY (ModuloID) REFERENCES Modulos(ID);
+ALTER TABLE ReporteAsistencia ADD FOREIGN KEY (AlumnoID) REFERENCES Alumnos(ID);
+ALTER TABLE ReporteAsistencia ADD FOREIGN KEY (SeccionID) REFERENCES Secciones(ID);
+ALTER TABLE ReporteAsistencia ADD FOREIGN KEY (ModuloID) REFERENCES Modulos(ID);
+ALTER TABLE MACs ADD FOREIGN KEY (AlumnoID) REFERENCES Alumnos(ID);
+
+CREATE OR REPLACE FUNCTION obtener_asistencia_por_seccion(seccion_id_input INT)
+RETURNS JSONB AS $$
+DECLARE
+reporte JSONB;
+BEGIN
+
+    -- paso 0: borrar registros de ReporteAsistencia para la seccion
+DELETE FROM ReporteAsistencia WHERE SeccionID = seccion_id_input;
+
+-- Paso 1: Insertar en ReporteAsistencia las sesiones programadas
+INSERT INTO ReporteAsistencia (AlumnoID, SeccionID, ModuloID, EstadoSesion)
+SELECT
+    i.AlumnoID,
+    seccion_id_input,
+    pc.ModuloID,
+    'ausente'
+FROM Inscripciones i
+         JOIN ProgramacionClases pc ON pc.SeccionID = seccion_id_input
+         LEFT JOIN ReporteAsistencia ra ON ra.AlumnoID = i.AlumnoID
+    AND ra.ModuloID = pc.ModuloID
+    AND ra.SeccionID = seccion_id_input
+WHERE i.SeccionID = seccion_id_input
+  AND ra.ID IS NULL;
+
+-- Paso 2: Actualizar registros existentes en ReporteAsistencia según Asistencia real
+UPDATE ReporteAsistencia ra
+SET EstadoSesion = 'presente'
+    FROM Asistencia a
+WHERE ra.AlumnoID = a.AlumnoID
+  AND ra.SeccionID = a.SeccionID
+  AND ra.ModuloID = a.ModuloID
+  AND ra.SeccionID = seccion_id_input;
+
+-- Paso 3: Generar el JSON final agrupado
+SELECT jsonb_agg(estudiante_data) INTO reporte
+FROM (
+         SELECT
+             a.ID as estudiante_id,
+             a.NombreCompleto AS estudiante,
+             jsonb_object_agg(
+                 to_char(m.Fecha, 'MM-DD'),
+                 jsonb_build_object(
+                     'estado', CASE
+                                  WHEN ra.EstadoSesion ILIKE 'presente' THEN '🟢'
+                                  ELSE '🔴'
+                     END,
+                     'alumno_id', a.ID,
+                     'modulo_id', m.ID
+                 ) ORDER BY m.Fecha
+             ) AS asistencia
+         FROM ReporteAsistencia ra
+                  JOIN Alumnos a ON a.ID = ra.AlumnoID
+                  JOIN Modulos m ON m.ID = ra.ModuloID
+         WHERE ra.SeccionID = seccion_id_input
+         GROUP BY a.ID, a.NombreCompleto
+         ORDER BY a.NombreCompleto
+     ) AS estudiante_data;
+
+RETURN reporte;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION obtener_asistencia_estudiante_seccion(seccion_id_input INT, alumno_id_input INT)
+RETURNS JSONB AS $$
+DECLARE
+reporte JSONB;
+    estudiante_existe BOOLEAN;
+    seccion_existe BOOLEAN;
+BEGIN
+    -- Verificar que el estudiante existe
+SELECT EXISTS(SELECT 1 FROM Alumnos WHERE ID = alumno_id_input) INTO estudiante_existe;
+IF NOT estudiante_existe THEN
+        RAISE EXCEPTION 'El estudiante con ID % no existe', alumno_id_input;
+END IF;
+
+    -- Verificar que la sección existe
+SELECT EXISTS(SELECT 1 FROM Secciones WHERE ID = seccion_id_input) INTO seccion_existe;
+IF NOT seccion_existe THEN
+        RAISE EXCEPTION 'La sección con ID % no existe', seccion_id_input;
+END IF;
+
+    -- paso 0: borrar registros de ReporteAsistencia para la seccion y alumno
+DELETE FROM ReporteAsistencia
+WHERE SeccionID = seccion_id_input
+  AND AlumnoID = alumno_id_input;
+
+-- Paso 1: Insertar en ReporteAsistencia las sesiones programadas
+INSERT INTO ReporteAsistencia (AlumnoID, SeccionID, ModuloID, EstadoSesion)
+SELECT
+    alumno_id_input,
+    seccion_id_input,
+    pc.ModuloID,
+    'ausente'
+FROM ProgramacionClases pc
+         LEFT JOIN ReporteAsistencia ra ON ra.AlumnoID = alumno_id_input
+    AND ra.ModuloID = pc.ModuloID
+    AND ra.SeccionID = seccion_id_input
+WHERE pc.SeccionID = seccion_id_input
+  AND ra.ID IS NULL;
+
+-- Paso 2: Actualizar registros existentes en ReporteAsistencia según Asistencia real
+UPDATE ReporteAsistencia ra
+SET EstadoSesion = 'presente'
+    FROM Asistencia a
+WHERE ra.AlumnoID = a.AlumnoID
+  AND ra.SeccionID = a.SeccionID
+  AND ra.ModuloID = a.ModuloID
+  AND ra.SeccionID = seccion_id_input
+  AND ra.AlumnoID = alumno_id_input;
+
+-- Paso 3: Generar el JSON final
+SELECT jsonb_build_object(
+               'estudiante', a.NombreCompleto,
+               'asistencia', COALESCE(
+                       jsonb_object_agg(
+                               to_char(m.Fecha, 'MM-DD'),
+                               CASE
+                                   WHEN ra.EstadoSesion ILIKE 'presente' THEN '🟢'
+                                   ELSE '🔴'
+                                   END
+                                   ORDER BY m.Fecha
+                       ),
+                       '{}'::jsonb
+                             )
+       ) INTO reporte
+FROM ReporteAsistencia ra
+         JOIN Alumnos a ON a.ID = ra.AlumnoID
+         JOIN Modulos m ON m.ID = ra.ModuloID
+WHERE ra.SeccionID = seccion_id_input
+  AND ra.AlumnoID = alumno_id_input
+GROUP BY a.ID, a.NombreCompleto;
+
+-- Si no hay datos, devolver un objeto con asistencia vacía
+IF reporte IS NULL THEN
+SELECT jsonb_build_object(
+               'estudiante', (SELECT NombreCompleto FROM Alumnos WHERE ID = alumno_id_input),
+               'asistencia', '{}'::jsonb
+       ) INTO reporte;
+END IF;
+
+RETURN reporte;
+END;
+$$ LANGUAGE plpgsql;
@@ -0,0 +1,6 @@
+-- Falla si quedan usuarios admin: hay que borrarlos antes de bajar.
+ALTER TABLE AUTH DROP CONSTRAINT IF EXISTS check_rol_id;
+ALTER TABLE AUTH ADD CONSTRAINT check_rol_id CHECK (
+    (rol = 'profesor' AND ProfesorID IS NOT NULL AND AlumnoID IS NULL) OR
+    (rol = 'alumno' AND AlumnoID IS NOT NULL AND ProfesorID IS NULL)
+);
@@ -0,0 +1,8 @@
+-- Rol "admin" para los endpoints de administración (desbloqueo de cuentas,
+-- auditoría). No está ligado a un profesor ni a un alumno.
+ALTER TABLE AUTH DROP CONSTRAINT IF EXISTS check_rol_id;
+ALTER TABLE AUTH ADD CONSTRAINT check_rol_id CHECK (
+    (rol = 'profesor' AND ProfesorID IS NOT NULL AND AlumnoID IS NULL) OR
+    (rol = 'alumno' AND AlumnoID IS NOT NULL AND ProfesorID IS NULL) OR
+    (rol = 'admin' AND ProfesorID IS NULL AND AlumnoID IS NULL)
+);
@@ -0,0 +1,13 @@
+DROP INDEX IF EXISTS idx_login_rut_fecha;
+DROP INDEX IF EXISTS idx_login_username_fecha;
+DROP INDEX IF EXISTS idx_login_fecha;
+
+ALTER TABLE LogIn DROP COLUMN IF EXISTS DispositivoID;
+ALTER TABLE LogIn DROP COLUMN IF EXISTS UserAgent;
+ALTER TABLE LogIn DROP COLUMN IF EXISTS IP;
+ALTER TABLE LogIn DROP COLUMN IF EXISTS Motivo;
+ALTER TABLE LogIn DROP COLUMN IF EXISTS Exitoso;
+ALTER TABLE LogIn DROP COLUMN IF EXISTS Username;
+
+ALTER TABLE LogIn ALTER COLUMN ID DROP DEFAULT;
+DROP SEQUENCE IF EXISTS login_id_seq;
@@ -0,0 +1,18 @@
+-- LogIn pasa a ser el registro de auditoría de todos los intentos de login
+-- (exitosos y fallidos). El ID no tenía default: se le agrega una secuencia.
+CREATE SEQUENCE IF NOT EXISTS login_id_seq OWNED BY LogIn.ID;
+SELECT setval('login_id_seq', COALESCE((SELECT MAX(ID) FROM LogIn), 0) + 1, false);
+ALTER TABLE LogIn ALTER COLUMN ID SET DEFAULT nextval('login_id_seq');
+
+ALTER TABLE LogIn ADD COLUMN IF NOT EXISTS Username varchar(50);
+ALTER TABLE LogIn ADD COLUMN IF NOT EXISTS Exitoso boolean NOT NULL DEFAULT true;
+ALTER TABLE LogIn ADD COLUMN IF NOT EXISTS Motivo varchar(30);
+ALTER TABLE LogIn ADD COLUMN IF NOT EXISTS IP varchar(45);
+ALTER TABLE LogIn ADD COLUMN IF NOT EXISTS UserAgent text;
+-- Identificador estable que genera la app por instalación (MAC queda por
+-- compatibilidad con las filas antiguas).
+ALTER TABLE LogIn ADD COLUMN IF NOT EXISTS DispositivoID varchar(100);
+
+CREATE INDEX IF NOT EXISTS idx_login_fecha ON LogIn (FechaRegistro);
+CREATE INDEX IF NOT EXISTS idx_login_username_fecha ON LogIn (Username, FechaRegistro);
+CREATE INDEX IF NOT EXISTS idx_login_rut_fecha ON LogIn (Rut, FechaRegistro);
@@ -0,0 +1,17 @@
+-- Sesión de clase: el período en que el profesor tiene abierta la toma de
+-- asistencia de un módulo. Hay a lo más una por sección y módulo; una vez
+-- cerrada no se reabre. CierreProgramado parte en el fin del módulo y se
+-- corre con "extender"; pasado ese momento ya no se aceptan escaneos.
+CREATE TABLE IF NOT EXISTS SesionesClase (
+    ID bigserial PRIMARY KEY,
+    SeccionID int NOT NULL REFERENCES Secciones(ID),
+    ModuloID int NOT NULL REFERENCES Modulos(ID),
+    ProfesorID int NOT NULL REFERENCES Profesores(ID),
+    Estado varchar(10) NOT NULL DEFAULT 'open' CHECK (Estado IN ('open', 'paused', 'closed')),
+    FechaApertura timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CierreProgramado timestamp NOT NULL,
+    FechaCierre timestamp,
+    UNIQUE (SeccionID, ModuloID)
+);
+
+CREATE INDEX IF NOT EXISTS idx_sesionesclase_profesor_estado ON SesionesClase (ProfesorID, Estado);
@@ -0,0 +1,5 @@
+ALTER TABLE Inscripciones ALTER COLUMN ID DROP DEFAULT;
+DROP SEQUENCE IF EXISTS inscripciones_id_seq;
+
+ALTER TABLE ProgramacionClases ALTER COLUMN ID DROP DEFAULT;
+DROP SEQUENCE IF EXISTS programacionclases_id_seq;
@@ -0,0 +1,10 @@
+-- ProgramacionClases e Inscripciones se crearon con ID int sin default, así
+-- que los INSERT que no pasan ID (carga masiva, alta de alumnos) fallaban.
+-- Igual que con LogIn en 003, se les agrega una secuencia.
+CREATE SEQUENCE IF NOT EXISTS programacionclases_id_seq OWNED BY ProgramacionClases.ID;
+SELECT setval('programacionclases_id_seq', COALESCE((SELECT MAX(ID) FROM ProgramacionClases), 0) + 1, false);
+ALTER TABLE ProgramacionClases ALTER COLUMN ID SET DEFAULT nextval('programacionclases_id_seq');
+
+CREATE SEQUENCE IF NOT EXISTS inscripciones_id_seq OWNED BY Inscripciones.ID;
+SELECT setval('inscripciones_id_seq', COALESCE((SELECT MAX(ID) FROM Inscripciones), 0) + 1, false);
+ALTER TABLE Inscripciones ALTER COLUMN ID SET DEFAULT nextval('inscripciones_id_seq');
@@ -0,0 +1,7 @@
+-- Las particiones por sección creadas por 006 quedan: son válidas sin
+-- ella. Las filas que hayan caído en las DEFAULT se pierden.
+DROP TABLE IF EXISTS reporte_asistencia_default;
+DROP TABLE IF EXISTS asistencia_default;
+DROP FUNCTION IF EXISTS archivar_particiones_seccion(INT);
+DROP FUNCTION IF EXISTS crear_particiones_seccion(INT);
+DROP SCHEMA IF EXISTS archivo;
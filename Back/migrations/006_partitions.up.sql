@@ -0,0 +1,86 @@
+-- Asistencia y ReporteAsistencia están particionadas por SeccionID y las
+-- particiones se creaban a mano, así que una sección nueva fallaba al
+-- insertar ("no partition of relation found for row").
+--
+-- crear_particiones_seccion crea las particiones de una sección (la llama
+-- la carga masiva en la misma transacción que crea la sección). Si la
+-- sección ya tenía filas en la partición DEFAULT, las mueve a la nueva.
+-- Una partición archivada (esquema archivo) no se vuelve a crear.
+CREATE SCHEMA IF NOT EXISTS archivo;
+
+CREATE OR REPLACE FUNCTION crear_particiones_seccion(seccion_id_input INT)
+RETURNS INT AS $$
+DECLARE
+    padres text[] := ARRAY['asistencia', 'reporteasistencia'];
+    prefijos text[] := ARRAY['asistencia', 'reporte_asistencia'];
+    particion text;
+    creadas int := 0;
+BEGIN
+    -- Serializa contra otra creación o un archivado en paralelo.
+    PERFORM pg_advisory_xact_lock(hashtext('particiones_asistencia'));
+
+    FOR i IN 1 .. array_length(padres, 1) LOOP
+        particion := prefijos[i] || '_' || seccion_id_input;
+        IF to_regclass('public.' || particion) IS NOT NULL
+            OR to_regclass('archivo.' || particion) IS NOT NULL THEN
+            CONTINUE;
+        END IF;
+
+        EXECUTE format('CREATE TABLE public.%I (LIKE public.%I INCLUDING DEFAULTS INCLUDING CONSTRAINTS)',
+            particion, padres[i]);
+        EXECUTE format('WITH movidas AS (DELETE FROM public.%I WHERE SeccionID = $1 RETURNING *)
+            INSERT INTO public.%I SELECT * FROM movidas', prefijos[i] || '_default', particion)
+            USING seccion_id_input;
+        EXECUTE format('ALTER TABLE public.%I ATTACH PARTITION public.%I FOR VALUES IN (%s)',
+            padres[i], particion, seccion_id_input);
+        creadas := creadas + 1;
+    END LOOP;
+
+    RETURN creadas;
+END;
+$$ LANGUAGE plpgsql;
+
+-- archivar_particiones_seccion separa las particiones de una sección
+-- terminada y las deja en el esquema archivo, con sus filas intactas.
+CREATE OR REPLACE FUNCTION archivar_particiones_seccion(seccion_id_input INT)
+RETURNS INT AS $$
+DECLARE
+    padres text[] := ARRAY['asistencia', 'reporteasistencia'];
+    prefijos text[] := ARRAY['asistencia', 'reporte_asistencia'];
+    particion text;
+    archivadas int := 0;
+BEGIN
+    PERFORM pg_advisory_xact_lock(hashtext('particiones_asistencia'));
+
+    FOR i IN 1 .. array_length(padres, 1) LOOP
+        particion := prefijos[i] || '_' || seccion_id_input;
+        IF to_regclass('public.' || particion) IS NULL THEN
+            CONTINUE;
+        END IF;
+
+        EXECUTE format('ALTER TABLE public.%I DETACH PARTITION public.%I', padres[i], particion);
+        EXECUTE format('ALTER TABLE public.%I SET SCHEMA archivo', particion);
+        archivadas := archivadas + 1;
+    END LOOP;
+
+    RETURN archivadas;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Particiones de las secciones que ya existían (antes de la DEFAULT, así
+-- que no hay filas que mover).
+DO $$
+DECLARE
+    s int;
+BEGIN
+    FOR s IN SELECT ID FROM Secciones ORDER BY ID LOOP
+        PERFORM crear_particiones_seccion(s);
+    END LOOP;
+END;
+$$;
+
+-- Red de seguridad: si una sección queda sin partición, sus filas caen acá
+-- en vez de fallar. `database partitions check` las reporta y
+-- `database partitions repair` las mueve a su partición.
+CREATE TABLE IF NOT EXISTS asistencia_default PARTITION OF Asistencia DEFAULT;
+CREATE TABLE IF NOT EXISTS reporte_asistencia_default PARTITION OF ReporteAsistencia DEFAULT;
@@ -0,0 +1,223 @@
+-- Vuelve a crear ReporteAsistencia (vacía: se llenaba en cada GET), sus
+-- particiones y las funciones de reporte de 001 y de particiones de 006.
+CREATE TABLE IF NOT EXISTS ReporteAsistencia (
+    ID bigserial,
+    AlumnoID int NOT NULL REFERENCES Alumnos(ID),
+    SeccionID int NOT NULL REFERENCES Secciones(ID),
+    ModuloID int NOT NULL REFERENCES Modulos(ID),
+    EstadoSesion varchar,
+    PRIMARY KEY (ID, SeccionID)
+) PARTITION BY LIST (SeccionID);
+
+CREATE TABLE IF NOT EXISTS reporte_asistencia_default PARTITION OF ReporteAsistencia DEFAULT;
+
+CREATE OR REPLACE FUNCTION crear_particiones_seccion(seccion_id_input INT)
+RETURNS INT AS $$
+DECLARE
+    padres text[] := ARRAY['asistencia', 'reporteasistencia'];
+    prefijos text[] := ARRAY['asistencia', 'reporte_asistencia'];
+    particion text;
+    creadas int := 0;
+BEGIN
+    -- Serializa contra otra creación o un archivado en paralelo.
+    PERFORM pg_advisory_xact_lock(hashtext('particiones_asistencia'));
+
+    FOR i IN 1 .. array_length(padres, 1) LOOP
+        particion := prefijos[i] || '_' || seccion_id_input;
+        IF to_regclass('public.' || particion) IS NOT NULL
+            OR to_regclass('archivo.' || particion) IS NOT NULL THEN
+            CONTINUE;
+        END IF;
+
+        EXECUTE format('CREATE TABLE public.%I (LIKE public.%I INCLUDING DEFAULTS INCLUDING CONSTRAINTS)',
+            particion, padres[i]);
+        EXECUTE format('WITH movidas AS (DELETE FROM public.%I WHERE SeccionID = $1 RETURNING *)
+            INSERT INTO public.%I SELECT * FROM movidas', prefijos[i] || '_default', particion)
+            USING seccion_id_input;
+        EXECUTE format('ALTER TABLE public.%I ATTACH PARTITION public.%I FOR VALUES IN (%s)',
+            padres[i], particion, seccion_id_input);
+        creadas := creadas + 1;
+    END LOOP;
+
+    RETURN creadas;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION archivar_particiones_seccion(seccion_id_input INT)
+RETURNS INT AS $$
+DECLARE
+    padres text[] := ARRAY['asistencia', 'reporteasistencia'];
+    prefijos text[] := ARRAY['asistencia', 'reporte_asistencia'];
+    particion text;
+    archivadas int := 0;
+BEGIN
+    PERFORM pg_advisory_xact_lock(hashtext('particiones_asistencia'));
+
+    FOR i IN 1 .. array_length(padres, 1) LOOP
+        particion := prefijos[i] || '_' || seccion_id_input;
+        IF to_regclass('public.' || particion) IS NULL THEN
+            CONTINUE;
+        END IF;
+
+        EXECUTE format('ALTER TABLE public.%I DETACH PARTITION public.%I', padres[i], particion);
+        EXECUTE format('ALTER TABLE public.%I SET SCHEMA archivo', particion);
+        archivadas := archivadas + 1;
+    END LOOP;
+
+    RETURN archivadas;
+END;
+$$ LANGUAGE plpgsql;
+
+DO $$
+DECLARE
+    s int;
+BEGIN
+    FOR s IN SELECT ID FROM Secciones ORDER BY ID LOOP
+        PERFORM crear_particiones_seccion(s);
+    END LOOP;
+END;
+$$;
+
+CREATE OR REPLACE FUNCTION obtener_asistencia_por_seccion(seccion_id_input INT)
+RETURNS JSONB AS $$
+DECLARE
+reporte JSONB;
+BEGIN
+
+    -- paso 0: borrar registros de ReporteAsistencia para la seccion
+DELETE FROM ReporteAsistencia WHERE SeccionID = seccion_id_input;
+
+-- Paso 1: Insertar en ReporteAsistencia las sesiones programadas
+INSERT INTO ReporteAsistencia (AlumnoID, SeccionID, ModuloID, EstadoSesion)
+SELECT
+    i.AlumnoID,
+    seccion_id_input,
+    pc.ModuloID,
+    'ausente'
+FROM Inscripciones i
+         JOIN ProgramacionClases pc ON pc.SeccionID = seccion_id_input
+         LEFT JOIN ReporteAsistencia ra ON ra.AlumnoID = i.AlumnoID
+    AND ra.ModuloID = pc.ModuloID
+    AND ra.SeccionID = seccion_id_input
+WHERE i.SeccionID = seccion_id_input
+  AND ra.ID IS NULL;
+
+-- Paso 2: Actualizar registros existentes en ReporteAsistencia según Asistencia real
+UPDATE ReporteAsistencia ra
+SET EstadoSesion = 'presente'
+    FROM Asistencia a
+WHERE ra.AlumnoID = a.AlumnoID
+  AND ra.SeccionID = a.SeccionID
+  AND ra.ModuloID = a.ModuloID
+  AND ra.SeccionID = seccion_id_input;
+
+-- Paso 3: Generar el JSON final agrupado
+SELECT jsonb_agg(estudiante_data) INTO reporte
+FROM (
+         SELECT
+             a.ID as estudiante_id,
+             a.NombreCompleto AS estudiante,
+             jsonb_object_agg(
+                 to_char(m.Fecha, 'MM-DD'),
+                 jsonb_build_object(
+                     'estado', CASE
+                                  WHEN ra.EstadoSesion ILIKE 'presente' THEN '🟢'
+                                  ELSE '🔴'
+                     END,
+                     'alumno_id', a.ID,
+                     'modulo_id', m.ID
+                 ) ORDER BY m.Fecha
+             ) AS asistencia
+         FROM ReporteAsistencia ra
+                  JOIN Alumnos a ON a.ID = ra.AlumnoID
+                  JOIN Modulos m ON m.ID = ra.ModuloID
+         WHERE ra.SeccionID = seccion_id_input
+         GROUP BY a.ID, a.NombreCompleto
+         ORDER BY a.NombreCompleto
+     ) AS estudiante_data;
+
+RETURN reporte;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION obtener_asistencia_estudiante_seccion(seccion_id_input INT, alumno_id_input INT)
+RETURNS JSONB AS $$
+DECLARE
+reporte JSONB;
+    estudiante_existe BOOLEAN;
+    seccion_existe BOOLEAN;
+BEGIN
+    -- Verificar que el estudiante existe
+SELECT EXISTS(SELECT 1 FROM Alumnos WHERE ID = alumno_id_input) INTO estudiante_existe;
+IF NOT estudiante_existe THEN
+        RAISE EXCEPTION 'El estudiante con ID % no existe', alumno_id_input;
+END IF;
+
+    -- Verificar que la sección existe
+SELECT EXISTS(SELECT 1 FROM Secciones WHERE ID = seccion_id_input) INTO seccion_existe;
+IF NOT seccion_existe THEN
+        RAISE EXCEPTION 'La sección con ID % no existe', seccion_id_input;
+END IF;
+
+    -- paso 0: borrar registros de ReporteAsistencia para la seccion y alumno
+DELETE FROM ReporteAsistencia
+WHERE SeccionID = seccion_id_input
+  AND AlumnoID = alumno_id_input;
+
+-- Paso 1: Insertar en ReporteAsistencia las sesiones programadas
+INSERT INTO ReporteAsistencia (AlumnoID, SeccionID, ModuloID, EstadoSesion)
+SELECT
+    alumno_id_input,
+    seccion_id_input,
+    pc.ModuloID,
+    'ausente'
+FROM ProgramacionClases pc
+         LEFT JOIN ReporteAsistencia ra ON ra.AlumnoID = alumno_id_input
+    AND ra.ModuloID = pc.ModuloID
+    AND ra.SeccionID = seccion_id_input
+WHERE pc.SeccionID = seccion_id_input
+  AND ra.ID IS NULL;
+
+-- Paso 2: Actualizar registros existentes en ReporteAsistencia según Asistencia real
+UPDATE ReporteAsistencia ra
+SET EstadoSesion = 'presente'
+    FROM Asistencia a
+WHERE ra.AlumnoID = a.AlumnoID
+  AND ra.SeccionID = a.SeccionID
+  AND ra.ModuloID = a.ModuloID
+  AND ra.SeccionID = seccion_id_input
+  AND ra.AlumnoID = alumno_id_input;
+
+-- Paso 3: Generar el JSON final
+SELECT jsonb_build_object(
+               'estudiante', a.NombreCompleto,
+               'asistencia', COALESCE(
+                       jsonb_object_agg(
+                               to_char(m.Fecha, 'MM-DD'),
+                               CASE
+                                   WHEN ra.EstadoSesion ILIKE 'presente' THEN '🟢'
+                                   ELSE '🔴'
+                                   END
+                                   ORDER BY m.Fecha
+                       ),
+                       '{}'::jsonb
+                             )
+       ) INTO reporte
+FROM ReporteAsistencia ra
+         JOIN Alumnos a ON a.ID = ra.AlumnoID
+         JOIN Modulos m ON m.ID = ra.ModuloID
+WHERE ra.SeccionID = seccion_id_input
+  AND ra.AlumnoID = alumno_id_input
+GROUP BY a.ID, a.NombreCompleto;
+
+-- Si no hay datos, devolver un objeto con asistencia vacía
+IF reporte IS NULL THEN
+SELECT jsonb_build_object(
+               'estudiante', (SELECT NombreCompleto FROM Alumnos WHERE ID = alumno_id_input),
+               'asistencia', '{}'::jsonb
+       ) INTO reporte;
+END IF;
+
+RETURN reporte;
+END;
+$$ LANGUAGE plpgsql;
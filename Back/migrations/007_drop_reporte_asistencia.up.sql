@@ -0,0 +1,60 @@
+-- El reporte de asistencia se calcula leyendo ProgramacionClases,
+-- Inscripciones y Asistencia (postgres.GetSectionAttendanceReport). Las
+-- funciones viejas borraban y reinsertaban ReporteAsistencia en cada GET, así
+-- que dos profesores mirando el mismo reporte competían por escribir. La
+-- tabla solo tenía datos derivados: se elimina junto con sus particiones.
+DROP FUNCTION IF EXISTS obtener_asistencia_estudiante_seccion(INT, INT);
+DROP FUNCTION IF EXISTS obtener_asistencia_por_seccion(INT);
+DROP TABLE IF EXISTS ReporteAsistencia;
+
+DO $$
+DECLARE
+    t text;
+BEGIN
+    FOR t IN SELECT tablename FROM pg_tables
+             WHERE schemaname = 'archivo' AND tablename LIKE 'reporte\_asistencia\_%' LOOP
+        EXECUTE format('DROP TABLE archivo.%I', t);
+    END LOOP;
+END;
+$$;
+
+-- Las funciones de particiones de 006, ahora solo para Asistencia.
+CREATE OR REPLACE FUNCTION crear_particiones_seccion(seccion_id_input INT)
+RETURNS INT AS $$
+DECLARE
+    particion text := 'asistencia_' || seccion_id_input;
+BEGIN
+    PERFORM pg_advisory_xact_lock(hashtext('particiones_asistencia'));
+
+    IF to_regclass('public.' || particion) IS NOT NULL
+        OR to_regclass('archivo.' || particion) IS NOT NULL THEN
+        RETURN 0;
+    END IF;
+
+    EXECUTE format('CREATE TABLE public.%I (LIKE public.asistencia INCLUDING DEFAULTS INCLUDING CONSTRAINTS)',
+        particion);
+    EXECUTE format('WITH movidas AS (DELETE FROM public.asistencia_default WHERE SeccionID = $1 RETURNING *)
+        INSERT INTO public.%I SELECT * FROM movidas', particion)
+        USING seccion_id_input;
+    EXECUTE format('ALTER TABLE public.asistencia ATTACH PARTITION public.%I FOR VALUES IN (%s)',
+        particion, seccion_id_input);
+    RETURN 1;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION archivar_particiones_seccion(seccion_id_input INT)
+RETURNS INT AS $$
+DECLARE
+    particion text := 'asistencia_' || seccion_id_input;
+BEGIN
+    PERFORM pg_advisory_xact_lock(hashtext('particiones_asistencia'));
+
+    IF to_regclass('public.' || particion) IS NULL THEN
+        RETURN 0;
+    END IF;
+
+    EXECUTE format('ALTER TABLE public.asistencia DETACH PARTITION public.%I', particion);
+    EXECUTE format('ALTER TABLE public.%I SET SCHEMA archivo', particion);
+    RETURN 1;
+END;
+$$ LANGUAGE plpgsql;
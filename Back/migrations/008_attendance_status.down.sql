@@ -0,0 +1,10 @@
+-- Las filas que no eran de asistencia (justified, excused, absent) se
+-- borran: sin Estado se leerían como presentes.
+DROP TABLE IF EXISTS PoliticasAsistencia;
+DELETE FROM Asistencia WHERE Estado NOT IN ('present', 'late');
+ALTER TABLE Asistencia DROP COLUMN IF EXISTS FechaModificacion;
+ALTER TABLE Asistencia DROP COLUMN IF EXISTS ModificadoPor;
+ALTER TABLE Asistencia DROP COLUMN IF EXISTS Motivo;
+ALTER TABLE Asistencia DROP CONSTRAINT IF EXISTS asistencia_estado_check;
+ALTER TABLE Asistencia DROP COLUMN IF EXISTS Estado;
+DROP INDEX IF EXISTS uq_asistencia_clase;
@@ -0,0 +1,39 @@
+-- Estados de asistencia. Antes una fila en Asistencia era "presente" y su
+-- ausencia "ausente"; ahora cada fila lleva su Estado:
+--   present    escaneó dentro del período de gracia (o alta manual)
+--   late       escaneó pasado HoraInicio + MinutosGracia de la sección
+--   justified  inasistencia justificada por el profesor
+--   excused    eximido de la clase
+--   absent     ausente marcado explícitamente (sin fila también es ausente)
+-- Motivo, ModificadoPor (AUTH.id) y FechaModificacion quedan cuando el
+-- profesor cambia el estado a mano.
+
+-- Hasta ahora podía haber más de una fila por clase (QR + manual). Se deja
+-- una sola, prefiriendo la del QR y después la más antigua.
+DELETE FROM Asistencia a
+USING Asistencia b
+WHERE a.SeccionID = b.SeccionID
+  AND a.AlumnoID = b.AlumnoID
+  AND a.ModuloID = b.ModuloID
+  AND (a.ManualInd, a.FechaRegistro, a.ID) > (b.ManualInd, b.FechaRegistro, b.ID);
+
+CREATE UNIQUE INDEX IF NOT EXISTS uq_asistencia_clase ON Asistencia (SeccionID, AlumnoID, ModuloID);
+
+ALTER TABLE Asistencia ADD COLUMN IF NOT EXISTS Estado varchar(10) NOT NULL DEFAULT 'present';
+ALTER TABLE Asistencia ADD CONSTRAINT asistencia_estado_check
+    CHECK (Estado IN ('present', 'late', 'justified', 'excused', 'absent'));
+ALTER TABLE Asistencia ADD COLUMN IF NOT EXISTS Motivo text;
+ALTER TABLE Asistencia ADD COLUMN IF NOT EXISTS ModificadoPor int REFERENCES AUTH(id);
+ALTER TABLE Asistencia ADD COLUMN IF NOT EXISTS FechaModificacion timestamp;
+
+-- Política de asistencia por sección. Sin fila, rigen los defaults de la
+-- tabla (los mismos que models.PoliticaPorDefecto). Los créditos son el
+-- porcentaje de una clase que vale cada estado; una clase "excused" no
+-- cuenta en el total salvo que ExcusadaCuenta sea true (y entonces vale 100).
+CREATE TABLE IF NOT EXISTS PoliticasAsistencia (
+    SeccionID int PRIMARY KEY REFERENCES Secciones(ID),
+    MinutosGracia int NOT NULL DEFAULT 10 CHECK (MinutosGracia BETWEEN 0 AND 240),
+    CreditoTarde int NOT NULL DEFAULT 100 CHECK (CreditoTarde BETWEEN 0 AND 100),
+    CreditoJustificada int NOT NULL DEFAULT 100 CHECK (CreditoJustificada BETWEEN 0 AND 100),
+    ExcusadaCuenta boolean NOT NULL DEFAULT false
+);
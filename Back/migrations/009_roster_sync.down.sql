@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS uq_inscripciones_alumno_seccion;
+
+-- Sin FechaBaja las bajas volverían a contar como inscritos.
+DELETE FROM Inscripciones WHERE FechaBaja IS NOT NULL;
+ALTER TABLE Inscripciones DROP COLUMN IF EXISTS FechaBaja;
+
+DROP INDEX IF EXISTS uq_secciones_id_externo;
+ALTER TABLE Secciones DROP COLUMN IF EXISTS IDExterno;
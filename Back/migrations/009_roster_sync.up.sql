@@ -0,0 +1,19 @@
+-- Reimportar el roster de Canvas sobre la misma sección. IDExterno es el
+-- identificador de la sección en Canvas (el código del export, ej.
+-- CIT1000_CA16) y es lo que busca la importación antes de crear una nueva.
+ALTER TABLE Secciones ADD COLUMN IF NOT EXISTS IDExterno varchar(100);
+CREATE UNIQUE INDEX IF NOT EXISTS uq_secciones_id_externo ON Secciones (IDExterno);
+
+-- Un alumno que deja de venir en el roster no se borra: su inscripción
+-- queda con FechaBaja y su asistencia sigue en Asistencia. Si vuelve a
+-- aparecer, se le limpia la baja.
+ALTER TABLE Inscripciones ADD COLUMN IF NOT EXISTS FechaBaja timestamp;
+
+-- Cada carga masiva podía repetir inscripciones; se deja la más antigua.
+DELETE FROM Inscripciones a
+USING Inscripciones b
+WHERE a.AlumnoID = b.AlumnoID
+  AND a.SeccionID = b.SeccionID
+  AND a.ID > b.ID;
+
+CREATE UNIQUE INDEX IF NOT EXISTS uq_inscripciones_alumno_seccion ON Inscripciones (AlumnoID, SeccionID);
@@ -0,0 +1,7 @@
+ALTER TABLE Modulos ALTER COLUMN ID DROP DEFAULT;
+DROP SEQUENCE IF EXISTS modulos_id_seq;
+DROP INDEX IF EXISTS idx_modulos_fecha;
+ALTER TABLE Modulos DROP COLUMN IF EXISTS PeriodoID;
+DROP TABLE IF EXISTS Feriados;
+DROP TABLE IF EXISTS BloquesHorario;
+DROP TABLE IF EXISTS Periodos;
@@ -0,0 +1,51 @@
+-- Calendario académico. Hasta ahora los Modulos se cargaban a mano; ahora
+-- se generan por período (`database calendar generate <periodo>` o
+-- POST /api/db/calendar/terms/:id/generate): un módulo por cada bloque de
+-- BloquesHorario en cada día hábil (lunes a viernes) entre FechaInicio y
+-- FechaFin, salvo los días que caen en un rango de Feriados (feriados de un
+-- día o recesos de varios).
+CREATE TABLE IF NOT EXISTS Periodos (
+    ID SERIAL PRIMARY KEY,
+    Nombre varchar(50) UNIQUE NOT NULL,
+    FechaInicio date NOT NULL,
+    FechaFin date NOT NULL,
+    CHECK (FechaFin >= FechaInicio)
+);
+
+CREATE TABLE IF NOT EXISTS BloquesHorario (
+    ID SERIAL PRIMARY KEY,
+    HoraInicio time NOT NULL,
+    HoraFin time NOT NULL,
+    UNIQUE (HoraInicio, HoraFin),
+    CHECK (HoraFin > HoraInicio)
+);
+
+-- Los bloques que ya ofrecía la app al crear un curso.
+INSERT INTO BloquesHorario (HoraInicio, HoraFin) VALUES
+    ('08:30', '10:00'),
+    ('10:00', '11:30'),
+    ('11:30', '13:00'),
+    ('13:00', '14:30'),
+    ('14:30', '16:00'),
+    ('16:00', '17:30'),
+    ('17:30', '19:00')
+ON CONFLICT DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS Feriados (
+    ID SERIAL PRIMARY KEY,
+    FechaInicio date NOT NULL,
+    FechaFin date NOT NULL,
+    Descripcion varchar(100) NOT NULL DEFAULT '',
+    CHECK (FechaFin >= FechaInicio)
+);
+
+-- Los módulos generados quedan asociados a su período; los que ya había
+-- quedan sin período hasta que se genere uno que los cubra.
+ALTER TABLE Modulos ADD COLUMN IF NOT EXISTS PeriodoID int REFERENCES Periodos(ID);
+CREATE INDEX IF NOT EXISTS idx_modulos_fecha ON Modulos (Fecha, HoraInicio, HoraFin);
+
+-- Modulos.ID se creó sin default; igual que en 005, se le agrega una
+-- secuencia para poder generarlos.
+CREATE SEQUENCE IF NOT EXISTS modulos_id_seq OWNED BY Modulos.ID;
+SELECT setval('modulos_id_seq', COALESCE((SELECT MAX(ID) FROM Modulos), 0) + 1, false);
+ALTER TABLE Modulos ALTER COLUMN ID SET DEFAULT nextval('modulos_id_seq');
@@ -0,0 +1,14 @@
+-- CierreProgramado vuelve a hora de pared de INSTITUTION_TZ
+-- (mysqr.institution_tz, ver la subida).
+ALTER TABLE SesionesClase
+    ALTER COLUMN FechaApertura TYPE timestamp,
+    ALTER COLUMN FechaCierre TYPE timestamp,
+    ALTER COLUMN CierreProgramado TYPE timestamp USING CierreProgramado AT TIME ZONE current_setting('mysqr.institution_tz');
+
+ALTER TABLE Inscripciones ALTER COLUMN FechaBaja TYPE timestamp;
+ALTER TABLE MACs ALTER COLUMN FechaRegistro TYPE timestamp;
+ALTER TABLE LogIn ALTER COLUMN FechaRegistro TYPE timestamp;
+ALTER TABLE QRGenerado ALTER COLUMN FechaRegistro TYPE timestamp;
+ALTER TABLE Asistencia
+    ALTER COLUMN FechaRegistro TYPE timestamp,
+    ALTER COLUMN FechaModificacion TYPE timestamp;
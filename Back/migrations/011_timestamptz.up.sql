@@ -0,0 +1,26 @@
+-- Los instantes (cuándo se escaneó, cuándo se abrió una sesión, cuándo hubo
+-- un login) pasan a timestamptz. Hasta ahora eran timestamp sin zona
+-- escritos con CURRENT_TIMESTAMP, o sea en la zona de la sesión de Postgres:
+-- la conversión implícita los lee en esa misma zona, así que conservan el
+-- instante siempre que el servidor no haya cambiado de TimeZone.
+--
+-- Modulos (Fecha, HoraInicio, HoraFin) sigue siendo hora de pared de la
+-- institución: el backend la compara con la hora de INSTITUTION_TZ.
+ALTER TABLE Asistencia
+    ALTER COLUMN FechaRegistro TYPE timestamptz,
+    ALTER COLUMN FechaModificacion TYPE timestamptz;
+ALTER TABLE QRGenerado ALTER COLUMN FechaRegistro TYPE timestamptz;
+ALTER TABLE LogIn ALTER COLUMN FechaRegistro TYPE timestamptz;
+ALTER TABLE MACs ALTER COLUMN FechaRegistro TYPE timestamptz;
+ALTER TABLE Inscripciones ALTER COLUMN FechaBaja TYPE timestamptz;
+
+-- CierreProgramado es la excepción: se calculaba como Modulos.Fecha +
+-- HoraFin, hora de pared de la institución y no de la sesión. La zona es
+-- INSTITUTION_TZ, que el runner (database migrate) pasa como
+-- mysqr.institution_tz. Para correr este script a mano hay que definirla
+-- antes en la misma transacción, por ejemplo
+-- SET LOCAL mysqr.institution_tz = 'America/Santiago'.
+ALTER TABLE SesionesClase
+    ALTER COLUMN FechaApertura TYPE timestamptz,
+    ALTER COLUMN FechaCierre TYPE timestamptz,
+    ALTER COLUMN CierreProgramado TYPE timestamptz USING CierreProgramado AT TIME ZONE current_setting('mysqr.institution_tz');
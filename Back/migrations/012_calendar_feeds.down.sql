@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS TokensCalendario;
+
+-- Sin la columna las clases canceladas volverían a contar como dictadas.
+DELETE FROM ProgramacionClases WHERE FechaCancelacion IS NOT NULL;
+ALTER TABLE ProgramacionClases DROP COLUMN IF EXISTS MotivoCancelacion;
+ALTER TABLE ProgramacionClases DROP COLUMN IF EXISTS FechaCancelacion;
@@ -0,0 +1,25 @@
+-- Clases canceladas. La fila de ProgramacionClases se conserva para que el
+-- feed iCal la publique como cancelada (los calendarios suscritos la
+-- tachan en vez de perderla), pero deja de contar en reportes y planillas
+-- y no se puede abrir su sesión.
+ALTER TABLE ProgramacionClases ADD COLUMN IF NOT EXISTS FechaCancelacion timestamptz;
+ALTER TABLE ProgramacionClases ADD COLUMN IF NOT EXISTS MotivoCancelacion varchar(500);
+
+-- Tokens de los feeds iCal (/api/db/calendar/feed/<token>.ics). Una app de
+-- calendario no manda JWT, así que el feed se autentica con el token en la
+-- URL; se guarda solo su SHA-256. Cada usuario tiene a lo más uno vigente:
+-- pedir otro revoca el anterior.
+CREATE TABLE IF NOT EXISTS TokensCalendario (
+    ID SERIAL PRIMARY KEY,
+    TokenHash char(64) UNIQUE NOT NULL,
+    ProfesorID int REFERENCES Profesores (ID),
+    AlumnoID int REFERENCES Alumnos (ID),
+    FechaCreacion timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FechaRevocacion timestamptz,
+    CHECK ((ProfesorID IS NULL) <> (AlumnoID IS NULL))
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS uq_tokens_calendario_profesor
+    ON TokensCalendario (ProfesorID) WHERE FechaRevocacion IS NULL AND ProfesorID IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS uq_tokens_calendario_alumno
+    ON TokensCalendario (AlumnoID) WHERE FechaRevocacion IS NULL AND AlumnoID IS NOT NULL;
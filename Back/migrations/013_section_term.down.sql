@@ -0,0 +1,11 @@
+DROP INDEX IF EXISTS uq_secciones_id_externo_periodo;
+
+-- Sin período, un IDExterno vuelve a ser único en toda la tabla: se lo deja
+-- a la sección más nueva y las de períodos anteriores quedan sin él.
+UPDATE Secciones s
+SET IDExterno = NULL
+WHERE s.IDExterno IS NOT NULL
+  AND EXISTS (SELECT 1 FROM Secciones o WHERE o.IDExterno = s.IDExterno AND o.ID > s.ID);
+
+CREATE UNIQUE INDEX IF NOT EXISTS uq_secciones_id_externo ON Secciones (IDExterno);
+ALTER TABLE Secciones DROP COLUMN IF EXISTS PeriodoID;
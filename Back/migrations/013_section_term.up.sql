@@ -0,0 +1,26 @@
+-- El código de Canvas (CIT1000_CA16) se repite cada período, así que
+-- IDExterno solo identifica una sección dentro de su período: la
+-- importación del período siguiente crea una sección nueva en vez de
+-- sincronizar la anterior.
+ALTER TABLE Secciones ADD COLUMN IF NOT EXISTS PeriodoID int REFERENCES Periodos(ID);
+
+-- Las secciones que ya existen toman el período de sus clases (el que más
+-- clases programadas tenga); las que no tienen clases en ningún período
+-- quedan sin período.
+UPDATE Secciones s
+SET PeriodoID = (
+    SELECT m.PeriodoID
+    FROM ProgramacionClases pc
+    JOIN Modulos m ON m.ID = pc.ModuloID
+    WHERE pc.SeccionID = s.ID AND m.PeriodoID IS NOT NULL
+    GROUP BY m.PeriodoID
+    ORDER BY COUNT(*) DESC, m.PeriodoID DESC
+    LIMIT 1
+)
+WHERE s.PeriodoID IS NULL;
+
+-- COALESCE para que dos secciones sin período con el mismo IDExterno
+-- también choquen.
+DROP INDEX IF EXISTS uq_secciones_id_externo;
+CREATE UNIQUE INDEX IF NOT EXISTS uq_secciones_id_externo_periodo
+    ON Secciones (IDExterno, COALESCE(PeriodoID, 0));
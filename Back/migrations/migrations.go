@@ -0,0 +1,26 @@
+// Package migrations embebe los scripts de esquema (NNN_nombre.up.sql /
+// .down.sql) y los datos de ejemplo de seeds/, para que el binario del
+// servicio database pueda migrar sin depender de archivos sueltos.
+package migrations
+
+import (
+	"embed"
+	"io/fs"
+)
+
+//go:embed *.sql
+var Schema embed.FS
+
+//go:embed seeds/*.sql
+var seeds embed.FS
+
+// Seeds son los datos de ejemplo, separados del esquema: no se aplican solos.
+var Seeds = mustSub(seeds, "seeds")
+
+func mustSub(fsys fs.FS, dir string) fs.FS {
+	sub, err := fs.Sub(fsys, dir)
+	if err != nil {
+		panic(err)
+	}
+	return sub
+}
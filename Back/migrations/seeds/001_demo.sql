@@ -0,0 +1,92 @@
+-- Datos de ejemplo para desarrollo: tres profesores, cinco alumnos y la
+-- sección 50 (asignatura "MysQR") con clases en todos los módulos de ayer,
+-- hoy, mañana y anteayer. Se cargan con `database migrate seed` sobre un
+-- esquema ya migrado; correrlo de nuevo no duplica nada.
+
+INSERT INTO Profesores (ID, Rut, Nombre, Apellido, Rol) VALUES
+    (1, 11111111, 'Juan', 'Pérez', 1),
+    (2, 22222222, 'María', 'González', 1),
+    (3, 33333333, 'Carlos', 'Rodríguez', 1)
+ON CONFLICT (ID) DO NOTHING;
+
+INSERT INTO Alumnos (ID, Rut, Nombre, NombreCompleto) VALUES
+    (1, 11111111, 'Ana', 'Ana Martínez'),
+    (2, 22222222, 'Pedro', 'Pedro Sánchez'),
+    (3, 33333333, 'Laura', 'Laura López'),
+    (4, 44444444, 'Diego', 'Diego Ramírez'),
+    (5, 55555555, 'Camila', 'Camila Torres')
+ON CONFLICT (ID) DO NOTHING;
+
+INSERT INTO Asignaturas (ID, Nombre, Codigo) VALUES
+    (50, 'MysQR', 'Feria01')
+ON CONFLICT (ID) DO NOTHING;
+SELECT setval(pg_get_serial_sequence('asignaturas', 'id'), (SELECT MAX(ID) FROM Asignaturas));
+
+INSERT INTO Secciones (ID, AsignaturaID, ProfesorID, Ubicacion) VALUES
+    (50, 50, 1, 'Sala Estudio')
+ON CONFLICT (ID) DO NOTHING;
+SELECT setval(pg_get_serial_sequence('secciones', 'id'), (SELECT MAX(ID) FROM Secciones));
+
+SELECT crear_particiones_seccion(50);
+
+-- Siete bloques por día, de anteayer a mañana.
+INSERT INTO Modulos (ID, Fecha, HoraInicio, HoraFin) VALUES
+    (1, CURRENT_DATE - 1, '08:30', '10:00'),
+    (2, CURRENT_DATE - 1, '10:00', '11:30'),
+    (3, CURRENT_DATE - 1, '11:30', '13:00'),
+    (4, CURRENT_DATE - 1, '13:00', '14:30'),
+    (5, CURRENT_DATE - 1, '14:30', '16:00'),
+    (6, CURRENT_DATE - 1, '16:00', '17:30'),
+    (7, CURRENT_DATE - 1, '17:30', '19:00'),
+    (8, CURRENT_DATE, '08:30', '10:00'),
+    (9, CURRENT_DATE, '10:00', '11:30'),
+    (10, CURRENT_DATE, '11:30', '13:00'),
+    (11, CURRENT_DATE, '13:00', '14:30'),
+    (12, CURRENT_DATE, '14:30', '16:00'),
+    (13, CURRENT_DATE, '16:00', '17:30'),
+    (14, CURRENT_DATE, '17:30', '19:00'),
+    (15, CURRENT_DATE + 1, '08:30', '10:00'),
+    (16, CURRENT_DATE + 1, '10:00', '11:30'),
+    (17, CURRENT_DATE + 1, '11:30', '13:00'),
+    (18, CURRENT_DATE + 1, '13:00', '14:30'),
+    (19, CURRENT_DATE + 1, '14:30', '16:00'),
+    (20, CURRENT_DATE + 1, '16:00', '17:30'),
+    (21, CURRENT_DATE + 1, '17:30', '19:00'),
+    (22, CURRENT_DATE - 2, '08:30', '10:00'),
+    (23, CURRENT_DATE - 2, '10:00', '11:30'),
+    (24, CURRENT_DATE - 2, '11:30', '13:00'),
+    (25, CURRENT_DATE - 2, '13:00', '14:30'),
+    (26, CURRENT_DATE - 2, '14:30', '16:00'),
+    (27, CURRENT_DATE - 2, '16:00', '17:30'),
+    (28, CURRENT_DATE - 2, '17:30', '19:00')
+ON CONFLICT (ID) DO NOTHING;
+SELECT setval(pg_get_serial_sequence('modulos', 'id'), (SELECT MAX(ID) FROM Modulos));
+
+INSERT INTO ProgramacionClases (SeccionID, ModuloID, TipoSesion)
+SELECT 50, m.ID, 1
+FROM Modulos m
+WHERE m.ID BETWEEN 1 AND 28
+  AND NOT EXISTS (SELECT 1 FROM ProgramacionClases pc WHERE pc.SeccionID = 50 AND pc.ModuloID = m.ID);
+
+INSERT INTO Inscripciones (AlumnoID, SeccionID)
+SELECT a.ID, 50
+FROM Alumnos a
+WHERE a.ID BETWEEN 1 AND 5
+  AND NOT EXISTS (SELECT 1 FROM Inscripciones i WHERE i.AlumnoID = a.ID AND i.SeccionID = 50);
+
+INSERT INTO QRGenerado (ID, ProfesorID, ModuloID, FechaRegistro, MAC) VALUES
+    (1, 1, 1, CURRENT_TIMESTAMP, '00:1B:44:11:3A:B7'),
+    (2, 2, 3, CURRENT_TIMESTAMP, '00:0D:3C:04:78:5B'),
+    (3, 3, 5, CURRENT_TIMESTAMP, '00:1C:B3:08:76:21')
+ON CONFLICT (ID) DO NOTHING;
+
+INSERT INTO MACs (ID, AlumnoID, FechaRegistro, MAC) VALUES
+    (1, 1, CURRENT_TIMESTAMP, '00:1B:44:11:3A:B7'),
+    (2, 2, CURRENT_TIMESTAMP, '00:0D:3C:04:78:5B'),
+    (3, 3, CURRENT_TIMESTAMP, '00:1C:B3:08:76:21')
+ON CONFLICT (ID) DO NOTHING;
+
+-- Profesor de la sección 50. Contraseña: "password".
+INSERT INTO AUTH (username, password_hash, rol, ProfesorID, AlumnoID, Rut) VALUES
+    ('MysQR', '$2a$12$rGaWamcrn3.N0sXY6yPBjekJb7kqY1L.sFyvHKr9djMSP004vFok6', 'profesor', 1, NULL, 11111111)
+ON CONFLICT (username) DO NOTHING;
@@ -2,6 +2,7 @@ package authmw
 
 import (
 	"net/http"
+	"slices"
 	"strings"
 
 	"mysqr/qr/pkg/auth"
@@ -11,9 +12,10 @@ import (
 
 const claimsKey = "claims"
 
-// RequireAuth exige un JWT válido en "Authorization: Bearer <token>" y deja
-// los claims disponibles en el contexto vía Claims(c).
-func RequireAuth() gin.HandlerFunc {
+// RequireAuth exige un JWT válido en "Authorization: Bearer <token>" cuya
+// sesión no haya sido revocada, y deja los claims disponibles en el contexto
+// vía Claims(c).
+func RequireAuth(verifier *auth.Verifier) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		header := c.GetHeader("Authorization")
 		if !strings.HasPrefix(header, "Bearer ") {
@@ -22,7 +24,7 @@ func RequireAuth() gin.HandlerFunc {
 		}
 		tokenString := strings.TrimPrefix(header, "Bearer ")
 
-		claims, err := auth.ValidateToken(tokenString)
+		claims, err := verifier.Verify(c.Request.Context(), tokenString)
 		if err != nil {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Token inválido o expirado"})
 			return
@@ -33,6 +35,19 @@ func RequireAuth() gin.HandlerFunc {
 	}
 }
 
+// RequireRole deja pasar solo a los roles indicados. Va después de
+// RequireAuth en la cadena de middlewares.
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims := Claims(c)
+		if claims != nil && slices.Contains(roles, claims.Rol) {
+			c.Next()
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "No tienes permisos para esta acción"})
+	}
+}
+
 // Claims recupera los claims del JWT guardados por RequireAuth.
 func Claims(c *gin.Context) *auth.Claims {
 	claims, _ := c.Get(claimsKey)
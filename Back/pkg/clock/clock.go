@@ -0,0 +1,86 @@
+// Package clock es la hora de la institución. Modulos guarda fecha y horas
+// "de pared" (08:30 es 08:30 en el campus), así que para saber qué módulo
+// corre ahora no sirve la zona horaria del contenedor de Postgres ni la del
+// servidor: se usa la de INSTITUTION_TZ.
+package clock
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	// Trae la base de zonas horarias dentro del binario: las imágenes
+	// mínimas no tienen /usr/share/zoneinfo.
+	_ "time/tzdata"
+)
+
+// DefaultZone es la zona horaria si no se define INSTITUTION_TZ.
+const DefaultZone = "America/Santiago"
+
+// Formato de una hora de pared para pasársela a Postgres como timestamp.
+const wallLayout = "2006-01-02 15:04:05"
+
+// Clock da la hora en la zona de la institución y las tolerancias con que
+// un módulo se considera "el actual": Antes de HoraInicio (el profesor
+// llega temprano) y Despues de HoraFin.
+type Clock struct {
+	Location *time.Location
+	Antes    time.Duration
+	Despues  time.Duration
+}
+
+// New arma un reloj para la zona loc con esas tolerancias.
+func New(loc *time.Location, antes, despues time.Duration) *Clock {
+	return &Clock{Location: loc, Antes: antes, Despues: despues}
+}
+
+// FromEnv lee INSTITUTION_TZ (nombre IANA, default America/Santiago),
+// MODULE_EARLY_MINUTES (default 10) y MODULE_LATE_MINUTES (default 0).
+func FromEnv() (*Clock, error) {
+	zone := DefaultZone
+	if v := os.Getenv("INSTITUTION_TZ"); v != "" {
+		zone = v
+	}
+	loc, err := time.LoadLocation(zone)
+	if err != nil {
+		return nil, fmt.Errorf("INSTITUTION_TZ inválido: %w", err)
+	}
+	antes, err := minutesFromEnv("MODULE_EARLY_MINUTES", 10)
+	if err != nil {
+		return nil, err
+	}
+	despues, err := minutesFromEnv("MODULE_LATE_MINUTES", 0)
+	if err != nil {
+		return nil, err
+	}
+	return New(loc, antes, despues), nil
+}
+
+func minutesFromEnv(key string, def int) (time.Duration, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return time.Duration(def) * time.Minute, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 || n > 120 {
+		return 0, fmt.Errorf("%s inválido: %q (minutos, de 0 a 120)", key, v)
+	}
+	return time.Duration(n) * time.Minute, nil
+}
+
+// Now es el instante actual en la zona de la institución.
+func (c *Clock) Now() time.Time {
+	return time.Now().In(c.Location)
+}
+
+// Wall es la hora de pared actual de la institución ("AAAA-MM-DD
+// HH:MM:SS"), para compararla en SQL con Modulos.Fecha + HoraInicio.
+func (c *Clock) Wall() string {
+	return c.Now().Format(wallLayout)
+}
+
+// Zone es el nombre IANA de la zona, para AT TIME ZONE en SQL.
+func (c *Clock) Zone() string {
+	return c.Location.String()
+}
@@ -0,0 +1,108 @@
+// Package livefeed reparte en vivo lo que pasa con los escaneos de una clase
+// (asistencias registradas y escaneos rechazados) usando pub/sub de Redis:
+// cualquier réplica de student publica y cualquier réplica de teacher
+// recibe, sin que tengan que conocerse entre sí.
+package livefeed
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Tipos de Event.
+const (
+	// EventAttendance: se registró la asistencia de un alumno por QR; Status
+	// dice si quedó "present" o "late".
+	EventAttendance = "attendance"
+	// EventRejected: se rechazó un escaneo; Reason dice por qué.
+	EventRejected = "rejected"
+)
+
+// Event es un suceso de la toma de asistencia de una sección en un módulo.
+type Event struct {
+	Type      string    `json:"type"`
+	SectionID int       `json:"section_id"`
+	ModuleID  int       `json:"module_id"`
+	SessionID int64     `json:"session_id,omitempty"`
+	AlumnoID  int       `json:"alumno_id"`
+	Reason    string    `json:"reason,omitempty"`
+	Status    string    `json:"status,omitempty"`
+	At        time.Time `json:"at"`
+}
+
+// Feed publica y entrega Events por el canal de Redis de cada clase.
+type Feed struct {
+	rdb *redis.Client
+}
+
+func New(rdb *redis.Client) *Feed {
+	return &Feed{rdb: rdb}
+}
+
+func channel(sectionID, moduleID int) string {
+	return fmt.Sprintf("attendance:%d:%d", sectionID, moduleID)
+}
+
+// Publish manda ev a quienes estén mirando esa clase. Si nadie mira, el
+// evento se pierde: el feed es en vivo, la fuente de verdad es la base.
+func (f *Feed) Publish(ctx context.Context, ev Event) error {
+	if ev.At.IsZero() {
+		ev.At = time.Now()
+	}
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	return f.rdb.Publish(ctx, channel(ev.SectionID, ev.ModuleID), data).Err()
+}
+
+// Subscription entrega los eventos de una clase hasta que se llama Close.
+type Subscription struct {
+	pubsub *redis.PubSub
+	events chan Event
+	done   chan struct{}
+}
+
+// Subscribe se suscribe a la clase y vuelve recién cuando Redis confirmó la
+// suscripción, así lo que se lea de la base después no se cruza con eventos
+// perdidos. El canal de Events se cierra con Close o si se corta Redis.
+func (f *Feed) Subscribe(ctx context.Context, sectionID, moduleID int) (*Subscription, error) {
+	pubsub := f.rdb.Subscribe(ctx, channel(sectionID, moduleID))
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return nil, err
+	}
+
+	sub := &Subscription{pubsub: pubsub, events: make(chan Event), done: make(chan struct{})}
+	go func() {
+		defer close(sub.events)
+		for msg := range pubsub.Channel() {
+			var ev Event
+			if err := json.Unmarshal([]byte(msg.Payload), &ev); err != nil {
+				log.Printf("livefeed: evento inválido en %s: %v", msg.Channel, err)
+				continue
+			}
+			select {
+			case sub.events <- ev:
+			case <-sub.done:
+				return
+			}
+		}
+	}()
+	return sub, nil
+}
+
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+// Close termina la suscripción. Se llama una sola vez.
+func (s *Subscription) Close() error {
+	close(s.done)
+	return s.pubsub.Close()
+}
@@ -0,0 +1,48 @@
+package password
+
+import (
+	"crypto/subtle"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Cost es el costo de bcrypt para los hashes nuevos. Si se sube, los hashes
+// viejos se rehashean solos en el siguiente login exitoso (ver NeedsRehash).
+const Cost = 12
+
+// Hash devuelve el hash bcrypt de una contraseña en formato modular crypt
+// ("$2a$12$..."): el prefijo versiona el algoritmo y el costo.
+func Hash(plain string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(plain), Cost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// IsLegacy indica si lo guardado en AUTH.password_hash es una contraseña en
+// texto plano de antes de hashear (no trae prefijo de bcrypt).
+func IsLegacy(stored string) bool {
+	return !strings.HasPrefix(stored, "$2")
+}
+
+// Verify compara una contraseña contra lo guardado en AUTH.password_hash.
+// Acepta filas legacy en texto plano (comparación en tiempo constante) para
+// poder migrar la tabla sin obligar a nadie a resetear su clave.
+func Verify(stored, plain string) bool {
+	if IsLegacy(stored) {
+		return subtle.ConstantTimeCompare([]byte(stored), []byte(plain)) == 1
+	}
+	return bcrypt.CompareHashAndPassword([]byte(stored), []byte(plain)) == nil
+}
+
+// NeedsRehash indica si, tras un login exitoso, conviene reemplazar lo
+// guardado por un hash nuevo: filas legacy o hashes con un costo anterior.
+func NeedsRehash(stored string) bool {
+	if IsLegacy(stored) {
+		return true
+	}
+	cost, err := bcrypt.Cost([]byte(stored))
+	return err != nil || cost != Cost
+}
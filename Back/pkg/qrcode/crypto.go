@@ -7,13 +7,41 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"errors"
-	"io"
+	"fmt"
+	"log"
 	"os"
+	"slices"
 )
 
-// El fallback debe tener exactamente 32 bytes (AES-256): aes.NewCipher
-// rechaza cualquier otro largo.
-var encryptionKey = []byte(getEnv("ENCRYPTION_KEY", "mysqr-attendance-secret-key-2026"))
+// sealVersion es el primer byte de todo QR sellado. Si cambia el formato
+// (algoritmo, encabezado) se sube y Open rechaza versiones que no conoce.
+const sealVersion byte = 1
+
+// Llave de desarrollo, solo se usa fuera de producción cuando no hay
+// ENCRYPTION_KEY. Debe tener exactamente 32 bytes (AES-256).
+const devKey = "mysqr-attendance-secret-key-2026"
+
+var (
+	ErrMalformed  = errors.New("qrcode: formato de QR inválido")
+	ErrVersion    = errors.New("qrcode: versión de QR no soportada")
+	ErrUnknownKey = errors.New("qrcode: llave de cifrado desconocida")
+	ErrTampered   = errors.New("qrcode: QR alterado o cifrado con otra llave")
+)
+
+// KeyRing sella y abre QRs con AES-256-GCM. Sella siempre con la llave
+// activa y abre con cualquiera de las que conoce, así un QR emitido con la
+// llave anterior sigue validando durante la rotación hasta que expira.
+//
+// Formato (base64url sin padding):
+//
+//	versión (1 byte) | largo del key ID (1 byte) | key ID | nonce (12) | ciphertext+tag
+//
+// El encabezado (versión + key ID) va como datos autenticados: cambiarlo
+// invalida el tag.
+type KeyRing struct {
+	activeID string
+	aeads    map[string]cipher.AEAD
+}
 
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
@@ -22,57 +50,140 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
-// Encrypt serializa y cifra un Payload (AES-CFB) para pintarlo como QR.
-func Encrypt(payload Payload) (string, error) {
-	jsonData, err := json.Marshal(payload)
-	if err != nil {
-		return "", err
+// LoadKeyRing arma el KeyRing desde el entorno:
+//
+//	ENCRYPTION_KEY / ENCRYPTION_KEY_ID                    llave activa (default id "k1")
+//	ENCRYPTION_KEY_PREVIOUS / ENCRYPTION_KEY_PREVIOUS_ID  llave anterior, solo para abrir
+//
+// Cada llave va como 32 bytes crudos o en base64. Con APP_ENV=production es
+// un error que falte ENCRYPTION_KEY; fuera de producción se usa una llave de
+// desarrollo.
+func LoadKeyRing() (*KeyRing, error) {
+	active := os.Getenv("ENCRYPTION_KEY")
+	if active == "" {
+		if os.Getenv("APP_ENV") == "production" {
+			return nil, errors.New("qrcode: falta ENCRYPTION_KEY en producción")
+		}
+		log.Printf("ENCRYPTION_KEY no definida: usando la llave de desarrollo para los QR")
+		active = devKey
 	}
 
-	block, err := aes.NewCipher(encryptionKey)
-	if err != nil {
-		return "", err
+	k := &KeyRing{aeads: map[string]cipher.AEAD{}}
+	k.activeID = getEnv("ENCRYPTION_KEY_ID", "k1")
+	if err := k.add(k.activeID, active); err != nil {
+		return nil, err
 	}
 
-	ciphertext := make([]byte, aes.BlockSize+len(jsonData))
-	iv := ciphertext[:aes.BlockSize]
-	if _, err := io.ReadFull(rand.Reader, iv); err != nil {
-		return "", err
+	if previous := os.Getenv("ENCRYPTION_KEY_PREVIOUS"); previous != "" {
+		previousID := getEnv("ENCRYPTION_KEY_PREVIOUS_ID", "k0")
+		if previousID == k.activeID {
+			return nil, errors.New("qrcode: ENCRYPTION_KEY_PREVIOUS_ID no puede ser igual a ENCRYPTION_KEY_ID")
+		}
+		if err := k.add(previousID, previous); err != nil {
+			return nil, err
+		}
 	}
 
-	stream := cipher.NewCFBEncrypter(block, iv)
-	stream.XORKeyStream(ciphertext[aes.BlockSize:], jsonData)
+	return k, nil
+}
 
-	return base64.StdEncoding.EncodeToString(ciphertext), nil
+func (k *KeyRing) add(id, raw string) error {
+	if id == "" || len(id) > 255 {
+		return fmt.Errorf("qrcode: key ID inválido %q", id)
+	}
+
+	key := []byte(raw)
+	if len(key) != 32 {
+		decoded, err := base64.StdEncoding.DecodeString(raw)
+		if err != nil || len(decoded) != 32 {
+			return fmt.Errorf("qrcode: la llave %q debe tener 32 bytes (crudos o en base64)", id)
+		}
+		key = decoded
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return err
+	}
+	k.aeads[id] = aead
+	return nil
+}
+
+// Seal serializa y sella un Payload con la llave activa para pintarlo como QR.
+func (k *KeyRing) Seal(payload Payload) (string, error) {
+	return k.seal("", payload)
 }
 
-// Decrypt revierte Encrypt. Devuelve error si el string no es un QR válido.
-func Decrypt(encrypted string) (Payload, error) {
+// Open revierte Seal. Falla si el string no es un QR de este formato, si la
+// llave no está en el KeyRing o si el contenido fue alterado.
+func (k *KeyRing) Open(encoded string) (Payload, error) {
 	var payload Payload
+	err := k.open("", encoded, &payload)
+	return payload, err
+}
 
-	ciphertext, err := base64.StdEncoding.DecodeString(encrypted)
+// seal sella v (como JSON) con la llave activa. purpose va en los datos
+// autenticados además del encabezado: un string sellado para un propósito
+// no abre con otro (un token de proyección no pasa por QR). Los QR usan ""
+// para seguir abriendo los ya emitidos.
+func (k *KeyRing) seal(purpose string, v any) (string, error) {
+	plaintext, err := json.Marshal(v)
 	if err != nil {
-		return payload, err
+		return "", err
 	}
 
-	block, err := aes.NewCipher(encryptionKey)
-	if err != nil {
-		return payload, err
+	aead := k.aeads[k.activeID]
+	header := append([]byte{sealVersion, byte(len(k.activeID))}, k.activeID...)
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
 	}
 
-	if len(ciphertext) < aes.BlockSize {
-		return payload, errors.New("qrcode: ciphertext demasiado corto")
+	// dst y los datos autenticados no pueden compartir memoria.
+	sealed := append(slices.Clone(header), nonce...)
+	sealed = aead.Seal(sealed, nonce, plaintext, append(slices.Clone(header), purpose...))
+
+	return base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+// open revierte seal sobre v.
+func (k *KeyRing) open(purpose, encoded string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil || len(data) < 2 {
+		return ErrMalformed
+	}
+	if data[0] != sealVersion {
+		return ErrVersion
 	}
 
-	iv := ciphertext[:aes.BlockSize]
-	ciphertext = ciphertext[aes.BlockSize:]
+	idLen := int(data[1])
+	if len(data) < 2+idLen {
+		return ErrMalformed
+	}
+	header := data[:2+idLen]
+	aead, ok := k.aeads[string(header[2:])]
+	if !ok {
+		return ErrUnknownKey
+	}
 
-	stream := cipher.NewCFBDecrypter(block, iv)
-	stream.XORKeyStream(ciphertext, ciphertext)
+	rest := data[len(header):]
+	if len(rest) < aead.NonceSize()+aead.Overhead() {
+		return ErrMalformed
+	}
+	nonce, ciphertext := rest[:aead.NonceSize()], rest[aead.NonceSize():]
 
-	if err := json.Unmarshal(ciphertext, &payload); err != nil {
-		return payload, err
+	plaintext, err := aead.Open(nil, nonce, ciphertext, append(slices.Clone(header), purpose...))
+	if err != nil {
+		return ErrTampered
 	}
 
-	return payload, nil
+	if err := json.Unmarshal(plaintext, v); err != nil {
+		return ErrMalformed
+	}
+	return nil
 }
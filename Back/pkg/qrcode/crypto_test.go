@@ -0,0 +1,170 @@
+package qrcode
+
+import (
+	"crypto/cipher"
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestKeyRingOpen(t *testing.T) {
+	k := testKeyRing(t)
+	now := time.Date(2026, 3, 9, 10, 0, 0, 0, time.UTC)
+
+	sealed, err := k.Seal(Payload{UUID: "u", SectionID: "10"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, _ := base64.RawURLEncoding.DecodeString(sealed)
+	tampered := append([]byte(nil), raw...)
+	tampered[len(tampered)-1] ^= 1
+	otherVersion := append([]byte(nil), raw...)
+	otherVersion[0] = sealVersion + 1
+
+	projection, err := k.SealProjection(Projection{ProfessorID: 5, ClassSession: 7}, ScopeProjection, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other := &KeyRing{activeID: "otra", aeads: map[string]cipher.AEAD{}}
+	if err := other.add("otra", "fedcba9876543210fedcba9876543210"); err != nil {
+		t.Fatal(err)
+	}
+	unknownKey, err := other.Seal(Payload{UUID: "u"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		encoded string
+		want    error
+	}{
+		{"vigente", sealed, nil},
+		{"alterado", base64.RawURLEncoding.EncodeToString(tampered), ErrTampered},
+		{"otra versión", base64.RawURLEncoding.EncodeToString(otherVersion), ErrVersion},
+		{"llave desconocida", unknownKey, ErrUnknownKey},
+		{"no es base64", "no es un QR!", ErrMalformed},
+		{"vacío", "", ErrMalformed},
+		{"token de proyección", projection, ErrTampered},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payload, err := k.Open(tt.encoded)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("Open = %v, se esperaba %v", err, tt.want)
+			}
+			if err == nil && (payload.UUID != "u" || payload.SectionID != "10") {
+				t.Errorf("Open = %+v", payload)
+			}
+		})
+	}
+}
+
+func TestKeyRingProjection(t *testing.T) {
+	k := testKeyRing(t)
+	now := time.Date(2026, 3, 9, 10, 0, 0, 0, time.UTC)
+	p := Projection{ProfessorID: 5, ClassSession: 7, SectionID: 10, ModuleID: 2, AuthSID: "sid"}
+
+	token, err := k.SealProjection(p, ScopeProjection, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := k.OpenProjection(token, ScopeProjection, now.Add(ProjectionTTL-time.Second))
+	if err != nil {
+		t.Fatalf("OpenProjection vigente: %v", err)
+	}
+	if got.ClassSession != 7 || got.AuthSID != "sid" || !got.Expires().Equal(now.Add(ProjectionTTL)) {
+		t.Errorf("OpenProjection = %+v", got)
+	}
+	if _, err := k.OpenProjection(token, ScopeProjection, now.Add(ProjectionTTL)); !errors.Is(err, ErrProjectionExpired) {
+		t.Errorf("OpenProjection vencido = %v", err)
+	}
+	if _, err := k.OpenProjection(token, ScopeLive, now); !errors.Is(err, ErrTampered) {
+		t.Errorf("un token de proyección abrió como ScopeLive: %v", err)
+	}
+}
+
+func TestKeyRingRotation(t *testing.T) {
+	old := testKeyRing(t)
+	sealed, err := old.Seal(Payload{UUID: "u", SectionID: "10"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// La llave anterior queda solo para abrir: lo emitido antes de rotar
+	// sigue valiendo y lo nuevo sale con la activa.
+	rotated := &KeyRing{activeID: "nueva", aeads: map[string]cipher.AEAD{}}
+	if err := rotated.add("nueva", "fedcba9876543210fedcba9876543210"); err != nil {
+		t.Fatal(err)
+	}
+	if err := rotated.add("test", "0123456789abcdef0123456789abcdef"); err != nil {
+		t.Fatal(err)
+	}
+	if payload, err := rotated.Open(sealed); err != nil || payload.UUID != "u" {
+		t.Fatalf("Open de un QR de la llave anterior = %+v, %v", payload, err)
+	}
+
+	fresh, err := rotated.Seal(Payload{UUID: "v"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := old.Open(fresh); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("la llave anterior abrió un QR de la nueva: %v", err)
+	}
+}
+
+func TestLoadKeyRing(t *testing.T) {
+	const active = "0123456789abcdef0123456789abcdef"
+	encoded := base64.StdEncoding.EncodeToString([]byte("fedcba9876543210fedcba9876543210"))
+
+	tests := []struct {
+		name    string
+		env     map[string]string
+		wantErr bool
+		ids     []string
+	}{
+		{name: "desarrollo sin llave", ids: []string{"k1"}},
+		{name: "producción sin llave", env: map[string]string{"APP_ENV": "production"}, wantErr: true},
+		{name: "llave cruda con id", env: map[string]string{"ENCRYPTION_KEY": active, "ENCRYPTION_KEY_ID": "2026"}, ids: []string{"2026"}},
+		{name: "llave en base64", env: map[string]string{"ENCRYPTION_KEY": encoded}, ids: []string{"k1"}},
+		{name: "llave corta", env: map[string]string{"ENCRYPTION_KEY": "corta"}, wantErr: true},
+		{
+			name: "con llave anterior",
+			env:  map[string]string{"ENCRYPTION_KEY": active, "ENCRYPTION_KEY_PREVIOUS": encoded},
+			ids:  []string{"k1", "k0"},
+		},
+		{
+			name:    "anterior con el mismo id",
+			env:     map[string]string{"ENCRYPTION_KEY": active, "ENCRYPTION_KEY_PREVIOUS": encoded, "ENCRYPTION_KEY_PREVIOUS_ID": "k1"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{"APP_ENV", "ENCRYPTION_KEY", "ENCRYPTION_KEY_ID", "ENCRYPTION_KEY_PREVIOUS", "ENCRYPTION_KEY_PREVIOUS_ID"} {
+				t.Setenv(key, tt.env[key])
+			}
+			k, err := LoadKeyRing()
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("LoadKeyRing no falló")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if k.activeID != tt.ids[0] || len(k.aeads) != len(tt.ids) {
+				t.Fatalf("LoadKeyRing: activa %q con %d llaves, se esperaba %v", k.activeID, len(k.aeads), tt.ids)
+			}
+			for _, id := range tt.ids {
+				if k.aeads[id] == nil {
+					t.Errorf("falta la llave %q", id)
+				}
+			}
+		})
+	}
+}
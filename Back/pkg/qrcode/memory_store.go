@@ -0,0 +1,119 @@
+package qrcode
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore implementa Store en memoria del proceso, con las mismas reglas
+// de vencimiento y canje que RedisStore. Es seguro para uso concurrente.
+type MemoryStore struct {
+	keys *KeyRing
+	opts Options
+
+	// now es el reloj; las pruebas lo reemplazan para vencer QRs sin esperar.
+	now func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*memoryEntry
+}
+
+type memoryEntry struct {
+	expiresAt time.Time
+	redeemers map[string]struct{}
+}
+
+func NewMemoryStore(keys *KeyRing, opts Options) *MemoryStore {
+	return &MemoryStore{
+		keys:    keys,
+		opts:    opts,
+		now:     time.Now,
+		entries: map[string]*memoryEntry{},
+	}
+}
+
+func (s *MemoryStore) Issue(_ context.Context, payload Payload, ttl time.Duration) (string, error) {
+	payload, encrypted, err := newPayload(s.keys, payload)
+	if err != nil {
+		return "", err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.sweep(now)
+	s.entries[redisKey(payload.SectionID, payload.UUID)] = &memoryEntry{
+		expiresAt: now.Add(ttl),
+		redeemers: map[string]struct{}{},
+	}
+	return encrypted, nil
+}
+
+func (s *MemoryStore) Redeem(_ context.Context, payload Payload, alumnoID string) (ok bool, reason string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry := s.live(redisKey(payload.SectionID, payload.UUID), s.now())
+	if entry == nil {
+		return false, ReasonExpired, nil
+	}
+	if !s.opts.SingleUse && s.opts.MaxRedemptions == 0 {
+		return true, "", nil
+	}
+
+	if _, redeemed := entry.redeemers[alumnoID]; redeemed {
+		if s.opts.SingleUse {
+			return false, ReasonAlreadyRedeemed, nil
+		}
+		return true, "", nil
+	}
+	if s.opts.MaxRedemptions > 0 && len(entry.redeemers) >= s.opts.MaxRedemptions {
+		return false, ReasonLimitReached, nil
+	}
+	entry.redeemers[alumnoID] = struct{}{}
+	return true, "", nil
+}
+
+func (s *MemoryStore) Release(_ context.Context, payload Payload, alumnoID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if entry := s.live(redisKey(payload.SectionID, payload.UUID), s.now()); entry != nil {
+		delete(entry.redeemers, alumnoID)
+	}
+	return nil
+}
+
+func (s *MemoryStore) Revoke(_ context.Context, sectionID, uuid string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.entries, redisKey(sectionID, uuid))
+	return nil
+}
+
+// live devuelve la entrada si todavía no vence; las vencidas se borran al
+// pasar. Se llama con s.mu tomado.
+func (s *MemoryStore) live(key string, now time.Time) *memoryEntry {
+	entry, ok := s.entries[key]
+	if !ok {
+		return nil
+	}
+	if !now.Before(entry.expiresAt) {
+		delete(s.entries, key)
+		return nil
+	}
+	return entry
+}
+
+// sweep borra todas las entradas vencidas. Los QR viven segundos, así que
+// basta con barrer en cada emisión. Se llama con s.mu tomado.
+func (s *MemoryStore) sweep(now time.Time) {
+	for key, entry := range s.entries {
+		if !now.Before(entry.expiresAt) {
+			delete(s.entries, key)
+		}
+	}
+}
@@ -0,0 +1,237 @@
+package qrcode
+
+import (
+	"context"
+	"crypto/cipher"
+	"testing"
+	"time"
+)
+
+func testKeyRing(t *testing.T) *KeyRing {
+	t.Helper()
+	k := &KeyRing{activeID: "test", aeads: map[string]cipher.AEAD{}}
+	if err := k.add("test", "0123456789abcdef0123456789abcdef"); err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+// fakeClock es un reloj que solo avanza cuando la prueba lo pide.
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) now() time.Time { return c.t }
+
+func newTestMemoryStore(t *testing.T, opts Options) (*MemoryStore, *fakeClock) {
+	t.Helper()
+	clock := &fakeClock{t: time.Date(2026, 3, 9, 10, 0, 0, 0, time.UTC)}
+	s := NewMemoryStore(testKeyRing(t), opts)
+	s.now = clock.now
+	return s, clock
+}
+
+func TestMemoryStoreIssue(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestMemoryStore(t, Options{})
+
+	encrypted, err := s.Issue(ctx, Payload{SessionID: "7", SectionID: "3", ProfessorID: "5", ModuleID: "2"}, DefaultTTL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, err := s.keys.Open(encrypted)
+	if err != nil {
+		t.Fatalf("Open del QR emitido: %v", err)
+	}
+	if payload.UUID == "" || payload.IssuedAt == 0 {
+		t.Errorf("Issue no completó UUID/IssuedAt: %+v", payload)
+	}
+	if payload.SessionID != "7" || payload.SectionID != "3" || payload.ProfessorID != "5" || payload.ModuleID != "2" {
+		t.Errorf("Issue cambió los campos de quien llama: %+v", payload)
+	}
+
+	other, err := s.Issue(ctx, Payload{SectionID: "3"}, DefaultTTL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherPayload, _ := s.keys.Open(other)
+	if otherPayload.UUID == payload.UUID {
+		t.Error("dos emisiones con el mismo UUID")
+	}
+}
+
+func TestMemoryStoreRedeem(t *testing.T) {
+	type step struct {
+		op      string // redeem, release, revoke o advance
+		alumno  string
+		advance time.Duration
+		ok      bool
+		reason  string
+	}
+	redeem := func(alumno string, ok bool, reason string) step {
+		return step{op: "redeem", alumno: alumno, ok: ok, reason: reason}
+	}
+	release := func(alumno string) step { return step{op: "release", alumno: alumno} }
+	advance := func(d time.Duration) step { return step{op: "advance", advance: d} }
+	revoke := step{op: "revoke"}
+
+	tests := []struct {
+		name  string
+		opts  Options
+		steps []step
+	}{
+		{
+			name: "sin límites se canjea libremente",
+			steps: []step{
+				redeem("a", true, ""),
+				redeem("a", true, ""),
+				redeem("b", true, ""),
+			},
+		},
+		{
+			name: "single use rechaza al mismo alumno",
+			opts: Options{SingleUse: true},
+			steps: []step{
+				redeem("a", true, ""),
+				redeem("a", false, ReasonAlreadyRedeemed),
+				redeem("b", true, ""),
+			},
+		},
+		{
+			name: "release permite reintentar",
+			opts: Options{SingleUse: true},
+			steps: []step{
+				redeem("a", true, ""),
+				release("a"),
+				redeem("a", true, ""),
+			},
+		},
+		{
+			name: "tope de alumnos distintos",
+			opts: Options{MaxRedemptions: 2},
+			steps: []step{
+				redeem("a", true, ""),
+				redeem("b", true, ""),
+				redeem("c", false, ReasonLimitReached),
+				redeem("a", true, ""),
+				release("b"),
+				redeem("c", true, ""),
+			},
+		},
+		{
+			name: "tope con single use",
+			opts: Options{SingleUse: true, MaxRedemptions: 1},
+			steps: []step{
+				redeem("a", true, ""),
+				redeem("a", false, ReasonAlreadyRedeemed),
+				redeem("b", false, ReasonLimitReached),
+			},
+		},
+		{
+			name: "vigente justo antes del TTL",
+			steps: []step{
+				advance(DefaultTTL - time.Nanosecond),
+				redeem("a", true, ""),
+			},
+		},
+		{
+			name: "vence al cumplir el TTL",
+			steps: []step{
+				advance(DefaultTTL),
+				redeem("a", false, ReasonExpired),
+			},
+		},
+		{
+			name: "release de un QR vencido no falla",
+			opts: Options{SingleUse: true},
+			steps: []step{
+				redeem("a", true, ""),
+				advance(DefaultTTL),
+				release("a"),
+				redeem("a", false, ReasonExpired),
+			},
+		},
+		{
+			name: "revocado",
+			steps: []step{
+				redeem("a", true, ""),
+				revoke,
+				redeem("b", false, ReasonExpired),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			s, clock := newTestMemoryStore(t, tt.opts)
+
+			encrypted, err := s.Issue(ctx, Payload{SessionID: "1", SectionID: "10", ModuleID: "2"}, DefaultTTL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			payload, err := s.keys.Open(encrypted)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for i, st := range tt.steps {
+				switch st.op {
+				case "redeem":
+					ok, reason, err := s.Redeem(ctx, payload, st.alumno)
+					if err != nil {
+						t.Fatalf("paso %d: %v", i, err)
+					}
+					if ok != st.ok || reason != st.reason {
+						t.Fatalf("paso %d: Redeem(%q) = %v, %q; se esperaba %v, %q", i, st.alumno, ok, reason, st.ok, st.reason)
+					}
+				case "release":
+					if err := s.Release(ctx, payload, st.alumno); err != nil {
+						t.Fatalf("paso %d: %v", i, err)
+					}
+				case "revoke":
+					if err := s.Revoke(ctx, payload.SectionID, payload.UUID); err != nil {
+						t.Fatalf("paso %d: %v", i, err)
+					}
+				case "advance":
+					clock.t = clock.t.Add(st.advance)
+				}
+			}
+		})
+	}
+}
+
+func TestMemoryStoreRedeemNotIssued(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestMemoryStore(t, Options{})
+
+	// Sellado con la llave correcta pero nunca guardado: no vale.
+	_, encrypted, err := newPayload(s.keys, Payload{SectionID: "10"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, err := s.keys.Open(encrypted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ok, reason, err := s.Redeem(ctx, payload, "a")
+	if err != nil || ok || reason != ReasonExpired {
+		t.Errorf("Redeem de un QR no emitido = %v, %q, %v", ok, reason, err)
+	}
+}
+
+func TestMemoryStoreSweep(t *testing.T) {
+	ctx := context.Background()
+	s, clock := newTestMemoryStore(t, Options{})
+
+	for range 3 {
+		if _, err := s.Issue(ctx, Payload{SectionID: "10"}, DefaultTTL); err != nil {
+			t.Fatal(err)
+		}
+	}
+	clock.t = clock.t.Add(DefaultTTL)
+	if _, err := s.Issue(ctx, Payload{SectionID: "10"}, DefaultTTL); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(s.entries); n != 1 {
+		t.Errorf("quedaron %d QRs guardados después de vencer 3, se esperaba 1", n)
+	}
+}
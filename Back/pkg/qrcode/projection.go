@@ -0,0 +1,65 @@
+package qrcode
+
+import (
+	"errors"
+	"time"
+)
+
+// ProjectionTTL es cuánto dura un token de proyección. La página de
+// proyección lo cambia por uno nuevo con cada QR que pide, así que solo
+// vence si deja de pedir (pestaña cerrada, equipo suspendido).
+const ProjectionTTL = 2 * time.Minute
+
+// ErrProjectionExpired se devuelve al abrir un token de proyección vencido.
+var ErrProjectionExpired = errors.New("qrcode: token de proyección vencido")
+
+// Projection es el contenido de un token de proyección: permite pedir QRs
+// (y ver la asistencia en vivo) de una sola sesión de clase, sin el JWT del
+// profesor. AuthSID es la sesión de login que la abrió: si el profesor
+// cierra sesión, la proyección deja de servir.
+type Projection struct {
+	ProfessorID  int    `json:"professor_id"`
+	ClassSession int64  `json:"class_session"`
+	SectionID    int    `json:"section_id"`
+	ModuleID     int    `json:"module_id"`
+	AuthSID      string `json:"sid"`
+	ExpiresAt    int64  `json:"exp"`
+}
+
+// ProjectionScope es para qué sirve un token de proyección. Un token de un
+// alcance no abre como de otro.
+type ProjectionScope string
+
+const (
+	// ScopeProjection pide QRs y se renueva; viaja solo en headers.
+	ScopeProjection ProjectionScope = "projection"
+	// ScopeLive solo abre el feed en vivo de la clase y no se renueva. Va en
+	// la URL (EventSource no manda headers), así que si queda en un log no
+	// sirve para emitir QRs ni para conseguir otro token.
+	ScopeLive ProjectionScope = "projection-live"
+)
+
+// SealProjection sella p para scope con vencimiento en ProjectionTTL desde
+// now.
+func (k *KeyRing) SealProjection(p Projection, scope ProjectionScope, now time.Time) (string, error) {
+	p.ExpiresAt = now.Add(ProjectionTTL).Unix()
+	return k.seal(string(scope), p)
+}
+
+// OpenProjection abre un token de SealProjection del mismo scope y confirma
+// que no haya vencido a la hora now.
+func (k *KeyRing) OpenProjection(token string, scope ProjectionScope, now time.Time) (Projection, error) {
+	var p Projection
+	if err := k.open(string(scope), token, &p); err != nil {
+		return p, err
+	}
+	if now.Unix() >= p.ExpiresAt {
+		return p, ErrProjectionExpired
+	}
+	return p, nil
+}
+
+// Expires es el vencimiento del token.
+func (p Projection) Expires() time.Time {
+	return time.Unix(p.ExpiresAt, 0)
+}
@@ -0,0 +1,93 @@
+package qrcode
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// RedisStore implementa Store sobre Redis: cada QR es una clave con TTL y
+// sus canjes un set que vence junto con ella.
+type RedisStore struct {
+	rdb  *redis.Client
+	keys *KeyRing
+	opts Options
+}
+
+func NewRedisStore(rdb *redis.Client, keys *KeyRing, opts Options) *RedisStore {
+	return &RedisStore{rdb: rdb, keys: keys, opts: opts}
+}
+
+func redisKey(sectionID, uuid string) string {
+	return fmt.Sprintf("qr:%s:%s", sectionID, uuid)
+}
+
+// redeemersKey es el set de alumnos que ya canjearon un UUID; vence junto
+// con el QR.
+func redeemersKey(sectionID, uuid string) string {
+	return redisKey(sectionID, uuid) + ":redeemed"
+}
+
+func (s *RedisStore) Issue(ctx context.Context, payload Payload, ttl time.Duration) (string, error) {
+	payload, encrypted, err := newPayload(s.keys, payload)
+	if err != nil {
+		return "", err
+	}
+
+	if err := s.rdb.Set(ctx, redisKey(payload.SectionID, payload.UUID), encrypted, ttl).Err(); err != nil {
+		return "", err
+	}
+
+	return encrypted, nil
+}
+
+// redeemScript revisa que el QR siga vivo y registra al alumno en el set de
+// canjes en un solo paso atómico, para que dos escaneos simultáneos no
+// puedan pasar ambos el tope.
+//
+//	KEYS[1] clave del QR, KEYS[2] set de canjes
+//	ARGV[1] alumno, ARGV[2] single-use (0/1), ARGV[3] tope (0 = sin tope)
+var redeemScript = redis.NewScript(`
+local ttl = redis.call('PTTL', KEYS[1])
+if ttl == -2 then return 'expired' end
+if ARGV[2] == '0' and ARGV[3] == '0' then return 'ok' end
+if redis.call('SISMEMBER', KEYS[2], ARGV[1]) == 1 then
+  if ARGV[2] == '1' then return 'already_redeemed' end
+  return 'ok'
+end
+local max = tonumber(ARGV[3])
+if max > 0 and redis.call('SCARD', KEYS[2]) >= max then return 'limit_reached' end
+redis.call('SADD', KEYS[2], ARGV[1])
+if ttl > 0 then redis.call('PEXPIRE', KEYS[2], ttl) end
+return 'ok'
+`)
+
+func (s *RedisStore) Redeem(ctx context.Context, payload Payload, alumnoID string) (ok bool, reason string, err error) {
+	singleUse := "0"
+	if s.opts.SingleUse {
+		singleUse = "1"
+	}
+
+	result, err := redeemScript.Run(ctx, s.rdb,
+		[]string{redisKey(payload.SectionID, payload.UUID), redeemersKey(payload.SectionID, payload.UUID)},
+		alumnoID, singleUse, strconv.Itoa(s.opts.MaxRedemptions),
+	).Text()
+	if err != nil {
+		return false, "", err
+	}
+	if result != "ok" {
+		return false, result, nil
+	}
+	return true, "", nil
+}
+
+func (s *RedisStore) Release(ctx context.Context, payload Payload, alumnoID string) error {
+	return s.rdb.SRem(ctx, redeemersKey(payload.SectionID, payload.UUID), alumnoID).Err()
+}
+
+func (s *RedisStore) Revoke(ctx context.Context, sectionID, uuid string) error {
+	return s.rdb.Del(ctx, redisKey(sectionID, uuid), redeemersKey(sectionID, uuid)).Err()
+}
@@ -0,0 +1,147 @@
+package qrcode
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"strconv"
+	"strings"
+
+	goqr "github.com/skip2/go-qrcode"
+)
+
+// Límites de RenderOptions: suficientes para proyectar en una sala o
+// imprimir sin dejar que un request pida una imagen gigante.
+const (
+	MinRenderSize = 64
+	MaxRenderSize = 2048
+	MaxMargin     = 16
+)
+
+// RenderOptions controla cómo se dibuja un QR: Size es el lado en píxeles
+// (en SVG es el tamaño por defecto, igual escala sin perder calidad), Level
+// el nivel de corrección de errores (L, M, Q o H) y Margin la zona de
+// silencio en módulos (el estándar pide 4).
+type RenderOptions struct {
+	Size   int
+	Level  string
+	Margin int
+}
+
+var DefaultRenderOptions = RenderOptions{Size: 512, Level: "M", Margin: 4}
+
+var recoveryLevels = map[string]goqr.RecoveryLevel{
+	"L": goqr.Low,
+	"M": goqr.Medium,
+	"Q": goqr.High,
+	"H": goqr.Highest,
+}
+
+// ParseRenderOptions valida los parámetros de query size, level y margin;
+// los vacíos toman el valor de DefaultRenderOptions.
+func ParseRenderOptions(size, level, margin string) (RenderOptions, error) {
+	opts := DefaultRenderOptions
+
+	if size != "" {
+		n, err := strconv.Atoi(size)
+		if err != nil || n < MinRenderSize || n > MaxRenderSize {
+			return opts, fmt.Errorf("size debe estar entre %d y %d", MinRenderSize, MaxRenderSize)
+		}
+		opts.Size = n
+	}
+	if level != "" {
+		opts.Level = strings.ToUpper(level)
+		if _, ok := recoveryLevels[opts.Level]; !ok {
+			return opts, errors.New("level debe ser L, M, Q o H")
+		}
+	}
+	if margin != "" {
+		n, err := strconv.Atoi(margin)
+		if err != nil || n < 0 || n > MaxMargin {
+			return opts, fmt.Errorf("margin debe estar entre 0 y %d", MaxMargin)
+		}
+		opts.Margin = n
+	}
+
+	return opts, nil
+}
+
+// modules codifica content y devuelve la matriz de módulos sin borde:
+// bitmap[y][x] es true si el módulo es oscuro.
+func modules(content string, opts RenderOptions) ([][]bool, error) {
+	level, ok := recoveryLevels[opts.Level]
+	if !ok {
+		return nil, errors.New("qrcode: nivel de corrección inválido")
+	}
+	q, err := goqr.New(content, level)
+	if err != nil {
+		return nil, err
+	}
+	q.DisableBorder = true
+	return q.Bitmap(), nil
+}
+
+// RenderPNG dibuja content como PNG de opts.Size x opts.Size. Cada módulo
+// ocupa un número entero de píxeles; lo que sobra se reparte como margen
+// extra para que el código quede centrado y nítido.
+func RenderPNG(w io.Writer, content string, opts RenderOptions) error {
+	bitmap, err := modules(content, opts)
+	if err != nil {
+		return err
+	}
+
+	total := len(bitmap) + 2*opts.Margin
+	scale := opts.Size / total
+	if scale < 1 {
+		return fmt.Errorf("qrcode: size %d es muy chico para %d módulos", opts.Size, total)
+	}
+	offset := (opts.Size - len(bitmap)*scale) / 2
+
+	palette := color.Palette{color.White, color.Black}
+	img := image.NewPaletted(image.Rect(0, 0, opts.Size, opts.Size), palette)
+	for y, row := range bitmap {
+		for x, dark := range row {
+			if !dark {
+				continue
+			}
+			x0, y0 := offset+x*scale, offset+y*scale
+			for py := y0; py < y0+scale; py++ {
+				for px := x0; px < x0+scale; px++ {
+					img.SetColorIndex(px, py, 1)
+				}
+			}
+		}
+	}
+
+	return png.Encode(w, img)
+}
+
+// RenderSVG dibuja content como SVG: un único path con un cuadrado por
+// módulo oscuro, en un viewBox medido en módulos.
+func RenderSVG(w io.Writer, content string, opts RenderOptions) error {
+	bitmap, err := modules(content, opts)
+	if err != nil {
+		return err
+	}
+
+	total := len(bitmap) + 2*opts.Margin
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, `<?xml version="1.0" encoding="UTF-8"?>`+"\n")
+	fmt.Fprintf(bw, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`,
+		opts.Size, opts.Size, total, total)
+	fmt.Fprintf(bw, `<rect width="%d" height="%d" fill="#fff"/><path fill="#000" d="`, total, total)
+	for y, row := range bitmap {
+		for x, dark := range row {
+			if dark {
+				fmt.Fprintf(bw, "M%d %dh1v1h-1z", x+opts.Margin, y+opts.Margin)
+			}
+		}
+	}
+	fmt.Fprint(bw, `"/></svg>`)
+
+	return bw.Flush()
+}
@@ -5,64 +5,110 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"fmt"
+	"os"
+	"strconv"
 	"time"
 
 	"github.com/go-redis/redis/v8"
 )
 
-// DefaultTTL es cuánto vive un QR emitido antes de expirar en Redis.
+// DefaultTTL es cuánto vive un QR emitido antes de expirar.
 const DefaultTTL = 15 * time.Second
 
-// Store emite y valida QRs respaldado por Redis: emitir escribe la clave
-// con TTL, validar solo confirma que sigue viva (no expiró, no fue inventada).
-type Store struct {
-	rdb *redis.Client
-}
+// Motivos por los que Redeem rechaza un QR.
+const (
+	ReasonExpired         = "expired"
+	ReasonAlreadyRedeemed = "already_redeemed"
+	ReasonLimitReached    = "limit_reached"
+)
 
-func NewStore(rdb *redis.Client) *Store {
-	return &Store{rdb: rdb}
+// Store emite y valida QRs: emitir guarda el QR con TTL, canjear confirma
+// que sigue vivo (no expiró, no fue inventado ni revocado) y, según Options,
+// lleva la cuenta de qué alumnos ya lo usaron.
+//
+// RedisStore es la implementación que se usa en producción (teacher emite y
+// student canjea, cada uno en su proceso); MemoryStore sirve para correr un
+// servicio solo en local y para pruebas.
+type Store interface {
+	// Issue le asigna al payload un UUID nuevo y la hora de emisión, lo
+	// sella, lo guarda con TTL y devuelve el QR cifrado listo para pintar.
+	// El resto de los campos (sesión, sección, módulo, profesor) los pone
+	// quien llama.
+	Issue(ctx context.Context, payload Payload, ttl time.Duration) (string, error)
+	// Redeem confirma que el payload descifrado corresponde a un QR todavía
+	// vigente y lo canjea para el alumno. Si se rechaza, devuelve ok=false y
+	// el motivo (ReasonExpired, ReasonAlreadyRedeemed o ReasonLimitReached).
+	Redeem(ctx context.Context, payload Payload, alumnoID string) (ok bool, reason string, err error)
+	// Release deshace el canje de un alumno, para cuando después de canjear
+	// no se pudo escribir la asistencia y el alumno tiene que poder reintentar.
+	Release(ctx context.Context, payload Payload, alumnoID string) error
+	// Revoke invalida un QR emitido antes de que expire.
+	Revoke(ctx context.Context, sectionID, uuid string) error
 }
 
-func redisKey(sectionID, uuid string) string {
-	return fmt.Sprintf("qr:%s:%s", sectionID, uuid)
+// Options ajusta cuánto se puede reusar un mismo QR emitido.
+//
+// Con SingleUse cada alumno puede canjear un UUID una sola vez. Con
+// MaxRedemptions > 0, un UUID deja de aceptar alumnos nuevos cuando ya lo
+// canjearon esa cantidad de alumnos distintos: así una captura reenviada al
+// grupo solo le sirve a unos pocos antes de que el QR rote.
+type Options struct {
+	SingleUse      bool
+	MaxRedemptions int
 }
 
-// Issue genera un UUID nuevo, completa el payload y lo cifra, lo guarda en
-// Redis con TTL y devuelve el QR cifrado listo para pintar.
-func (s *Store) Issue(ctx context.Context, sectionID, professorID, moduleID string, ttl time.Duration) (string, error) {
-	uuid, err := newUUID()
-	if err != nil {
-		return "", err
+// OptionsFromEnv lee QR_SINGLE_USE (true/false) y QR_MAX_REDEMPTIONS (0 =
+// sin tope). Sin variables, el QR se puede canjear libremente mientras viva.
+func OptionsFromEnv() (Options, error) {
+	var opts Options
+	if v := os.Getenv("QR_SINGLE_USE"); v != "" {
+		singleUse, err := strconv.ParseBool(v)
+		if err != nil {
+			return opts, fmt.Errorf("QR_SINGLE_USE inválido: %w", err)
+		}
+		opts.SingleUse = singleUse
 	}
-
-	payload := Payload{
-		UUID:        uuid,
-		SectionID:   sectionID,
-		ProfessorID: professorID,
-		ModuleID:    moduleID,
-		IssuedAt:    time.Now().Unix(),
+	if v := os.Getenv("QR_MAX_REDEMPTIONS"); v != "" {
+		max, err := strconv.Atoi(v)
+		if err != nil || max < 0 {
+			return opts, fmt.Errorf("QR_MAX_REDEMPTIONS inválido: %q", v)
+		}
+		opts.MaxRedemptions = max
 	}
+	return opts, nil
+}
 
-	encrypted, err := Encrypt(payload)
-	if err != nil {
-		return "", err
+// NewStoreFromEnv elige el backend según QR_STORE: "redis" (default) o
+// "memory". Con "memory" los QR viven en el proceso, así que solo sirve si
+// el mismo proceso emite y canjea (desarrollo local, pruebas). Solo cambia
+// dónde viven los QR: las sesiones de login (auth.SessionStore), el feed en
+// vivo y el limitador de intentos siguen en Redis, así que rdb sigue
+// haciendo falta.
+func NewStoreFromEnv(rdb *redis.Client, keys *KeyRing, opts Options) (Store, error) {
+	switch backend := getEnv("QR_STORE", "redis"); backend {
+	case "redis":
+		return NewRedisStore(rdb, keys, opts), nil
+	case "memory":
+		return NewMemoryStore(keys, opts), nil
+	default:
+		return nil, fmt.Errorf("QR_STORE inválido: %q (usa redis o memory)", backend)
 	}
+}
 
-	if err := s.rdb.Set(ctx, redisKey(sectionID, uuid), encrypted, ttl).Err(); err != nil {
-		return "", err
+// newPayload completa y sella el payload de un QR nuevo.
+func newPayload(keys *KeyRing, payload Payload) (Payload, string, error) {
+	uuid, err := newUUID()
+	if err != nil {
+		return Payload{}, "", err
 	}
+	payload.UUID = uuid
+	payload.IssuedAt = time.Now().Unix()
 
-	return encrypted, nil
-}
-
-// Validate confirma que el payload descifrado corresponde a un QR todavía
-// vigente (su clave no expiró en Redis).
-func (s *Store) Validate(ctx context.Context, payload Payload) (bool, error) {
-	exists, err := s.rdb.Exists(ctx, redisKey(payload.SectionID, payload.UUID)).Result()
+	encrypted, err := keys.Seal(payload)
 	if err != nil {
-		return false, err
+		return Payload{}, "", err
 	}
-	return exists == 1, nil
+	return payload, encrypted, nil
 }
 
 func newUUID() (string, error) {
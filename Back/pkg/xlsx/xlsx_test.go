@@ -0,0 +1,164 @@
+package xlsx
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"testing"
+)
+
+func TestColumnName(t *testing.T) {
+	tests := []struct {
+		i    int
+		want string
+	}{
+		{0, "A"},
+		{25, "Z"},
+		{26, "AA"},
+		{27, "AB"},
+		{51, "AZ"},
+		{52, "BA"},
+		{701, "ZZ"},
+		{702, "AAA"},
+		{16383, "XFD"}, // última columna de Excel
+	}
+	for _, tt := range tests {
+		if got := columnName(tt.i); got != tt.want {
+			t.Errorf("columnName(%d) = %q, se esperaba %q", tt.i, got, tt.want)
+		}
+	}
+}
+
+// sheetXML es lo justo de sheet1.xml para leer las celdas de vuelta.
+type sheetXML struct {
+	Rows []struct {
+		R     int `xml:"r,attr"`
+		Cells []struct {
+			Ref    string `xml:"r,attr"`
+			Type   string `xml:"t,attr"`
+			Value  string `xml:"v"`
+			Inline string `xml:"is>t"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+type workbookXML struct {
+	Sheets []struct {
+		Name string `xml:"name,attr"`
+	} `xml:"sheets>sheet"`
+}
+
+func TestWriterRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, `Curso <A&B> [1]/2`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	header := make([]any, 30)
+	for i := range header {
+		header[i] = columnName(i)
+	}
+	rows := [][]any{
+		header,
+		{`<b>"Muñoz" & 'Pérez'</b>`, 42, 87.5, nil, "=1+1"},
+	}
+	for _, row := range rows {
+		if err := w.WriteRow(row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("el .xlsx no es un zip válido: %v", err)
+	}
+	files := map[string][]byte{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[f.Name], err = io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/_rels/workbook.xml.rels", "xl/workbook.xml", "xl/worksheets/sheet1.xml"} {
+		if _, ok := files[name]; !ok {
+			t.Errorf("falta %s en el zip", name)
+			continue
+		}
+		// Todas las partes tienen que ser XML bien formado.
+		d := xml.NewDecoder(bytes.NewReader(files[name]))
+		for {
+			if _, err := d.Token(); err == io.EOF {
+				break
+			} else if err != nil {
+				t.Errorf("%s no es XML válido: %v", name, err)
+				break
+			}
+		}
+	}
+
+	var wb workbookXML
+	if err := xml.Unmarshal(files["xl/workbook.xml"], &wb); err != nil {
+		t.Fatal(err)
+	}
+	if len(wb.Sheets) != 1 || wb.Sheets[0].Name != "Curso <A&B> 12" {
+		t.Errorf("nombre de la hoja = %+v", wb.Sheets)
+	}
+
+	var sheet sheetXML
+	if err := xml.Unmarshal(files["xl/worksheets/sheet1.xml"], &sheet); err != nil {
+		t.Fatal(err)
+	}
+	if len(sheet.Rows) != 2 {
+		t.Fatalf("%d filas, se esperaban 2", len(sheet.Rows))
+	}
+
+	first := sheet.Rows[0]
+	if first.R != 1 || len(first.Cells) != 30 {
+		t.Fatalf("fila 1: r=%d con %d celdas", first.R, len(first.Cells))
+	}
+	for i, c := range first.Cells {
+		want := columnName(i)
+		if c.Ref != want+"1" || c.Inline != want {
+			t.Errorf("celda %d = %s %q, se esperaba %s1 %q", i, c.Ref, c.Inline, want, want)
+		}
+	}
+	if last := first.Cells[29].Ref; last != "AD1" {
+		t.Errorf("la columna 30 quedó como %s", last)
+	}
+
+	second := sheet.Rows[1]
+	type cell struct{ ref, typ, value string }
+	var got []cell
+	for _, c := range second.Cells {
+		value := c.Value
+		if c.Type == "inlineStr" {
+			value = c.Inline
+		}
+		got = append(got, cell{c.Ref, c.Type, value})
+	}
+	want := []cell{
+		{"A2", "inlineStr", `<b>"Muñoz" & 'Pérez'</b>`},
+		{"B2", "", "42"},
+		{"C2", "", "87.5"},
+		// nil no escribe celda: D2 no aparece.
+		{"E2", "inlineStr", "=1+1"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("fila 2 = %+v, se esperaba %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("fila 2, celda %d = %+v, se esperaba %+v", i, got[i], want[i])
+		}
+	}
+}
@@ -3,12 +3,17 @@ package main
 import (
 	"log"
 	"net/http"
+	"os"
 	"strings"
 
+	"mysqr/pkg/authmw"
+	"mysqr/pkg/clock"
 	"mysqr/pkg/httpcors"
 	"mysqr/qr/pkg/auth"
+	"mysqr/qr/pkg/database"
 
 	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
 )
 
 func main() {
@@ -16,10 +21,66 @@ func main() {
 	r := gin.Default()
 	r.Use(httpcors.Middleware())
 
+	// c.ClientIP() (bloqueo por IP y auditoría de LogIn) solo lee
+	// X-Forwarded-For si la conexión viene de un proxy listado en
+	// TRUSTED_PROXIES (IPs o CIDR separados por coma, el Traefik del
+	// compose). Sin la variable no se confía en nadie y se usa la IP de la
+	// conexión: si no, cualquiera rota el header y nunca llega al bloqueo.
+	if err := r.SetTrustedProxies(splitList(os.Getenv("TRUSTED_PROXIES"))); err != nil {
+		log.Fatalf("TRUSTED_PROXIES inválido: %v", err)
+	}
+
+	// Un solo pool para todo el servicio: login y auditoría lo comparten.
+	db, err := database.CreateConnection()
+	if err != nil {
+		log.Fatalf("Error al conectar a la base de datos: %v", err)
+	}
+	defer db.Close()
+	users := database.NewStore(db)
+
+	rdb := redis.NewClient(&redis.Options{
+		Addr: getEnv("REDIS_HOST", "localhost") + ":" + getEnv("REDIS_PORT", "6379"),
+	})
+	keys, err := auth.LoadKeyRing(getEnv("JWT_KEYS_DIR", "keys"), os.Getenv("JWT_ACTIVE_KID"))
+	if err != nil {
+		log.Fatalf("Error al cargar las llaves JWT: %v", err)
+	}
+	// Los filtros por día del historial de logins son días de la
+	// institución.
+	clk, err := clock.FromEnv()
+	if err != nil {
+		log.Fatalf("Error al leer la zona horaria de la institución: %v", err)
+	}
+
+	sessions := auth.NewSessionStore(rdb)
+	verifier := auth.NewVerifier(keys, sessions)
+
+	// Los contadores de intentos viven en Redis para que todas las réplicas
+	// compartan el bloqueo; LOGIN_LIMITER=memory sirve para correr sin Redis.
+	var counters auth.CounterStore = auth.NewRedisCounterStore(rdb)
+	if getEnv("LOGIN_LIMITER", "redis") == "memory" {
+		counters = auth.NewMemoryCounterStore()
+	}
+	limiter := auth.NewLoginLimiter(counters, auth.DefaultLoginPolicy)
+
 	// Endpoint de login
+	login := auth.LoginHandler(users, keys, sessions, limiter)
 	r.POST("/login", func(c *gin.Context) {
 		log.Printf("Procesando login en: %s", c.Request.URL.Path)
-		auth.LoginHandler(c)
+		login(c)
+	})
+
+	// Canjea el refresh token por un par nuevo cuando vence el access token.
+	r.POST("/refresh", auth.RefreshHandler(keys, sessions))
+
+	// Cierra la sesión (o todas las del usuario con "all": true).
+	r.POST("/logout", auth.LogoutHandler(verifier, sessions))
+
+	// Llaves públicas para que los demás servicios (y terceros) verifiquen los
+	// tokens sin compartir ningún secreto.
+	r.GET("/.well-known/jwks.json", func(c *gin.Context) {
+		c.Header("Cache-Control", "public, max-age=300")
+		c.JSON(http.StatusOK, keys.JWKS())
 	})
 
 	// El Front lo llama al arrancar para saber si la sesión guardada sigue viva.
@@ -30,7 +91,7 @@ func main() {
 			return
 		}
 
-		claims, err := auth.ValidateToken(strings.TrimPrefix(header, "Bearer "))
+		claims, err := verifier.Verify(c.Request.Context(), strings.TrimPrefix(header, "Bearer "))
 		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Token inválido o expirado"})
 			return
@@ -43,8 +104,32 @@ func main() {
 		})
 	})
 
+	admin := r.Group("/admin", authmw.RequireAuth(verifier), authmw.RequireRole("admin"))
+	// Levanta el bloqueo por intentos fallidos de una cuenta o IP.
+	admin.POST("/unlock", auth.UnlockHandler(limiter))
+	// Historial de intentos de login (tabla LogIn) por usuario o rango de fechas.
+	admin.GET("/logins", auth.LoginHistoryHandler(users, clk.Location))
+
 	log.Printf("Iniciando servidor QR (auth) en :8087")
 	if err := r.Run(":8087"); err != nil {
 		log.Fatal(err)
 	}
 }
+
+func getEnv(key, defaultValue string) string {
+	if v, ok := os.LookupEnv(key); ok {
+		return v
+	}
+	return defaultValue
+}
+
+// splitList separa una lista separada por comas, sin espacios ni vacíos.
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -1,10 +1,13 @@
 package auth
 
 import (
-	"database/sql"
+	"context"
 	"errors"
 	"log"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"mysqr/qr/pkg/database"
@@ -13,23 +16,22 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 )
 
-var (
-	secretKey = []byte("mysqr-secret-key-2024") // En producción, esto debería venir de variables de entorno
-)
-
 type LoginRequest struct {
 	Username string `json:"username"`
 	Password string `json:"password"`
 	Rol      string `json:"rol"`
+	DeviceID string `json:"device_id"`
 }
 
 type LoginResponse struct {
-	Token      string `json:"token"`
-	Rol        string `json:"rol"`
-	ID         int    `json:"id"`
-	Rut        int    `json:"rut"`
-	ProfesorID *int   `json:"profesor_id,omitempty"`
-	AlumnoID   *int   `json:"alumno_id,omitempty"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"`
+	Rol          string `json:"rol"`
+	ID           int    `json:"id"`
+	Rut          int    `json:"rut"`
+	ProfesorID   *int   `json:"profesor_id,omitempty"`
+	AlumnoID     *int   `json:"alumno_id,omitempty"`
 }
 
 type Claims struct {
@@ -38,52 +40,62 @@ type Claims struct {
 	Rut        int    `json:"rut"`
 	ProfesorID *int   `json:"profesor_id,omitempty"`
 	AlumnoID   *int   `json:"alumno_id,omitempty"`
+	SessionID  string `json:"sid"`
 	jwt.RegisteredClaims
 }
 
-func ValidateLogin(username, password, rol string, db *sql.DB) (*LoginResponse, error) {
-	user, err := database.ValidateUser(username, password, rol)
+func ValidateLogin(users *database.Store, username, password, rol string) (*database.User, error) {
+	return users.ValidateUser(username, password, rol)
+}
+
+// issueTokens firma un access token corto para la sesión y lo devuelve junto
+// al refresh token con el que se renueva.
+func issueTokens(keys *KeyRing, sess Session, refreshToken string) (*LoginResponse, error) {
+	token, err := generateToken(keys, sess)
 	if err != nil {
 		return nil, err
 	}
 
-	return generateToken(user.ID, user.Role, user.Rut, user.ProfesorID, user.AlumnoID)
+	return &LoginResponse{
+		Token:        token,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int(AccessTokenTTL.Seconds()),
+		Rol:          sess.Rol,
+		ID:           sess.UserID,
+		Rut:          sess.Rut,
+		ProfesorID:   sess.ProfesorID,
+		AlumnoID:     sess.AlumnoID,
+	}, nil
 }
 
-func generateToken(userID int, rol string, rut int, profesorID, alumnoID *int) (*LoginResponse, error) {
+func generateToken(keys *KeyRing, sess Session) (string, error) {
 	claims := &Claims{
-		UserID:     userID,
-		Rol:        rol,
-		Rut:        rut,
-		ProfesorID: profesorID,
-		AlumnoID:   alumnoID,
+		UserID:     sess.UserID,
+		Rol:        sess.Rol,
+		Rut:        sess.Rut,
+		ProfesorID: sess.ProfesorID,
+		AlumnoID:   sess.AlumnoID,
+		SessionID:  sess.ID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(AccessTokenTTL)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString(secretKey)
-	if err != nil {
-		return nil, err
-	}
-
-	return &LoginResponse{
-		Token:      tokenString,
-		Rol:        rol,
-		ID:         userID,
-		Rut:        rut,
-		ProfesorID: profesorID,
-		AlumnoID:   alumnoID,
-	}, nil
+	return keys.Sign(claims)
 }
 
-func ValidateToken(tokenString string) (*Claims, error) {
+// ValidateToken verifica firma (EdDSA, con la llave que indica el kid del
+// header) y vigencia de un access token.
+func ValidateToken(ctx context.Context, tokenString string, keys KeySource) (*Claims, error) {
 	claims := &Claims{}
 	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-		return secretKey, nil
-	})
+		kid, _ := token.Header["kid"].(string)
+		if kid == "" {
+			return nil, ErrUnknownKey
+		}
+		return keys.PublicKey(ctx, kid)
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodEdDSA.Alg()}))
 
 	if err != nil {
 		return nil, err
@@ -96,31 +108,348 @@ func ValidateToken(tokenString string) (*Claims, error) {
 	return claims, nil
 }
 
-func LoginHandler(c *gin.Context) {
-	var req LoginRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		log.Printf("Error al parsear JSON: %v", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Cuerpo de la solicitud inválido"})
-		return
-	}
+// ErrSessionRevoked se devuelve cuando el token es válido pero su sesión fue
+// cerrada (logout o revocación).
+var ErrSessionRevoked = errors.New("sesión revocada")
 
-	log.Printf("Intento de login - Usuario: %s, Rol: %s", req.Username, req.Rol)
+// RevocationChecker es lo que Verifier consulta para saber si la sesión de
+// un token fue cerrada. En producción es el SessionStore (Redis); otro
+// backend solo tiene que responder IsRevoked.
+type RevocationChecker interface {
+	IsRevoked(ctx context.Context, sid string) (bool, error)
+}
+
+// Verifier valida access tokens: firma y vigencia (ValidateToken) más la
+// lista de revocación de sesiones.
+type Verifier struct {
+	keys     KeySource
+	sessions RevocationChecker
+}
+
+func NewVerifier(keys KeySource, sessions RevocationChecker) *Verifier {
+	return &Verifier{keys: keys, sessions: sessions}
+}
 
-	db, err := database.CreateConnection()
+// Verify valida el token y confirma que su sesión siga abierta.
+func (v *Verifier) Verify(ctx context.Context, tokenString string) (*Claims, error) {
+	claims, err := ValidateToken(ctx, tokenString, v.keys)
 	if err != nil {
-		log.Printf("Error de conexión a la base de datos: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error de conexión a la base de datos"})
-		return
+		return nil, err
+	}
+
+	// Los tokens anteriores a las sesiones no traen sid y no se pueden revocar.
+	if claims.SessionID == "" {
+		return nil, ErrSessionRevoked
 	}
-	defer db.Close()
 
-	response, err := ValidateLogin(req.Username, req.Password, req.Rol, db)
+	revoked, err := v.sessions.IsRevoked(ctx, claims.SessionID)
 	if err != nil {
-		log.Printf("Error de validación: %v", err)
-		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
-		return
+		return nil, err
+	}
+	if revoked {
+		return nil, ErrSessionRevoked
+	}
+
+	return claims, nil
+}
+
+// LoginHandler valida credenciales, abre una sesión en Redis y responde con
+// el par access/refresh token. Cuenta los fallos en el limiter y rechaza con
+// 429 mientras el usuario o la IP estén bloqueados.
+func LoginHandler(users *database.Store, keys *KeyRing, sessions *SessionStore, limiter *LoginLimiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req LoginRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			log.Printf("Error al parsear JSON: %v", err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Cuerpo de la solicitud inválido"})
+			return
+		}
+
+		log.Printf("Intento de login - Usuario: %s, Rol: %s", req.Username, req.Rol)
+
+		ctx := c.Request.Context()
+		ip := c.ClientIP()
+
+		audit := database.LoginEvent{
+			Username:      req.Username,
+			Rol:           req.Rol,
+			IP:            ip,
+			UserAgent:     c.Request.UserAgent(),
+			DispositivoID: req.DeviceID,
+		}
+		if audit.DispositivoID == "" {
+			audit.DispositivoID = c.GetHeader("X-Device-ID")
+		}
+
+		wait, err := limiter.Locked(ctx, req.Username, ip)
+		if err != nil {
+			log.Printf("Error al consultar bloqueos de login: %v", err)
+			recordLogin(users, audit, database.LoginError)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error interno del servidor"})
+			return
+		}
+		if wait > 0 {
+			recordLogin(users, audit, database.LoginBloqueado)
+			seconds := int(math.Ceil(wait.Seconds()))
+			c.Header("Retry-After", strconv.Itoa(seconds))
+			c.JSON(http.StatusTooManyRequests, gin.H{
+				"error":       "Demasiados intentos fallidos, espera antes de volver a intentar",
+				"retry_after": seconds,
+			})
+			return
+		}
+
+		user, err := ValidateLogin(users, req.Username, req.Password, req.Rol)
+		if errors.Is(err, database.ErrInvalidCredentials) {
+			log.Printf("Credenciales inválidas para usuario: %s desde %s", req.Username, ip)
+			if err := limiter.Fail(ctx, req.Username, ip); err != nil {
+				log.Printf("Error al registrar intento fallido: %v", err)
+			}
+			recordLogin(users, audit, database.LoginCredencialesInvalidas)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Credenciales inválidas"})
+			return
+		}
+		if err != nil {
+			log.Printf("Error de validación: %v", err)
+			recordLogin(users, audit, database.LoginError)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error interno del servidor"})
+			return
+		}
+
+		audit.Rol = user.Role
+		audit.Rut = &user.Rut
+
+		if err := limiter.Succeed(ctx, req.Username); err != nil {
+			log.Printf("Error al limpiar intentos fallidos: %v", err)
+		}
+
+		sess, refresh, err := sessions.Create(ctx, Session{
+			UserID:     user.ID,
+			Rol:        user.Role,
+			Rut:        user.Rut,
+			ProfesorID: user.ProfesorID,
+			AlumnoID:   user.AlumnoID,
+		})
+		if err != nil {
+			log.Printf("Error al crear sesión: %v", err)
+			recordLogin(users, audit, database.LoginError)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo iniciar la sesión"})
+			return
+		}
+
+		response, err := issueTokens(keys, sess, refresh)
+		if err != nil {
+			log.Printf("Error al firmar token: %v", err)
+			recordLogin(users, audit, database.LoginError)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo iniciar la sesión"})
+			return
+		}
+
+		recordLogin(users, audit, database.LoginOK)
+		log.Printf("Login exitoso para usuario: %s", req.Username)
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+// recordLogin deja el intento en la tabla LogIn. Un fallo de auditoría no
+// cambia la respuesta del login, solo se loguea.
+func recordLogin(users *database.Store, ev database.LoginEvent, motivo string) {
+	ev.Motivo = motivo
+	ev.Exitoso = motivo == database.LoginOK
+	if err := users.RecordLogin(ev); err != nil {
+		log.Printf("Error al registrar login en auditoría: %v", err)
+	}
+}
+
+// RefreshHandler canjea un refresh token por un par nuevo (rotación): el
+// refresh token recibido deja de servir.
+func RefreshHandler(keys *KeyRing, sessions *SessionStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			RefreshToken string `json:"refresh_token" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Cuerpo de la solicitud inválido"})
+			return
+		}
+
+		sess, refresh, err := sessions.Rotate(c.Request.Context(), req.RefreshToken)
+		if errors.Is(err, ErrInvalidRefreshToken) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Sesión expirada, inicia sesión nuevamente"})
+			return
+		}
+		if err != nil {
+			log.Printf("Error al rotar refresh token: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo renovar la sesión"})
+			return
+		}
+
+		response, err := issueTokens(keys, sess, refresh)
+		if err != nil {
+			log.Printf("Error al firmar token: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo renovar la sesión"})
+			return
+		}
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+// LogoutHandler revoca la sesión del token presentado. Acepta el access token
+// (Authorization: Bearer) o, si ya expiró, el refresh token en el body. Con
+// "all": true cierra todas las sesiones del usuario.
+func LogoutHandler(verifier *Verifier, sessions *SessionStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			RefreshToken string `json:"refresh_token"`
+			All          bool   `json:"all"`
+		}
+		if c.Request.ContentLength != 0 {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Cuerpo de la solicitud inválido"})
+				return
+			}
+		}
+
+		ctx := c.Request.Context()
+		var sid string
+		var userID int
+		if header := c.GetHeader("Authorization"); strings.HasPrefix(header, "Bearer ") {
+			if claims, err := verifier.Verify(ctx, strings.TrimPrefix(header, "Bearer ")); err == nil {
+				sid, userID = claims.SessionID, claims.UserID
+			}
+		}
+		if sid == "" && req.RefreshToken != "" {
+			if sess, err := sessions.Lookup(ctx, req.RefreshToken); err == nil {
+				sid, userID = sess.ID, sess.UserID
+			}
+		}
+		if sid == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Token inválido o expirado"})
+			return
+		}
+
+		var err error
+		if req.All {
+			err = sessions.RevokeAll(ctx, userID)
+		} else {
+			err = sessions.Revoke(ctx, sid)
+		}
+		if err != nil {
+			log.Printf("Error al revocar sesión: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo cerrar la sesión"})
+			return
+		}
+
+		c.Status(http.StatusNoContent)
 	}
+}
+
+// UnlockHandler es el endpoint de administración para levantar a mano el
+// bloqueo de una cuenta (y opcionalmente de una IP).
+func UnlockHandler(limiter *LoginLimiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Username string `json:"username"`
+			IP       string `json:"ip"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil || (req.Username == "" && req.IP == "") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Indica username o ip"})
+			return
+		}
 
-	log.Printf("Login exitoso para usuario: %s", req.Username)
-	c.JSON(http.StatusOK, response)
+		ctx := c.Request.Context()
+		if req.Username != "" {
+			if err := limiter.UnlockUser(ctx, req.Username); err != nil {
+				log.Printf("Error al desbloquear usuario %s: %v", req.Username, err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo desbloquear"})
+				return
+			}
+		}
+		if req.IP != "" {
+			if err := limiter.UnlockIP(ctx, req.IP); err != nil {
+				log.Printf("Error al desbloquear IP %s: %v", req.IP, err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo desbloquear"})
+				return
+			}
+		}
+
+		log.Printf("Desbloqueo manual - Usuario: %q, IP: %q", req.Username, req.IP)
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// LoginHistoryHandler busca en el historial de logins para investigar, por
+// ejemplo, una asistencia disputada. Filtros por query: username, rut,
+// exitoso (true/false), desde y hasta (RFC 3339 o AAAA-MM-DD; hasta es
+// exclusivo), limit (máx. 500) y offset. Una fecha sola es ese día en la
+// zona de la institución (loc, INSTITUTION_TZ), no en la del contenedor.
+func LoginHistoryHandler(users *database.Store, loc *time.Location) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		filter := database.LoginFilter{
+			Username: c.Query("username"),
+			Limit:    100,
+		}
+
+		if v := c.Query("rut"); v != "" {
+			rut, err := strconv.Atoi(v)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "rut inválido"})
+				return
+			}
+			filter.Rut = &rut
+		}
+		if v := c.Query("exitoso"); v != "" {
+			exitoso, err := strconv.ParseBool(v)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "exitoso inválido"})
+				return
+			}
+			filter.Exitoso = &exitoso
+		}
+		for param, dst := range map[string]**time.Time{"desde": &filter.Desde, "hasta": &filter.Hasta} {
+			v := c.Query(param)
+			if v == "" {
+				continue
+			}
+			t, err := parseDateParam(v, loc)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": param + " inválido"})
+				return
+			}
+			*dst = &t
+		}
+		if v := c.Query("limit"); v != "" {
+			limit, err := strconv.Atoi(v)
+			if err != nil || limit <= 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "limit inválido"})
+				return
+			}
+			filter.Limit = min(limit, 500)
+		}
+		if v := c.Query("offset"); v != "" {
+			offset, err := strconv.Atoi(v)
+			if err != nil || offset < 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "offset inválido"})
+				return
+			}
+			filter.Offset = offset
+		}
+
+		events, err := users.SearchLogins(filter)
+		if err != nil {
+			log.Printf("Error al buscar historial de logins: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al buscar historial de logins"})
+			return
+		}
+		c.JSON(http.StatusOK, events)
+	}
+}
+
+// parseDateParam lee un instante RFC 3339 o un día AAAA-MM-DD, que empieza
+// a medianoche en loc.
+func parseDateParam(v string, loc *time.Location) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation("2006-01-02", v, loc)
 }
@@ -0,0 +1,39 @@
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDateParam(t *testing.T) {
+	santiago, err := time.LoadLocation("America/Santiago")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		in      string
+		want    time.Time
+		wantErr bool
+	}{
+		// Marzo en Santiago es UTC-3: el día empieza a las 03:00 UTC.
+		{in: "2026-03-09", want: time.Date(2026, 3, 9, 3, 0, 0, 0, time.UTC)},
+		// Julio es UTC-4.
+		{in: "2026-07-01", want: time.Date(2026, 7, 1, 4, 0, 0, 0, time.UTC)},
+		{in: "2026-03-09T10:00:00Z", want: time.Date(2026, 3, 9, 10, 0, 0, 0, time.UTC)},
+		{in: "2026-03-09T10:00:00-03:00", want: time.Date(2026, 3, 9, 13, 0, 0, 0, time.UTC)},
+		{in: "09-03-2026", wantErr: true},
+		{in: "ayer", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseDateParam(tt.in, santiago)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseDateParam(%q) = %v, se esperaba error", tt.in, got)
+			}
+			continue
+		}
+		if err != nil || !got.Equal(tt.want) {
+			t.Errorf("parseDateParam(%q) = %v, %v; se esperaba %v", tt.in, got, err, tt.want)
+		}
+	}
+}
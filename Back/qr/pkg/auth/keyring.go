@@ -0,0 +1,352 @@
+package auth
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/sync/singleflight"
+)
+
+// ErrUnknownKey se devuelve cuando el token trae un kid que ninguna llave
+// conocida respalda.
+var ErrUnknownKey = errors.New("llave de firma desconocida")
+
+// KeySource entrega la llave pública con la que se verifica un token según
+// el kid de su header. La implementa el KeyRing del servicio qr (llaves
+// locales) y RemoteKeySet en el resto de los servicios (JWKS por HTTP).
+type KeySource interface {
+	PublicKey(ctx context.Context, kid string) (ed25519.PublicKey, error)
+}
+
+// KeyRing guarda las llaves Ed25519 del servicio qr: una activa con la que
+// se firma y todas las que todavía sirven para verificar. Durante una
+// rotación conviven la llave nueva y la anterior hasta que expiren los
+// tokens firmados con esta última.
+type KeyRing struct {
+	activeKID string
+	private   map[string]ed25519.PrivateKey
+	public    map[string]ed25519.PublicKey
+}
+
+// LoadKeyRing lee las llaves de dir: cada "<kid>.pem" es una llave privada
+// Ed25519 (PKCS#8) y cada "<kid>.pub.pem" una pública (PKIX) de una llave
+// retirada que solo se usa para verificar. La llave activa es activeKID o,
+// si viene vacío, el kid privado mayor en orden lexicográfico (conviene
+// nombrarlas por fecha, ej. "2026-10").
+//
+// Si dir no tiene llaves se genera una efímera para desarrollo: los tokens
+// dejan de servir cada vez que se reinicia el servicio. Con
+// APP_ENV=production eso es un error, igual que falte ENCRYPTION_KEY.
+func LoadKeyRing(dir, activeKID string) (*KeyRing, error) {
+	k := &KeyRing{
+		private: map[string]ed25519.PrivateKey{},
+		public:  map[string]ed25519.PublicKey{},
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".pem") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+
+		if kid, ok := strings.CutSuffix(name, ".pub.pem"); ok {
+			pub, err := parsePublicKey(data)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", name, err)
+			}
+			k.public[kid] = pub
+			continue
+		}
+
+		kid := strings.TrimSuffix(name, ".pem")
+		priv, err := parsePrivateKey(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		k.private[kid] = priv
+		k.public[kid] = priv.Public().(ed25519.PublicKey)
+	}
+
+	if len(k.private) == 0 {
+		if os.Getenv("APP_ENV") == "production" {
+			return nil, fmt.Errorf("no hay llaves JWT en %q y APP_ENV=production (generar con make jwt-key)", dir)
+		}
+		log.Printf("No hay llaves JWT en %q: usando una llave efímera de desarrollo", dir)
+		return newEphemeralKeyRing()
+	}
+
+	if activeKID == "" {
+		kids := make([]string, 0, len(k.private))
+		for kid := range k.private {
+			kids = append(kids, kid)
+		}
+		sort.Strings(kids)
+		activeKID = kids[len(kids)-1]
+	}
+	if _, ok := k.private[activeKID]; !ok {
+		return nil, fmt.Errorf("la llave activa %q no está en %s", activeKID, dir)
+	}
+	k.activeKID = activeKID
+
+	return k, nil
+}
+
+func newEphemeralKeyRing() (*KeyRing, error) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return nil, err
+	}
+	kid := "dev-" + hex.EncodeToString(suffix)
+
+	return &KeyRing{
+		activeKID: kid,
+		private:   map[string]ed25519.PrivateKey{kid: priv},
+		public:    map[string]ed25519.PublicKey{kid: pub},
+	}, nil
+}
+
+func parsePrivateKey(data []byte) (ed25519.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("PEM inválido")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	priv, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, errors.New("la llave no es Ed25519")
+	}
+	return priv, nil
+}
+
+func parsePublicKey(data []byte) (ed25519.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("PEM inválido")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	pub, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, errors.New("la llave no es Ed25519")
+	}
+	return pub, nil
+}
+
+// Sign firma los claims con la llave activa y pone su kid en el header.
+func (k *KeyRing) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodEdDSA, claims)
+	token.Header["kid"] = k.activeKID
+	return token.SignedString(k.private[k.activeKID])
+}
+
+func (k *KeyRing) PublicKey(_ context.Context, kid string) (ed25519.PublicKey, error) {
+	pub, ok := k.public[kid]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	return pub, nil
+}
+
+// JWK es una llave pública Ed25519 en formato JSON Web Key (RFC 8037).
+type JWK struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+}
+
+// JWKS es el documento que sirve GET /.well-known/jwks.json.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS publica todas las llaves de verificación, incluida la activa.
+func (k *KeyRing) JWKS() JWKS {
+	kids := make([]string, 0, len(k.public))
+	for kid := range k.public {
+		kids = append(kids, kid)
+	}
+	sort.Strings(kids)
+
+	set := JWKS{Keys: make([]JWK, 0, len(kids))}
+	for _, kid := range kids {
+		set.Keys = append(set.Keys, JWK{
+			Kty: "OKP",
+			Crv: "Ed25519",
+			X:   base64.RawURLEncoding.EncodeToString(k.public[kid]),
+			Kid: kid,
+			Use: "sig",
+			Alg: "EdDSA",
+		})
+	}
+	return set
+}
+
+// RemoteKeySet verifica tokens con las llaves publicadas por el servicio qr
+// en su JWKS. Las guarda en memoria y vuelve a pedirlas cuando vencen o
+// cuando aparece un kid desconocido (la llave recién rotada), sin pegarle al
+// endpoint más de una vez cada minRefresh, haya respondido o no.
+//
+// El pedido se hace fuera del mutex y uno solo a la vez (singleflight): si
+// qr no responde, los requests concurrentes no hacen fila detrás del timeout
+// del cliente, y mientras no toque reintentar se sigue con lo que había.
+type RemoteKeySet struct {
+	url    string
+	client *http.Client
+	group  singleflight.Group
+
+	mu          sync.Mutex
+	keys        map[string]ed25519.PublicKey
+	fetchedAt   time.Time // última respuesta válida
+	attemptedAt time.Time // último intento, haya salido bien o no
+	lastErr     error
+}
+
+const (
+	jwksMaxAge     = 10 * time.Minute
+	jwksMinRefresh = 30 * time.Second
+)
+
+func NewRemoteKeySet(url string) *RemoteKeySet {
+	return &RemoteKeySet{
+		url:    url,
+		client: &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+func (r *RemoteKeySet) PublicKey(ctx context.Context, kid string) (ed25519.PublicKey, error) {
+	r.mu.Lock()
+	pub, ok := r.keys[kid]
+	fresh := time.Since(r.fetchedAt) < jwksMaxAge
+	throttled := time.Since(r.attemptedAt) < jwksMinRefresh
+	known, lastErr := r.keys != nil, r.lastErr
+	r.mu.Unlock()
+
+	switch {
+	case ok && fresh:
+		return pub, nil
+	case throttled && ok:
+		// Se intentó refrescar hace poco y falló: la llave vencida sigue
+		// sirviendo para no tumbar a los demás servicios.
+		return pub, nil
+	case throttled && known:
+		return nil, ErrUnknownKey
+	case throttled:
+		return nil, fmt.Errorf("JWKS no disponible: %w", lastErr)
+	}
+
+	// El pedido no depende del ctx de quien llegó primero: lo comparten
+	// todos los que esperan, y cada uno deja de esperar con su propio ctx.
+	ch := r.group.DoChan("jwks", func() (any, error) {
+		return nil, r.fetch(context.Background())
+	})
+	var err error
+	select {
+	case res := <-ch:
+		err = res.Err
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	if err != nil {
+		if ok {
+			log.Printf("No se pudo refrescar el JWKS (%v), usando llaves en caché", err)
+			return pub, nil
+		}
+		return nil, err
+	}
+
+	r.mu.Lock()
+	pub, ok = r.keys[kid]
+	r.mu.Unlock()
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	return pub, nil
+}
+
+// fetch pide el JWKS y reemplaza las llaves. Registra el intento aunque
+// falle, para que el próximo espere minRefresh.
+func (r *RemoteKeySet) fetch(ctx context.Context) error {
+	keys, err := r.download(ctx)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.attemptedAt = time.Now()
+	r.lastErr = err
+	if err != nil {
+		return err
+	}
+	r.keys = keys
+	r.fetchedAt = r.attemptedAt
+	return nil
+}
+
+func (r *RemoteKeySet) download(ctx context.Context) (map[string]ed25519.PublicKey, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("JWKS respondió %d", resp.StatusCode)
+	}
+
+	var set JWKS
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]ed25519.PublicKey, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Kty != "OKP" || jwk.Crv != "Ed25519" {
+			continue
+		}
+		x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			continue
+		}
+		keys[jwk.Kid] = ed25519.PublicKey(x)
+	}
+	return keys, nil
+}
@@ -0,0 +1,125 @@
+package auth
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// jwksServer publica pub como "k1" mientras up sea verdadero; si no,
+// responde 503. hits cuenta los pedidos.
+type jwksServer struct {
+	*httptest.Server
+	up   atomic.Bool
+	hits atomic.Int32
+	wait chan struct{} // si no es nil, cada pedido espera a que se cierre
+}
+
+func newJWKSServer(t *testing.T, pub ed25519.PublicKey) *jwksServer {
+	t.Helper()
+	s := &jwksServer{}
+	s.up.Store(true)
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.hits.Add(1)
+		if s.wait != nil {
+			<-s.wait
+		}
+		if !s.up.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		json.NewEncoder(w).Encode(JWKS{Keys: []JWK{{
+			Kty: "OKP", Crv: "Ed25519", Kid: "k1", Use: "sig", Alg: "EdDSA",
+			X: base64.RawURLEncoding.EncodeToString(pub),
+		}}})
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func TestRemoteKeySetBackoff(t *testing.T) {
+	ctx := context.Background()
+	pub, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := newJWKSServer(t, pub)
+	r := NewRemoteKeySet(srv.URL)
+
+	if got, err := r.PublicKey(ctx, "k1"); err != nil || !got.Equal(pub) {
+		t.Fatalf("PublicKey = %v, %v", got, err)
+	}
+	if _, err := r.PublicKey(ctx, "k2"); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("kid desconocido = %v", err)
+	}
+	if n := srv.hits.Load(); n != 1 {
+		t.Fatalf("%d pedidos al JWKS, un kid desconocido justo después de refrescar no debe pedir otro", n)
+	}
+
+	// Caché vencido y qr caído: se sigue con la llave en caché y el intento
+	// fallido cuenta para minRefresh.
+	srv.up.Store(false)
+	r.mu.Lock()
+	r.fetchedAt = time.Now().Add(-jwksMaxAge)
+	r.attemptedAt = r.fetchedAt
+	r.mu.Unlock()
+	for range 5 {
+		if got, err := r.PublicKey(ctx, "k1"); err != nil || !got.Equal(pub) {
+			t.Fatalf("PublicKey con qr caído = %v, %v", got, err)
+		}
+	}
+	if n := srv.hits.Load(); n != 2 {
+		t.Errorf("%d pedidos al JWKS con qr caído, se esperaba uno solo por minRefresh", n)
+	}
+}
+
+func TestRemoteKeySetSingleFetch(t *testing.T) {
+	pub, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := newJWKSServer(t, pub)
+	srv.wait = make(chan struct{})
+	r := NewRemoteKeySet(srv.URL)
+
+	// Muchos requests a la vez con el caché vacío comparten un pedido.
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := r.PublicKey(context.Background(), "k1")
+			errs <- err
+		}()
+	}
+	for srv.hits.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	// Mientras el pedido está colgado, quien tenga apuro se va con su ctx.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := r.PublicKey(ctx, "k1"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("PublicKey con ctx vencido = %v", err)
+	}
+
+	close(srv.wait)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+	if n := srv.hits.Load(); n != 1 {
+		t.Errorf("%d pedidos al JWKS, se esperaba uno compartido", n)
+	}
+}
@@ -0,0 +1,244 @@
+package auth
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// CounterStore guarda los contadores de intentos fallidos y las marcas de
+// bloqueo del LoginLimiter. Hay una implementación sobre Redis (la que se
+// usa con varias réplicas) y otra en memoria para correr sin Redis.
+type CounterStore interface {
+	// Incr suma uno a key y devuelve el total. Si la clave no existía, vence
+	// en ttl.
+	Incr(ctx context.Context, key string, ttl time.Duration) (int64, error)
+	// Set deja key viva durante ttl.
+	Set(ctx context.Context, key string, ttl time.Duration) error
+	// TTL devuelve cuánto le queda a key, o 0 si no existe.
+	TTL(ctx context.Context, key string) (time.Duration, error)
+	Del(ctx context.Context, keys ...string) error
+}
+
+// LoginPolicy define cuántos fallos se toleran y cuánto dura el bloqueo.
+// Cada bloqueo sucesivo dura el doble que el anterior (hasta MaxLockout);
+// el contador de bloqueos se olvida tras StrikeWindow sin bloqueos nuevos.
+type LoginPolicy struct {
+	MaxUserFailures int64
+	MaxIPFailures   int64
+	FailureWindow   time.Duration
+	BaseLockout     time.Duration
+	MaxLockout      time.Duration
+	StrikeWindow    time.Duration
+}
+
+var DefaultLoginPolicy = LoginPolicy{
+	MaxUserFailures: 5,
+	MaxIPFailures:   20,
+	FailureWindow:   15 * time.Minute,
+	BaseLockout:     time.Minute,
+	MaxLockout:      time.Hour,
+	StrikeWindow:    24 * time.Hour,
+}
+
+// LoginLimiter frena la fuerza bruta contra /login contando fallos por
+// usuario y por IP.
+type LoginLimiter struct {
+	store  CounterStore
+	policy LoginPolicy
+}
+
+func NewLoginLimiter(store CounterStore, policy LoginPolicy) *LoginLimiter {
+	return &LoginLimiter{store: store, policy: policy}
+}
+
+func userSubject(username string) string { return "user:" + strings.ToLower(username) }
+func ipSubject(ip string) string         { return "ip:" + ip }
+
+func failuresKey(subject string) string { return "login:failures:" + subject }
+func lockKey(subject string) string     { return "login:lock:" + subject }
+func strikesKey(subject string) string  { return "login:strikes:" + subject }
+
+// Locked devuelve cuánto falta para que se levante el bloqueo del usuario o
+// de la IP (el mayor de los dos), o 0 si ninguno está bloqueado.
+func (l *LoginLimiter) Locked(ctx context.Context, username, ip string) (time.Duration, error) {
+	var wait time.Duration
+	for _, subject := range []string{userSubject(username), ipSubject(ip)} {
+		ttl, err := l.store.TTL(ctx, lockKey(subject))
+		if err != nil {
+			return 0, err
+		}
+		if ttl > wait {
+			wait = ttl
+		}
+	}
+	return wait, nil
+}
+
+// Fail registra un intento fallido y, si se pasó el umbral, bloquea al
+// usuario o a la IP.
+func (l *LoginLimiter) Fail(ctx context.Context, username, ip string) error {
+	if err := l.fail(ctx, userSubject(username), l.policy.MaxUserFailures); err != nil {
+		return err
+	}
+	return l.fail(ctx, ipSubject(ip), l.policy.MaxIPFailures)
+}
+
+func (l *LoginLimiter) fail(ctx context.Context, subject string, max int64) error {
+	n, err := l.store.Incr(ctx, failuresKey(subject), l.policy.FailureWindow)
+	if err != nil {
+		return err
+	}
+	if n < max {
+		return nil
+	}
+
+	strikes, err := l.store.Incr(ctx, strikesKey(subject), l.policy.StrikeWindow)
+	if err != nil {
+		return err
+	}
+	lockout := l.policy.BaseLockout
+	for i := int64(1); i < strikes && lockout < l.policy.MaxLockout; i++ {
+		lockout *= 2
+	}
+	if lockout > l.policy.MaxLockout {
+		lockout = l.policy.MaxLockout
+	}
+
+	if err := l.store.Set(ctx, lockKey(subject), lockout); err != nil {
+		return err
+	}
+	return l.store.Del(ctx, failuresKey(subject))
+}
+
+// Succeed limpia los fallos acumulados del usuario tras un login correcto.
+// Los de la IP se mantienen: desde una misma IP se puede estar probando
+// contra muchas cuentas.
+func (l *LoginLimiter) Succeed(ctx context.Context, username string) error {
+	subject := userSubject(username)
+	return l.store.Del(ctx, failuresKey(subject), strikesKey(subject))
+}
+
+// UnlockUser levanta el bloqueo de una cuenta y olvida su historial.
+func (l *LoginLimiter) UnlockUser(ctx context.Context, username string) error {
+	subject := userSubject(username)
+	return l.store.Del(ctx, lockKey(subject), failuresKey(subject), strikesKey(subject))
+}
+
+// UnlockIP levanta el bloqueo de una IP y olvida su historial.
+func (l *LoginLimiter) UnlockIP(ctx context.Context, ip string) error {
+	subject := ipSubject(ip)
+	return l.store.Del(ctx, lockKey(subject), failuresKey(subject), strikesKey(subject))
+}
+
+// RedisCounterStore implementa CounterStore sobre Redis.
+type RedisCounterStore struct {
+	rdb *redis.Client
+}
+
+func NewRedisCounterStore(rdb *redis.Client) *RedisCounterStore {
+	return &RedisCounterStore{rdb: rdb}
+}
+
+func (s *RedisCounterStore) Incr(ctx context.Context, key string, ttl time.Duration) (int64, error) {
+	pipe := s.rdb.TxPipeline()
+	incr := pipe.Incr(ctx, key)
+	pipe.ExpireNX(ctx, key, ttl)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+	return incr.Val(), nil
+}
+
+func (s *RedisCounterStore) Set(ctx context.Context, key string, ttl time.Duration) error {
+	return s.rdb.Set(ctx, key, 1, ttl).Err()
+}
+
+func (s *RedisCounterStore) TTL(ctx context.Context, key string) (time.Duration, error) {
+	ttl, err := s.rdb.PTTL(ctx, key).Result()
+	if err != nil {
+		return 0, err
+	}
+	// PTTL devuelve -2 si la clave no existe y -1 si no tiene vencimiento.
+	if ttl < 0 {
+		return 0, nil
+	}
+	return ttl, nil
+}
+
+func (s *RedisCounterStore) Del(ctx context.Context, keys ...string) error {
+	return s.rdb.Del(ctx, keys...).Err()
+}
+
+// MemoryCounterStore implementa CounterStore en memoria del proceso. Sirve
+// para desarrollo o una sola réplica: cada réplica lleva su propia cuenta.
+type MemoryCounterStore struct {
+	mu      sync.Mutex
+	entries map[string]memoryCounter
+}
+
+type memoryCounter struct {
+	value     int64
+	expiresAt time.Time
+}
+
+func NewMemoryCounterStore() *MemoryCounterStore {
+	return &MemoryCounterStore{entries: map[string]memoryCounter{}}
+}
+
+// get devuelve la entrada si sigue viva; las vencidas se borran al pasar.
+func (s *MemoryCounterStore) get(key string, now time.Time) (memoryCounter, bool) {
+	entry, ok := s.entries[key]
+	if ok && !now.Before(entry.expiresAt) {
+		delete(s.entries, key)
+		return memoryCounter{}, false
+	}
+	return entry, ok
+}
+
+func (s *MemoryCounterStore) Incr(_ context.Context, key string, ttl time.Duration) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	entry, ok := s.get(key, now)
+	if !ok {
+		entry.expiresAt = now.Add(ttl)
+	}
+	entry.value++
+	s.entries[key] = entry
+	return entry.value, nil
+}
+
+func (s *MemoryCounterStore) Set(_ context.Context, key string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.entries[key] = memoryCounter{value: 1, expiresAt: time.Now().Add(ttl)}
+	return nil
+}
+
+func (s *MemoryCounterStore) TTL(_ context.Context, key string) (time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	entry, ok := s.get(key, now)
+	if !ok {
+		return 0, nil
+	}
+	return entry.expiresAt.Sub(now), nil
+}
+
+func (s *MemoryCounterStore) Del(_ context.Context, keys ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, key := range keys {
+		delete(s.entries, key)
+	}
+	return nil
+}
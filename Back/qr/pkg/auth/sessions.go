@@ -0,0 +1,226 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	// AccessTokenTTL es la vigencia del JWT de acceso. Corta a propósito:
+	// la sesión se mantiene viva rotando el refresh token.
+	AccessTokenTTL = 15 * time.Minute
+	// RefreshTokenTTL es cuánto puede pasar sin usar la app antes de tener
+	// que volver a iniciar sesión.
+	RefreshTokenTTL = 30 * 24 * time.Hour
+)
+
+// ErrInvalidRefreshToken se devuelve cuando el refresh token no existe, ya
+// expiró o ya fue rotado.
+var ErrInvalidRefreshToken = errors.New("refresh token inválido o expirado")
+
+// Session es lo que se guarda en Redis por cada login: alcanza para volver a
+// firmar un access token sin pasar por la base de datos.
+type Session struct {
+	ID         string `json:"sid"`
+	UserID     int    `json:"user_id"`
+	Rol        string `json:"rol"`
+	Rut        int    `json:"rut"`
+	ProfesorID *int   `json:"profesor_id,omitempty"`
+	AlumnoID   *int   `json:"alumno_id,omitempty"`
+}
+
+// SessionStore guarda en Redis los refresh tokens (hasheados) de cada
+// sesión y la lista de sesiones revocadas que consulta RequireAuth.
+//
+// Claves:
+//
+//	refresh:<sha256(token)>   -> Session (JSON), TTL RefreshTokenTTL
+//	rotated:<sha256(token)>   -> sid de un refresh ya usado, para detectar reuso
+//	session:<sid>:refresh     -> hash del refresh token vigente de la sesión
+//	user-sessions:<userID>    -> set de sids abiertos del usuario
+//	revoked:<sid>             -> marca de revocación, TTL AccessTokenTTL
+type SessionStore struct {
+	rdb *redis.Client
+}
+
+func NewSessionStore(rdb *redis.Client) *SessionStore {
+	return &SessionStore{rdb: rdb}
+}
+
+func refreshKey(hash string) string       { return "refresh:" + hash }
+func rotatedKey(hash string) string       { return "rotated:" + hash }
+func sessionRefreshKey(sid string) string { return "session:" + sid + ":refresh" }
+func revokedKey(sid string) string        { return "revoked:" + sid }
+func userSessionsKey(userID int) string {
+	return "user-sessions:" + strconv.Itoa(userID)
+}
+
+// Create abre una sesión nueva para el usuario y devuelve la sesión y su
+// primer refresh token.
+func (s *SessionStore) Create(ctx context.Context, sess Session) (Session, string, error) {
+	sid, err := randomToken(16)
+	if err != nil {
+		return Session{}, "", err
+	}
+	sess.ID = sid
+
+	refresh, err := s.storeRefresh(ctx, sess)
+	if err != nil {
+		return Session{}, "", err
+	}
+	return sess, refresh, nil
+}
+
+// Rotate canjea un refresh token por uno nuevo de la misma sesión. Cada
+// refresh token sirve una sola vez: si se presenta uno ya rotado se asume
+// que fue robado y se revoca la sesión completa.
+func (s *SessionStore) Rotate(ctx context.Context, refreshToken string) (Session, string, error) {
+	hash := hashToken(refreshToken)
+
+	data, err := s.rdb.GetDel(ctx, refreshKey(hash)).Bytes()
+	if err == redis.Nil {
+		if sid, err := s.rdb.Get(ctx, rotatedKey(hash)).Result(); err == nil {
+			_ = s.Revoke(ctx, sid)
+		}
+		return Session{}, "", ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return Session{}, "", err
+	}
+
+	var sess Session
+	if err := json.Unmarshal(data, &sess); err != nil {
+		return Session{}, "", err
+	}
+
+	if revoked, err := s.IsRevoked(ctx, sess.ID); err != nil {
+		return Session{}, "", err
+	} else if revoked {
+		return Session{}, "", ErrInvalidRefreshToken
+	}
+
+	if err := s.rdb.Set(ctx, rotatedKey(hash), sess.ID, RefreshTokenTTL).Err(); err != nil {
+		return Session{}, "", err
+	}
+
+	refresh, err := s.storeRefresh(ctx, sess)
+	if err != nil {
+		return Session{}, "", err
+	}
+	return sess, refresh, nil
+}
+
+// Lookup devuelve la sesión dueña de un refresh token sin rotarlo.
+func (s *SessionStore) Lookup(ctx context.Context, refreshToken string) (Session, error) {
+	data, err := s.rdb.Get(ctx, refreshKey(hashToken(refreshToken))).Bytes()
+	if err == redis.Nil {
+		return Session{}, ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return Session{}, err
+	}
+
+	var sess Session
+	err = json.Unmarshal(data, &sess)
+	return sess, err
+}
+
+// Revoke cierra una sesión: borra su refresh token vigente y la marca como
+// revocada el tiempo suficiente para que expiren sus access tokens.
+func (s *SessionStore) Revoke(ctx context.Context, sid string) error {
+	hash, err := s.rdb.Get(ctx, sessionRefreshKey(sid)).Result()
+	if err != nil && err != redis.Nil {
+		return err
+	}
+
+	pipe := s.rdb.TxPipeline()
+	pipe.Set(ctx, revokedKey(sid), 1, AccessTokenTTL)
+	pipe.Del(ctx, sessionRefreshKey(sid))
+	if hash != "" {
+		pipe.Del(ctx, refreshKey(hash))
+	}
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// RevokeAll cierra todas las sesiones abiertas de un usuario, por ejemplo
+// cuando le roban el teléfono y entra desde otro dispositivo.
+func (s *SessionStore) RevokeAll(ctx context.Context, userID int) error {
+	sids, err := s.rdb.SMembers(ctx, userSessionsKey(userID)).Result()
+	if err != nil {
+		return err
+	}
+	for _, sid := range sids {
+		if err := s.Revoke(ctx, sid); err != nil {
+			return err
+		}
+	}
+	return s.rdb.Del(ctx, userSessionsKey(userID)).Err()
+}
+
+// IsActive indica si la sesión sigue abierta: tiene refresh token vigente y
+// no fue revocada. Sirve para credenciales que duran más que un access
+// token (una proyección de QR), para las que IsRevoked no alcanza porque la
+// marca de revocación vence junto con AccessTokenTTL.
+func (s *SessionStore) IsActive(ctx context.Context, sid string) (bool, error) {
+	n, err := s.rdb.Exists(ctx, sessionRefreshKey(sid)).Result()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+// IsRevoked indica si la sesión fue cerrada antes de que expiraran sus
+// access tokens.
+func (s *SessionStore) IsRevoked(ctx context.Context, sid string) (bool, error) {
+	n, err := s.rdb.Exists(ctx, revokedKey(sid)).Result()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+func (s *SessionStore) storeRefresh(ctx context.Context, sess Session) (string, error) {
+	refresh, err := randomToken(32)
+	if err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(sess)
+	if err != nil {
+		return "", err
+	}
+
+	hash := hashToken(refresh)
+	pipe := s.rdb.TxPipeline()
+	pipe.Set(ctx, refreshKey(hash), data, RefreshTokenTTL)
+	pipe.Set(ctx, sessionRefreshKey(sess.ID), hash, RefreshTokenTTL)
+	pipe.SAdd(ctx, userSessionsKey(sess.UserID), sess.ID)
+	pipe.Expire(ctx, userSessionsKey(sess.UserID), RefreshTokenTTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return "", err
+	}
+	return refresh, nil
+}
+
+// Solo se guarda el hash del refresh token: un volcado de Redis no alcanza
+// para suplantar sesiones.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
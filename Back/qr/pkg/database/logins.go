@@ -0,0 +1,111 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Motivos con los que se registra cada intento en LogIn.
+const (
+	LoginOK                    = "ok"
+	LoginCredencialesInvalidas = "credenciales_invalidas"
+	LoginBloqueado             = "bloqueado"
+	LoginError                 = "error"
+)
+
+// LoginEvent es una fila de la tabla LogIn: un intento de login, exitoso o
+// no, con los datos del cliente que lo hizo.
+type LoginEvent struct {
+	ID            int64     `json:"id"`
+	FechaRegistro time.Time `json:"fecha_registro"`
+	Username      string    `json:"username"`
+	Rol           string    `json:"rol"`
+	Rut           *int      `json:"rut,omitempty"`
+	Exitoso       bool      `json:"exitoso"`
+	Motivo        string    `json:"motivo"`
+	IP            string    `json:"ip"`
+	UserAgent     string    `json:"user_agent"`
+	DispositivoID string    `json:"dispositivo_id"`
+}
+
+// LoginFilter acota la búsqueda del historial de logins. Los campos vacíos
+// no filtran.
+type LoginFilter struct {
+	Username string
+	Rut      *int
+	Exitoso  *bool
+	Desde    *time.Time
+	Hasta    *time.Time
+	Limit    int
+	Offset   int
+}
+
+// RecordLogin guarda un intento de login en LogIn.
+func (s *Store) RecordLogin(ev LoginEvent) error {
+	_, err := s.db.Exec(`
+		INSERT INTO LogIn (FechaRegistro, Username, Rol, Rut, Exitoso, Motivo, IP, UserAgent, DispositivoID)
+		VALUES (CURRENT_TIMESTAMP, $1, $2, $3, $4, $5, $6, $7, $8)
+	`, ev.Username, ev.Rol, ev.Rut, ev.Exitoso, ev.Motivo, ev.IP, ev.UserAgent, ev.DispositivoID)
+	return err
+}
+
+// SearchLogins busca en el historial de logins, del más reciente al más
+// antiguo.
+func (s *Store) SearchLogins(f LoginFilter) ([]LoginEvent, error) {
+	var where []string
+	var args []interface{}
+	add := func(cond string, arg interface{}) {
+		args = append(args, arg)
+		where = append(where, fmt.Sprintf(cond, len(args)))
+	}
+	if f.Username != "" {
+		add("Username = $%d", f.Username)
+	}
+	if f.Rut != nil {
+		add("Rut = $%d", *f.Rut)
+	}
+	if f.Exitoso != nil {
+		add("Exitoso = $%d", *f.Exitoso)
+	}
+	if f.Desde != nil {
+		add("FechaRegistro >= $%d", *f.Desde)
+	}
+	if f.Hasta != nil {
+		add("FechaRegistro < $%d", *f.Hasta)
+	}
+
+	query := `
+		SELECT ID, FechaRegistro, COALESCE(Username, ''), COALESCE(Rol, ''), Rut,
+		       Exitoso, COALESCE(Motivo, ''), COALESCE(IP, ''), COALESCE(UserAgent, ''),
+		       COALESCE(DispositivoID, MAC, '')
+		FROM LogIn`
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	args = append(args, f.Limit, f.Offset)
+	query += fmt.Sprintf(" ORDER BY FechaRegistro DESC, ID DESC LIMIT $%d OFFSET $%d", len(args)-1, len(args))
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []LoginEvent{}
+	for rows.Next() {
+		var ev LoginEvent
+		var rut sql.NullInt64
+		if err := rows.Scan(&ev.ID, &ev.FechaRegistro, &ev.Username, &ev.Rol, &rut,
+			&ev.Exitoso, &ev.Motivo, &ev.IP, &ev.UserAgent, &ev.DispositivoID); err != nil {
+			return nil, err
+		}
+		if rut.Valid {
+			v := int(rut.Int64)
+			ev.Rut = &v
+		}
+		events = append(events, ev)
+	}
+	return events, rows.Err()
+}
@@ -2,8 +2,13 @@ package database
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+	"log"
 	"os"
+	"sync"
+
+	passwd "mysqr/pkg/password"
 
 	_ "github.com/lib/pq"
 )
@@ -18,7 +23,27 @@ type User struct {
 	AlumnoID   *int   `json:"alumno_id,omitempty"`
 }
 
-var db *sql.DB
+// Store es el acceso del servicio qr a Postgres (AUTH y LogIn) sobre un
+// pool que se abre una sola vez al arrancar.
+type Store struct {
+	db *sql.DB
+}
+
+// NewStore arma el Store sobre el pool de CreateConnection.
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+// ErrInvalidCredentials se devuelve tanto si el usuario no existe como si la
+// contraseña no coincide, para no revelar cuál de las dos falló.
+var ErrInvalidCredentials = errors.New("credenciales inválidas")
+
+// dummyHash se compara cuando el usuario no existe, para que esa respuesta
+// tarde lo mismo que una contraseña incorrecta.
+var dummyHash = sync.OnceValue(func() string {
+	hash, _ := passwd.Hash("mysqr-dummy-password")
+	return hash
+})
 
 func CreateConnection() (*sql.DB, error) {
 	host := getEnv("DB_HOST", "database")
@@ -30,8 +55,7 @@ func CreateConnection() (*sql.DB, error) {
 	connStr := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
 		host, port, user, password, dbname)
 
-	var err error
-	db, err = sql.Open("postgres", connStr)
+	db, err := sql.Open("postgres", connStr)
 	if err != nil {
 		return nil, err
 	}
@@ -52,19 +76,18 @@ func getEnv(key, defaultValue string) string {
 	return value
 }
 
-func ValidateUser(username, password, rol string) (*User, error) {
-	if db == nil {
-		return nil, fmt.Errorf("conexión a la base de datos no inicializada")
-	}
-
+// ValidateUser busca al usuario por username y rol y verifica la contraseña
+// contra el hash guardado. Si la fila todavía tenía la clave en texto plano
+// (o un hash con costo viejo), la rehashea en el mismo login exitoso.
+func (s *Store) ValidateUser(username, password, rol string) (*User, error) {
 	query := `
 		SELECT id, username, password_hash, rol, rut, ProfesorID, AlumnoID
 		FROM AUTH 
-		WHERE username = $1 AND password_hash = $2 AND rol = $3
+		WHERE username = $1 AND rol = $2
 	`
 
 	var user User
-	err := db.QueryRow(query, username, password, rol).Scan(
+	err := s.db.QueryRow(query, username, rol).Scan(
 		&user.ID,
 		&user.Username,
 		&user.Password,
@@ -75,12 +98,39 @@ func ValidateUser(username, password, rol string) (*User, error) {
 	)
 
 	if err == sql.ErrNoRows {
-		return nil, fmt.Errorf("credenciales inválidas")
+		passwd.Verify(dummyHash(), password)
+		return nil, ErrInvalidCredentials
 	}
 
 	if err != nil {
 		return nil, fmt.Errorf("error de base de datos: %v", err)
 	}
 
+	if !passwd.Verify(user.Password, password) {
+		return nil, ErrInvalidCredentials
+	}
+
+	if passwd.NeedsRehash(user.Password) {
+		if err := s.upgradePasswordHash(user.ID, user.Password, password); err != nil {
+			// El login ya es válido: si falla el upgrade se reintenta en el próximo.
+			log.Printf("No se pudo rehashear la contraseña del usuario %d: %v", user.ID, err)
+		}
+	}
+
 	return &user, nil
 }
+
+// upgradePasswordHash reemplaza el password_hash de un usuario por un hash
+// bcrypt nuevo. Solo pisa la fila si sigue teniendo el valor que se verificó,
+// para no competir con un cambio de clave concurrente.
+func (s *Store) upgradePasswordHash(userID int, stored, password string) error {
+	hash, err := passwd.Hash(password)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(`
+		UPDATE AUTH SET password_hash = $1
+		WHERE id = $2 AND password_hash = $3
+	`, hash, userID, stored)
+	return err
+}
@@ -6,10 +6,14 @@ import (
 	"os"
 	"strconv"
 
+	"mysqr/database/pkg/models"
 	"mysqr/database/pkg/postgres"
 	"mysqr/pkg/authmw"
+	"mysqr/pkg/clock"
 	"mysqr/pkg/httpcors"
+	"mysqr/pkg/livefeed"
 	"mysqr/pkg/qrcode"
+	"mysqr/qr/pkg/auth"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-redis/redis/v8"
@@ -24,14 +28,32 @@ func main() {
 		log.Fatal("Error connecting to database:", err)
 	}
 	defer db.Close()
-	dbService := postgres.NewDatabaseService(db)
+	clk, err := clock.FromEnv()
+	if err != nil {
+		log.Fatal("Error reading institution clock:", err)
+	}
+	dbService := postgres.NewDatabaseService(db, clk)
 
 	rdb := redis.NewClient(&redis.Options{
 		Addr: getEnv("REDIS_HOST", "localhost") + ":" + getEnv("REDIS_PORT", "6379"),
 	})
-	store := qrcode.NewStore(rdb)
+	qrKeys, err := qrcode.LoadKeyRing()
+	if err != nil {
+		log.Fatal("Error loading QR encryption keys:", err)
+	}
+	qrOpts, err := qrcode.OptionsFromEnv()
+	if err != nil {
+		log.Fatal("Error reading QR options:", err)
+	}
+	store, err := qrcode.NewStoreFromEnv(rdb, qrKeys, qrOpts)
+	if err != nil {
+		log.Fatal("Error creating QR store:", err)
+	}
+	keys := auth.NewRemoteKeySet(getEnv("JWKS_URL", "http://localhost:8087/.well-known/jwks.json"))
+	verifier := auth.NewVerifier(keys, auth.NewSessionStore(rdb))
+	feed := livefeed.New(rdb)
 
-	r.POST("/api/scan", authmw.RequireAuth(), func(c *gin.Context) {
+	r.POST("/api/scan", authmw.RequireAuth(verifier), func(c *gin.Context) {
 		claims := authmw.Claims(c)
 		if claims.Rol != "alumno" || claims.AlumnoID == nil {
 			c.JSON(http.StatusForbidden, gin.H{"error": "Solo un alumno puede escanear asistencia"})
@@ -47,40 +69,88 @@ func main() {
 			return
 		}
 
-		payload, err := qrcode.Decrypt(request.QR)
+		payload, err := qrKeys.Open(request.QR)
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "QR inválido"})
 			return
 		}
 
-		valid, err := store.Validate(c.Request.Context(), payload)
+		seccionID, err := strconv.Atoi(payload.SectionID)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al validar el QR"})
+			c.JSON(http.StatusBadRequest, gin.H{"error": "QR inválido"})
 			return
 		}
-		if !valid {
-			c.JSON(http.StatusNotFound, gin.H{"error": "QR expirado, pide uno nuevo"})
+		moduloID, err := strconv.Atoi(payload.ModuleID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "QR inválido"})
 			return
 		}
-
-		seccionID, err := strconv.Atoi(payload.SectionID)
+		sessionID, err := strconv.ParseInt(payload.SessionID, 10, 64)
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "QR inválido"})
 			return
 		}
-		moduloID, err := strconv.Atoi(payload.ModuleID)
+
+		// Un QR vigente no basta: la sesión de clase que lo emitió tiene que
+		// seguir abierta. Así el profesor corta los escaneos tardíos al
+		// cerrar o pausar la toma de asistencia, aunque queden QR vivos.
+		session, err := dbService.GetClassSession(sessionID)
 		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al verificar la sesión de clase"})
+			return
+		}
+		if session == nil || session.SeccionID != seccionID || session.ModuloID != moduloID {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "QR inválido"})
 			return
 		}
 
+		// Desde acá el QR es auténtico y de una clase concreta: lo que pase
+		// con el escaneo se avisa en el feed en vivo del profesor.
+		event := livefeed.Event{SectionID: seccionID, ModuleID: moduloID, SessionID: sessionID, AlumnoID: alumnoID}
+		publish := func(eventType, reason string) {
+			event.Type, event.Reason = eventType, reason
+			if err := feed.Publish(c.Request.Context(), event); err != nil {
+				log.Printf("Error al publicar en el feed en vivo: %v", err)
+			}
+		}
+		reject := func(status int, message, reason string) {
+			publish(livefeed.EventRejected, reason)
+			c.JSON(status, gin.H{"error": message, "reason": reason})
+		}
+
+		// Cancelar la clase cierra su sesión, pero un QR emitido justo antes
+		// no debe valer aunque la sesión se lea todavía abierta.
+		scheduled, err := dbService.IsScheduled(seccionID, moduloID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al verificar la clase"})
+			return
+		}
+		if !scheduled {
+			reject(http.StatusConflict, "La clase fue cancelada", "class_cancelled")
+			return
+		}
+
+		switch {
+		case session.Estado == models.SesionCerrada:
+			reject(http.StatusConflict, "El profesor ya cerró la toma de asistencia", "session_closed")
+			return
+		case session.Estado == models.SesionPausada:
+			reject(http.StatusConflict, "La toma de asistencia está pausada", "session_paused")
+			return
+		case session.Vencida:
+			reject(http.StatusConflict, "La toma de asistencia de esta clase ya terminó", "session_expired")
+			return
+		}
+
+		// Inscripción y asistencia previa se revisan antes de canjear, para
+		// que un alumno ajeno o uno que reescanea no gasten un cupo del QR.
 		enrolled, err := dbService.IsEnrolled(alumnoID, seccionID)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al verificar la inscripción"})
 			return
 		}
 		if !enrolled {
-			c.JSON(http.StatusForbidden, gin.H{"error": "No estás inscrito en esta sección"})
+			reject(http.StatusForbidden, "No estás inscrito en esta sección", "not_enrolled")
 			return
 		}
 
@@ -94,12 +164,65 @@ func main() {
 			return
 		}
 
-		if err := dbService.RegisterAttendance(alumnoID, seccionID, moduloID); err != nil {
+		ctx := c.Request.Context()
+		alumno := strconv.Itoa(alumnoID)
+		ok, reason, err := store.Redeem(ctx, payload, alumno)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al validar el QR"})
+			return
+		}
+		if !ok {
+			switch reason {
+			case qrcode.ReasonExpired:
+				reject(http.StatusNotFound, "QR expirado, pide uno nuevo", reason)
+			case qrcode.ReasonAlreadyRedeemed:
+				reject(http.StatusConflict, "Ya usaste este QR, espera el siguiente", reason)
+			default:
+				reject(http.StatusConflict, "Este QR ya alcanzó su límite de usos, espera el siguiente", reason)
+			}
+			return
+		}
+
+		estado, anterior, err := dbService.RegisterAttendance(alumnoID, seccionID, moduloID)
+		if err != nil || estado == "" {
+			if err := store.Release(ctx, payload, alumno); err != nil {
+				log.Printf("Error al liberar el canje del QR: %v", err)
+			}
+		}
+		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al registrar la asistencia"})
 			return
 		}
+		if estado == "" {
+			// Una clase justificada o excusada es decisión del profesor, con
+			// su motivo: el escaneo no la pisa.
+			switch anterior {
+			case models.AsistenciaJustificada:
+				reject(http.StatusConflict, "El profesor ya registró esta clase como justificada", "status_set")
+				return
+			case models.AsistenciaExcusada:
+				reject(http.StatusConflict, "El profesor ya registró esta clase como excusada", "status_set")
+				return
+			}
+			// Otro escaneo del mismo alumno ganó la carrera.
+			c.JSON(http.StatusOK, gin.H{"status": "already_registered", "message": "Ya habías registrado tu asistencia"})
+			return
+		}
 
-		c.JSON(http.StatusOK, gin.H{"status": "registered", "message": "Asistencia registrada exitosamente"})
+		event.Status = estado
+		publish(livefeed.EventAttendance, "")
+		message := "Asistencia registrada exitosamente"
+		if estado == models.AsistenciaTarde {
+			message = "Asistencia registrada como atrasada"
+		}
+		response := gin.H{"status": "registered", "attendance_status": estado, "message": message}
+		if anterior == models.AsistenciaAusente {
+			// Reemplaza una ausencia que marcó el profesor: se avisa para que
+			// no pase desapercibido (su motivo queda en el registro).
+			response["previous_status"] = anterior
+			response["message"] = message + ", reemplaza la ausencia que había marcado el profesor"
+		}
+		c.JSON(http.StatusOK, response)
 	})
 
 	log.Printf("Iniciando servidor Student en :8085")
@@ -1,15 +1,25 @@
 package main
 
 import (
+	"bytes"
+	_ "embed"
+	"errors"
+	"io"
 	"log"
 	"net/http"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
+	"mysqr/database/pkg/models"
 	"mysqr/database/pkg/postgres"
 	"mysqr/pkg/authmw"
+	"mysqr/pkg/clock"
 	"mysqr/pkg/httpcors"
+	"mysqr/pkg/livefeed"
 	"mysqr/pkg/qrcode"
+	"mysqr/qr/pkg/auth"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-redis/redis/v8"
@@ -24,39 +34,261 @@ func main() {
 		log.Fatal("Error connecting to database:", err)
 	}
 	defer db.Close()
-	dbService := postgres.NewDatabaseService(db)
+	clk, err := clock.FromEnv()
+	if err != nil {
+		log.Fatal("Error reading institution clock:", err)
+	}
+	dbService := postgres.NewDatabaseService(db, clk)
 
 	rdb := redis.NewClient(&redis.Options{
 		Addr: getEnv("REDIS_HOST", "localhost") + ":" + getEnv("REDIS_PORT", "6379"),
 	})
-	store := qrcode.NewStore(rdb)
+	qrKeys, err := qrcode.LoadKeyRing()
+	if err != nil {
+		log.Fatal("Error loading QR encryption keys:", err)
+	}
+	qrOpts, err := qrcode.OptionsFromEnv()
+	if err != nil {
+		log.Fatal("Error reading QR options:", err)
+	}
+	store, err := qrcode.NewStoreFromEnv(rdb, qrKeys, qrOpts)
+	if err != nil {
+		log.Fatal("Error creating QR store:", err)
+	}
+	keys := auth.NewRemoteKeySet(getEnv("JWKS_URL", "http://localhost:8087/.well-known/jwks.json"))
+	authSessions := auth.NewSessionStore(rdb)
+	verifier := auth.NewVerifier(keys, authSessions)
+	feed := livefeed.New(rdb)
+
+	// currentClass resuelve la clase para la que se abre la sesión: la
+	// elegida en choice, validada contra el horario, o la que corresponde
+	// ahora. Si no hay ninguna (o hay que elegir) ya respondió y devuelve
+	// ok=false.
+	currentClass := func(c *gin.Context, profesorID int, choice models.ModuloSeccion) (*models.ModuloSeccion, bool) {
+		if choice.SeccionID != 0 {
+			clase, err := dbService.GetCurrentClass(profesorID, choice.SeccionID, choice.ModuloID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return nil, false
+			}
+			if clase == nil {
+				c.JSON(http.StatusConflict, gin.H{"error": "Esa sección no tiene clase programada en este momento"})
+				return nil, false
+			}
+			return &models.ModuloSeccion{ModuloID: clase.ModuloID, SeccionID: clase.SeccionID}, true
+		}
+
+		moduleSection, err := dbService.GetCurrentModuleAndSection(profesorID)
+		if errors.Is(err, postgres.ErrClaseAmbigua) {
+			classes, err := dbService.GetCurrentClasses(profesorID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return nil, false
+			}
+			c.JSON(http.StatusConflict, gin.H{"error": "Hay más de una clase programada en este momento, elige la sección", "classes": classes})
+			return nil, false
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return nil, false
+		}
+		if moduleSection == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "No hay clase programada en este momento"})
+			return nil, false
+		}
+		return moduleSection, true
+	}
+
+	// classSession resuelve la sesión de clase actual del profesor: la que
+	// tenga abierta (aunque se haya extendido más allá de su módulo) o, si no
+	// tiene, la de la clase que le corresponde ahora, que se abre en ese
+	// momento. Con choice.SeccionID (y opcionalmente choice.ModuloID) el
+	// profesor elige la clase, que tiene que estar entre sus clases actuales;
+	// si no elige y tiene dos secciones en el mismo horario responde 409 con
+	// las opciones. Una clase cancelada no tiene sesión: ni se reutiliza ni se
+	// abre. Si algo falla ya respondió el error y devuelve ok=false.
+	classSession := func(c *gin.Context, profesorID int, choice models.ModuloSeccion) (*models.SesionClase, bool) {
+		session, err := dbService.GetActiveClassSession(profesorID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return nil, false
+		}
+		// Una elección explícita de otra clase no reutiliza la sesión abierta.
+		if session != nil && choice.SeccionID != 0 &&
+			(session.SeccionID != choice.SeccionID || (choice.ModuloID != 0 && session.ModuloID != choice.ModuloID)) {
+			session = nil
+		}
+		if session == nil {
+			moduleSection, found := currentClass(c, profesorID, choice)
+			if !found {
+				return nil, false
+			}
+			session, err = dbService.OpenClassSession(profesorID, moduleSection.SeccionID, moduleSection.ModuloID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo abrir la sesión de clase"})
+				return nil, false
+			}
+			// currentClass ya descarta las canceladas; esto cubre una
+			// cancelación entre las dos consultas.
+			if session == nil {
+				c.JSON(http.StatusConflict, gin.H{"error": "Esa clase fue cancelada"})
+				return nil, false
+			}
+		}
+		return session, true
+	}
+
+	// sessionQR emite un QR para session si está tomando asistencia; si no
+	// (cerrada, pausada o vencida) responde 409 con la sesión y devuelve
+	// ok=false.
+	sessionQR := func(c *gin.Context, session *models.SesionClase) (string, bool) {
+		switch {
+		case session.Estado == models.SesionCerrada:
+			c.JSON(http.StatusConflict, gin.H{"error": "La toma de asistencia de esta clase ya se cerró", "session": session})
+			return "", false
+		case session.Estado == models.SesionPausada:
+			c.JSON(http.StatusConflict, gin.H{"error": "La toma de asistencia está pausada", "session": session})
+			return "", false
+		case session.Vencida:
+			c.JSON(http.StatusConflict, gin.H{"error": "La toma de asistencia de esta clase ya terminó", "session": session})
+			return "", false
+		}
 
-	r.POST("/api/classes/start", authmw.RequireAuth(), func(c *gin.Context) {
+		encrypted, err := store.Issue(c.Request.Context(), qrcode.Payload{
+			SessionID:   strconv.FormatInt(session.ID, 10),
+			SectionID:   strconv.Itoa(session.SeccionID),
+			ProfessorID: strconv.Itoa(session.ProfesorID),
+			ModuleID:    strconv.Itoa(session.ModuloID),
+		}, qrcode.DefaultTTL)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo emitir el QR"})
+			return "", false
+		}
+		return encrypted, true
+	}
+
+	// issueQR emite un QR para la sesión de clase actual del profesor
+	// autenticado (ver classSession). Si algo falla ya respondió el error y
+	// devuelve ok=false.
+	issueQR := func(c *gin.Context, choice models.ModuloSeccion) (encrypted string, session *models.SesionClase, ok bool) {
 		claims := authmw.Claims(c)
 		if claims.Rol != "profesor" || claims.ProfesorID == nil {
 			c.JSON(http.StatusForbidden, gin.H{"error": "Solo un profesor puede emitir un QR"})
+			return "", nil, false
+		}
+
+		session, ok = classSession(c, *claims.ProfesorID, choice)
+		if !ok {
+			return "", nil, false
+		}
+		encrypted, ok = sessionQR(c, session)
+		return encrypted, session, ok
+	}
+
+	// projectionSession abre el token de proyección del header
+	// "Authorization: Projection <token>" y carga su sesión de clase,
+	// confirmando que la sesión de login que lo emitió siga abierta. Si no, ya
+	// respondió 401 y devuelve ok=false.
+	projectionSession := func(c *gin.Context) (qrcode.Projection, *models.SesionClase, bool) {
+		header := c.GetHeader("Authorization")
+		if !strings.HasPrefix(header, "Projection ") {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Falta token de proyección"})
+			return qrcode.Projection{}, nil, false
+		}
+		projection, err := qrKeys.OpenProjection(strings.TrimPrefix(header, "Projection "), qrcode.ScopeProjection, time.Now())
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Token de proyección inválido o expirado"})
+			return qrcode.Projection{}, nil, false
+		}
+
+		active, err := authSessions.IsActive(c.Request.Context(), projection.AuthSID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return qrcode.Projection{}, nil, false
+		}
+		if !active {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "La sesión que abrió la proyección se cerró"})
+			return qrcode.Projection{}, nil, false
+		}
+
+		session, err := dbService.GetClassSession(projection.ClassSession)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return qrcode.Projection{}, nil, false
+		}
+		if session == nil || session.ProfesorID != projection.ProfessorID {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Token de proyección inválido o expirado"})
+			return qrcode.Projection{}, nil, false
+		}
+		return projection, session, true
+	}
+
+	// ownSession carga la sesión de :id y confirma que es del profesor
+	// autenticado. Si no, ya respondió el error y devuelve ok=false.
+	ownSession := func(c *gin.Context) (*models.SesionClase, bool) {
+		claims := authmw.Claims(c)
+		if claims.Rol != "profesor" || claims.ProfesorID == nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Solo un profesor puede administrar sus clases"})
+			return nil, false
+		}
+
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "ID de sesión inválido"})
+			return nil, false
+		}
+		session, err := dbService.GetClassSession(id)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return nil, false
+		}
+		if session == nil || session.ProfesorID != *claims.ProfesorID {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Sesión no encontrada"})
+			return nil, false
+		}
+		return session, true
+	}
+
+	// Clases del profesor que pueden ser "la actual" (en curso, por empezar
+	// o recién terminadas según las tolerancias), de la más a la menos
+	// probable, más la sesión que tenga abierta. Sirve para elegir la sección
+	// en /start cuando dicta dos en el mismo horario.
+	r.GET("/api/classes/current", authmw.RequireAuth(verifier), func(c *gin.Context) {
+		claims := authmw.Claims(c)
+		if claims.Rol != "profesor" || claims.ProfesorID == nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Solo un profesor puede ver sus clases"})
 			return
 		}
 
-		moduleSection, err := dbService.GetCurrentModuleAndSection(*claims.ProfesorID)
+		classes, err := dbService.GetCurrentClasses(*claims.ProfesorID)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
-		if moduleSection == nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "No hay clase programada en este momento"})
+		session, err := dbService.GetActiveClassSession(*claims.ProfesorID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
+		c.JSON(http.StatusOK, gin.H{"classes": classes, "session": session})
+	})
 
-		encrypted, err := store.Issue(
-			c.Request.Context(),
-			strconv.Itoa(moduleSection.SeccionID),
-			strconv.Itoa(*claims.ProfesorID),
-			strconv.Itoa(moduleSection.ModuloID),
-			qrcode.DefaultTTL,
-		)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo emitir el QR"})
+	// Body opcional: {"section_id": N, "module_id": M} para elegir la clase
+	// (module_id solo hace falta si la sección tiene dos módulos candidatos).
+	r.POST("/api/classes/start", authmw.RequireAuth(verifier), func(c *gin.Context) {
+		var request struct {
+			SectionID int `json:"section_id"`
+			ModuleID  int `json:"module_id"`
+		}
+		if c.Request.ContentLength != 0 {
+			if err := c.ShouldBindJSON(&request); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Cuerpo de la solicitud inválido"})
+				return
+			}
+		}
+
+		encrypted, session, ok := issueQR(c, models.ModuloSeccion{ModuloID: request.ModuleID, SeccionID: request.SectionID})
+		if !ok {
 			return
 		}
 
@@ -64,9 +296,395 @@ func main() {
 			"encrypted_qr": encrypted,
 			"expires_in":   int(qrcode.DefaultTTL.Seconds()),
 			"data": gin.H{
-				"section_id": moduleSection.SeccionID,
-				"module_id":  moduleSection.ModuloID,
+				"session_id": session.ID,
+				"section_id": session.SeccionID,
+				"module_id":  session.ModuloID,
 			},
+			"session": session,
+		})
+	})
+
+	// Ciclo de vida de la sesión. Cada transición responde la sesión
+	// actualizada, o 409 con la sesión tal como está si no aplica a su estado
+	// (por ejemplo, pausar una sesión cerrada).
+	sessions := r.Group("/api/classes/sessions/:id", authmw.RequireAuth(verifier))
+
+	sessions.GET("", func(c *gin.Context) {
+		session, ok := ownSession(c)
+		if !ok {
+			return
+		}
+		c.JSON(http.StatusOK, session)
+	})
+
+	// Body opcional: {"minutes": N} (1 a 120, default 10). El cierre se corre
+	// N minutos desde lo que sea más tarde entre el cierre actual y ahora.
+	sessions.POST("/extend", func(c *gin.Context) {
+		session, ok := ownSession(c)
+		if !ok {
+			return
+		}
+
+		var request struct {
+			Minutes int `json:"minutes"`
+		}
+		if c.Request.ContentLength != 0 {
+			if err := c.ShouldBindJSON(&request); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Cuerpo de la solicitud inválido"})
+				return
+			}
+		}
+		if request.Minutes == 0 {
+			request.Minutes = 10
+		}
+		if request.Minutes < 1 || request.Minutes > 120 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "minutes debe estar entre 1 y 120"})
+			return
+		}
+
+		updated, err := dbService.ExtendClassSession(session.ID, time.Duration(request.Minutes)*time.Minute)
+		respondTransition(c, session, updated, err)
+	})
+
+	sessions.POST("/pause", func(c *gin.Context) {
+		session, ok := ownSession(c)
+		if !ok {
+			return
+		}
+		updated, err := dbService.PauseClassSession(session.ID)
+		respondTransition(c, session, updated, err)
+	})
+
+	sessions.POST("/resume", func(c *gin.Context) {
+		session, ok := ownSession(c)
+		if !ok {
+			return
+		}
+		updated, err := dbService.ResumeClassSession(session.ID)
+		respondTransition(c, session, updated, err)
+	})
+
+	sessions.POST("/close", func(c *gin.Context) {
+		session, ok := ownSession(c)
+		if !ok {
+			return
+		}
+		updated, err := dbService.CloseClassSession(session.ID)
+		respondTransition(c, session, updated, err)
+	})
+
+	// Igual que /start pero devuelve el QR ya dibujado (png o svg), para
+	// imprimirlo o incrustarlo. Para proyectarlo desde un navegador sin la app
+	// está /api/classes/projection. Query opcional: size (px), level
+	// (L|M|Q|H), margin (módulos) y section_id/module_id para elegir la clase
+	// como en /start.
+	r.GET("/api/classes/qr/:format", authmw.RequireAuth(verifier), func(c *gin.Context) {
+		format := c.Param("format")
+		if format != "png" && format != "svg" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Formato no soportado, usa png o svg"})
+			return
+		}
+
+		opts, err := qrcode.ParseRenderOptions(c.Query("size"), c.Query("level"), c.Query("margin"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		var choice models.ModuloSeccion
+		if v := c.Query("section_id"); v != "" {
+			if choice.SeccionID, err = strconv.Atoi(v); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "ID de sección inválido"})
+				return
+			}
+		}
+		if v := c.Query("module_id"); v != "" {
+			if choice.ModuloID, err = strconv.Atoi(v); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "ID de módulo inválido"})
+				return
+			}
+		}
+
+		encrypted, _, ok := issueQR(c, choice)
+		if !ok {
+			return
+		}
+
+		var buf bytes.Buffer
+		contentType := "image/png"
+		if format == "svg" {
+			contentType = "image/svg+xml"
+			err = qrcode.RenderSVG(&buf, encrypted, opts)
+		} else {
+			err = qrcode.RenderPNG(&buf, encrypted, opts)
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "No se pudo dibujar el QR: " + err.Error()})
+			return
+		}
+
+		c.Header("Cache-Control", "no-store")
+		c.Data(http.StatusOK, contentType, buf.Bytes())
+	})
+
+	// Proyección del QR en un navegador sin la app. El profesor (con su JWT)
+	// pide un token de proyección para la sesión de clase actual, con el
+	// mismo body opcional que /start, y abre la URL que se le devuelve. El
+	// token va en el fragmento (#), que el navegador no manda al servidor ni
+	// en el Referer, y dura ProjectionTTL: la página lo cambia por uno nuevo
+	// con cada QR que pide. Deja de servir cuando la sesión de clase termina
+	// o el profesor cierra sesión.
+	r.POST("/api/classes/projection", authmw.RequireAuth(verifier), func(c *gin.Context) {
+		claims := authmw.Claims(c)
+		if claims.Rol != "profesor" || claims.ProfesorID == nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Solo un profesor puede proyectar un QR"})
+			return
+		}
+
+		var request struct {
+			SectionID int `json:"section_id"`
+			ModuleID  int `json:"module_id"`
+		}
+		if c.Request.ContentLength != 0 {
+			if err := c.ShouldBindJSON(&request); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Cuerpo de la solicitud inválido"})
+				return
+			}
+		}
+
+		session, ok := classSession(c, *claims.ProfesorID, models.ModuloSeccion{ModuloID: request.ModuleID, SeccionID: request.SectionID})
+		if !ok {
+			return
+		}
+		if session.Estado == models.SesionCerrada || session.Vencida {
+			c.JSON(http.StatusConflict, gin.H{"error": "La toma de asistencia de esta clase ya terminó", "session": session})
+			return
+		}
+
+		token, err := qrKeys.SealProjection(qrcode.Projection{
+			ProfessorID:  *claims.ProfesorID,
+			ClassSession: session.ID,
+			SectionID:    session.SeccionID,
+			ModuleID:     session.ModuloID,
+			AuthSID:      claims.SessionID,
+		}, qrcode.ScopeProjection, time.Now())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo emitir el token de proyección"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"url":        "/api/classes/projection#" + token,
+			"token":      token,
+			"expires_in": int(qrcode.ProjectionTTL.Seconds()),
+			"session":    session,
+		})
+	})
+
+	// La página de proyección es estática y pública: sin el token del
+	// fragmento no muestra nada.
+	r.GET("/api/classes/projection", func(c *gin.Context) {
+		c.Header("Cache-Control", "no-store")
+		c.Header("Referrer-Policy", "no-referrer")
+		c.Header("Content-Security-Policy", "default-src 'self'; script-src 'unsafe-inline'; style-src 'unsafe-inline'; img-src data:")
+		c.Data(http.StatusOK, "text/html; charset=utf-8", projectionPage)
+	})
+
+	// Lo que pide la página de proyección cada pocos segundos, con
+	// "Authorization: Projection <token>": el QR vigente en SVG, un token
+	// nuevo que reemplaza al anterior y un live_token para el feed en vivo
+	// de la clase. Mientras la sesión está pausada
+	// responde sin svg pero con token, para que la página siga esperando;
+	// cerrada o vencida responde 409 y la página se detiene.
+	r.POST("/api/classes/projection/qr", func(c *gin.Context) {
+		projection, session, ok := projectionSession(c)
+		if !ok {
+			return
+		}
+
+		now := time.Now()
+		token, err := qrKeys.SealProjection(projection, qrcode.ScopeProjection, now)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo emitir el token de proyección"})
+			return
+		}
+		liveToken, err := qrKeys.SealProjection(projection, qrcode.ScopeLive, now)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo emitir el token de proyección"})
+			return
+		}
+		response := gin.H{
+			"token":      token,
+			"live_token": liveToken,
+			"expires_in": int(qrcode.ProjectionTTL.Seconds()),
+			"refresh_in": int(qrcode.DefaultTTL.Seconds()) - 3,
+			"session":    session,
+		}
+
+		if session.Estado == models.SesionPausada && !session.Vencida {
+			c.Header("Cache-Control", "no-store")
+			c.JSON(http.StatusOK, response)
+			return
+		}
+		encrypted, ok := sessionQR(c, session)
+		if !ok {
+			return
+		}
+
+		var buf bytes.Buffer
+		if err := qrcode.RenderSVG(&buf, encrypted, qrcode.DefaultRenderOptions); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo dibujar el QR: " + err.Error()})
+			return
+		}
+		response["svg"] = buf.String()
+
+		c.Header("Cache-Control", "no-store")
+		c.JSON(http.StatusOK, response)
+	})
+
+	// Feed en vivo de una clase por Server-Sent Events: apenas conecta manda
+	// "totals" (presentes vs. inscritos) y después, por cada escaneo, un
+	// "attendance" o un "rejected" (con reason) seguido de "totals"
+	// actualizado cuando corresponde. Los eventos llegan por pub/sub de Redis
+	// desde cualquier réplica de student.
+	//
+	// Se autentica con el JWT en el header o, como EventSource no manda
+	// headers, con ?live_token= (el que entrega /api/classes/projection/qr
+	// para esta clase). El stream no dura más que la credencial: al vencer
+	// manda "reauth" con reason "expired" y corta, y la página se vuelve a
+	// conectar con un token nuevo. Si el profesor cierra sesión manda "reauth"
+	// con reason "revoked" en el siguiente heartbeat y corta.
+	r.GET("/api/classes/live/:section/:module", func(c *gin.Context) {
+		seccionID, err := strconv.Atoi(c.Param("section"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "ID de sección inválido"})
+			return
+		}
+		moduloID, err := strconv.Atoi(c.Param("module"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "ID de módulo inválido"})
+			return
+		}
+
+		var (
+			profesorID int
+			authSID    string
+			expires    time.Time
+		)
+		if token := c.Query("live_token"); token != "" {
+			projection, err := qrKeys.OpenProjection(token, qrcode.ScopeLive, time.Now())
+			if err != nil {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Token inválido o expirado"})
+				return
+			}
+			if projection.SectionID != seccionID || projection.ModuleID != moduloID {
+				c.JSON(http.StatusForbidden, gin.H{"error": "El token es de otra clase"})
+				return
+			}
+			profesorID, authSID, expires = projection.ProfessorID, projection.AuthSID, projection.Expires()
+		} else {
+			header := c.GetHeader("Authorization")
+			if !strings.HasPrefix(header, "Bearer ") {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Falta token de autorización"})
+				return
+			}
+			claims, err := verifier.Verify(c.Request.Context(), strings.TrimPrefix(header, "Bearer "))
+			if err != nil {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Token inválido o expirado"})
+				return
+			}
+			if claims.Rol != "profesor" || claims.ProfesorID == nil || claims.ExpiresAt == nil {
+				c.JSON(http.StatusForbidden, gin.H{"error": "Solo un profesor puede ver la asistencia en vivo"})
+				return
+			}
+			profesorID, authSID, expires = *claims.ProfesorID, claims.SessionID, claims.ExpiresAt.Time
+		}
+
+		active, err := authSessions.IsActive(c.Request.Context(), authSID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if !active {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Token inválido o expirado"})
+			return
+		}
+
+		owner, err := dbService.IsSectionProfessor(seccionID, profesorID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if !owner {
+			c.JSON(http.StatusForbidden, gin.H{"error": "No dictas esta sección"})
+			return
+		}
+
+		ctx := c.Request.Context()
+		sub, err := feed.Subscribe(ctx, seccionID, moduloID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo abrir el feed en vivo"})
+			return
+		}
+		defer sub.Close()
+
+		// Los totales se leen después de suscribirse: un escaneo que llegue
+		// entremedio sale igual como evento.
+		totals, err := dbService.GetAttendanceTotals(seccionID, moduloID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.Header("Cache-Control", "no-store")
+		c.Header("X-Accel-Buffering", "no")
+		c.SSEvent("totals", totals)
+
+		heartbeat := time.NewTicker(liveHeartbeat)
+		defer heartbeat.Stop()
+		expiry := time.NewTimer(time.Until(expires))
+		defer expiry.Stop()
+
+		c.Stream(func(w io.Writer) bool {
+			select {
+			case <-ctx.Done():
+				return false
+			case <-expiry.C:
+				c.SSEvent("reauth", gin.H{"reason": "expired"})
+				return false
+			case <-heartbeat.C:
+				// El heartbeat también revisa que la sesión de login siga
+				// abierta: un logout corta el stream a más tardar aquí.
+				active, err := authSessions.IsActive(ctx, authSID)
+				if err != nil {
+					log.Printf("Error al revisar la sesión del feed en vivo: %v", err)
+				} else if !active {
+					c.SSEvent("reauth", gin.H{"reason": "revoked"})
+					return false
+				}
+				// Comentario SSE: mantiene viva la conexión a través de proxies.
+				io.WriteString(w, ": ping\n\n")
+				return true
+			case ev, ok := <-sub.Events():
+				if !ok {
+					return false
+				}
+				nombre, err := dbService.GetStudentName(ev.AlumnoID)
+				if err != nil {
+					log.Printf("Error al buscar el nombre del alumno %d: %v", ev.AlumnoID, err)
+				}
+				c.SSEvent(ev.Type, liveEvent{Event: ev, Nombre: nombre})
+
+				if ev.Type == livefeed.EventAttendance {
+					totals, err := dbService.GetAttendanceTotals(seccionID, moduloID)
+					if err != nil {
+						log.Printf("Error al contar la asistencia: %v", err)
+						return true
+					}
+					c.SSEvent("totals", totals)
+				}
+				return true
+			}
 		})
 	})
 
@@ -76,6 +694,37 @@ func main() {
 	}
 }
 
+// projectionPage es la página que proyecta el QR en el navegador; ver
+// /api/classes/projection.
+//
+//go:embed projection.html
+var projectionPage []byte
+
+// liveHeartbeat es cada cuánto el feed en vivo manda un comentario vacío
+// para que proxies y balanceadores no corten la conexión por inactividad.
+const liveHeartbeat = 15 * time.Second
+
+// liveEvent es lo que recibe el profesor por el feed en vivo: el evento tal
+// como lo publicó student más el nombre del alumno.
+type liveEvent struct {
+	livefeed.Event
+	Nombre string `json:"nombre"`
+}
+
+// respondTransition responde el resultado de un cambio de estado de una
+// sesión: updated es nil cuando la transición no aplicaba a su estado.
+func respondTransition(c *gin.Context, session, updated *models.SesionClase, err error) {
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "No se pudo actualizar la sesión"})
+		return
+	}
+	if updated == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "La sesión está en estado " + session.Estado, "session": session})
+		return
+	}
+	c.JSON(http.StatusOK, updated)
+}
+
 func getEnv(key, defaultValue string) string {
 	if v, ok := os.LookupEnv(key); ok {
 		return v
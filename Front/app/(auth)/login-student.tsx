@@ -4,6 +4,7 @@ import { Alert, ImageBackground, Platform, StyleSheet, Text, TextInput, Touchabl
 import PublicRoute from '@/components/PublicRoute';
 import { useAuth } from '@/context/AuthContext';
 import { API_URL } from '@/services/api';
+import { getDeviceId } from '@/services/device';
 
 export default function LoginScreen() {
     const [usuario, setUsuario] = useState('');
@@ -34,7 +35,8 @@ export default function LoginScreen() {
                 body: JSON.stringify({
                     username: usuario,
                     password: contrasena,
-                    rol: 'alumno'
+                    rol: 'alumno',
+                    device_id: await getDeviceId()
                 }),
             });
 
@@ -59,7 +61,7 @@ export default function LoginScreen() {
                     alumnoId: data.alumno_id
                 };
 
-                await login(data.token, userData);
+                await login(data.token, userData, data.refresh_token, data.expires_in);
                 
                 setUsuario('');
                 setContrasena('');
@@ -4,6 +4,7 @@ import { Alert, ImageBackground, Platform, StyleSheet, Text, TextInput, Touchabl
 import PublicRoute from '@/components/PublicRoute';
 import { useAuth } from '@/context/AuthContext';
 import { API_URL } from '@/services/api';
+import { getDeviceId } from '@/services/device';
 
 export default function LoginScreen() {
     const [usuario, setUsuario] = useState('');
@@ -29,7 +30,8 @@ export default function LoginScreen() {
                 body: JSON.stringify({
                     username: usuario,
                     password: contrasena,
-                    rol: 'profesor'
+                    rol: 'profesor',
+                    device_id: await getDeviceId()
                 }),
             });
 
@@ -55,7 +57,7 @@ export default function LoginScreen() {
                     profesorId: data.profesor_id
                 };
                 console.log('userData que se guardará:', userData);
-                await login(data.token, userData);
+                await login(data.token, userData, data.refresh_token, data.expires_in);
                 
                 setUsuario('');
                 setContrasena('');
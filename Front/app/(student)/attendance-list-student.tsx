@@ -3,7 +3,8 @@ import { useLocalSearchParams, useRouter } from 'expo-router';
 import React, { useEffect, useState } from 'react';
 import { ActivityIndicator, Dimensions, Image, Platform, StyleSheet, Text, TouchableOpacity, View } from 'react-native';
 import ProtectedRoute from '@/components/ProtectedRoute';
-import { API_URL } from '@/services/api';
+import { useAuth } from '@/context/AuthContext';
+import { API_URL, authHeaders } from '@/services/api';
 import { StudentAttendanceRow, UserData } from '@/types/domain';
 
 const { width: SCREEN_WIDTH, height: SCREEN_HEIGHT } = Dimensions.get('window');
@@ -12,6 +13,7 @@ const isWeb = Platform.OS === 'web';
 export default function AttendanceListStudent() {
   const router = useRouter();
   const { courseId } = useLocalSearchParams();
+  const { userToken } = useAuth();
   const [hora, setHora] = useState('');
   const [loading, setLoading] = useState(true);
   const [error, setError] = useState<string | null>(null);
@@ -73,7 +75,7 @@ export default function AttendanceListStudent() {
         const url = `${API_URL}/api/db/attendance/student?seccion_id=${courseId}&alumno_id=${userData.alumnoId}`;
         console.log('Debug - Fetching URL:', url);
         
-        const response = await fetch(url);
+        const response = await fetch(url, { headers: authHeaders(userToken) });
         console.log('Debug - Response status:', response.status);
         
         if (!response.ok) {
@@ -102,7 +104,7 @@ export default function AttendanceListStudent() {
     };
 
     fetchStudentAttendance();
-  }, [courseId, userData]);
+  }, [courseId, userData, userToken]);
 
   useEffect(() => {
     const actualizarHora = () => {
@@ -4,7 +4,8 @@ import { useLocalSearchParams, useRouter } from 'expo-router';
 import ProtectedRoute from '@/components/ProtectedRoute';
 import { AntDesign } from '@expo/vector-icons';
 import * as XLSX from 'xlsx';
-import { API_URL } from '@/services/api';
+import { useAuth } from '@/context/AuthContext';
+import { API_URL, authHeaders } from '@/services/api';
 import { SectionAttendanceRow } from '@/types/domain';
 
 const { width: SCREEN_WIDTH, height: SCREEN_HEIGHT } = Dimensions.get('window');
@@ -13,6 +14,7 @@ const isWeb = Platform.OS === 'web';
 export default function AttendanceList() {
   const router = useRouter();
   const { courseId } = useLocalSearchParams();
+  const { userToken } = useAuth();
   const [students, setStudents] = useState<SectionAttendanceRow[]>([]);
   const [loading, setLoading] = useState(true);
   const [error, setError] = useState<string | null>(null);
@@ -26,7 +28,9 @@ export default function AttendanceList() {
     const fetchAttendanceData = async () => {
       try {
         setLoading(true);
-        const response = await fetch(`${API_URL}/api/db/attendance/report?seccion_id=${courseId}`);
+        const response = await fetch(`${API_URL}/api/db/attendance/report?seccion_id=${courseId}`, {
+          headers: authHeaders(userToken),
+        });
         if (!response.ok) {
           throw new Error('Error al cargar los datos de asistencia');
         }
@@ -53,7 +57,7 @@ export default function AttendanceList() {
     };
 
     fetchAttendanceData();
-  }, [courseId]);
+  }, [courseId, userToken]);
 
   // Función para calcular el porcentaje de asistencia de un estudiante
   const calcularPorcentajeEstudiante = (student: SectionAttendanceRow) => {
@@ -104,7 +108,7 @@ export default function AttendanceList() {
       // Primero eliminamos los registros manuales
       const response = await fetch(`${API_URL}/api/db/attendance/manual/delete`, {
         method: 'POST',
-        headers: { 'Content-Type': 'application/json' },
+        headers: { 'Content-Type': 'application/json', ...authHeaders(userToken) },
         body: JSON.stringify({ seccion_id: Number(courseId) }),
       });
 
@@ -145,7 +149,7 @@ export default function AttendanceList() {
 
               const response = await fetch(`${API_URL}/api/db/attendance/manual`, {
                 method: 'POST',
-                headers: { 'Content-Type': 'application/json' },
+                headers: { 'Content-Type': 'application/json', ...authHeaders(userToken) },
                 body: JSON.stringify(requestData),
               });
 
@@ -4,6 +4,7 @@ import { AntDesign } from '@expo/vector-icons';
 import { useRouter } from 'expo-router';
 import ProtectedRoute from '@/components/ProtectedRoute';
 import QRCode from 'react-native-qrcode-svg';
+import { useAuth } from '@/context/AuthContext';
 import { useStoredUserData } from '@/hooks/useStoredUserData';
 import { useProfessorSections, TeacherCourse } from '@/hooks/useProfessorSections';
 import { useTeacherQr } from '@/hooks/useTeacherQr';
@@ -26,6 +27,7 @@ const BLOQUES_HORARIOS = [
 export default function Courses() {
   const router = useRouter();
   const { userData } = useStoredUserData();
+  const { userToken } = useAuth();
   const { courses, setCourses } = useProfessorSections(userData?.profesorId);
   const [modalVisible, setModalVisible] = useState(false);
   const [qrVisible, setQrVisible] = useState(false);
@@ -81,7 +83,7 @@ export default function Courses() {
   );
 
   const handleFilePick = async () => {
-    const extracted = await csvImport.pickFile();
+    const extracted = await csvImport.pickFile(userToken || '');
     if (extracted) {
       setCit(extracted.codigo);
       setNombre(extracted.nombre);
@@ -91,10 +93,9 @@ export default function Courses() {
   const handleUpload = async () => {
     await csvImport.upload({
       cit,
-      nombre,
       dias: diasSeleccionados,
       bloque: bloqueSeleccionado,
-      profesorId: userData?.profesorId || '',
+      token: userToken || '',
     });
   };
 
@@ -1,5 +1,5 @@
 import AsyncStorage from '@react-native-async-storage/async-storage';
-import React, { createContext, ReactNode, useContext, useEffect, useState } from 'react';
+import React, { createContext, ReactNode, useContext, useEffect, useRef, useState } from 'react';
 import { API_URL } from '../services/api';
 
 interface UserData {
@@ -16,7 +16,7 @@ interface AuthContextType {
     userToken: string | null;
     userData: UserData | null;
     isLoading: boolean;
-    login: (token: string, userData: UserData) => Promise<void>;
+    login: (token: string, userData: UserData, refreshToken: string, expiresIn: number) => Promise<void>;
     logout: () => Promise<void>;
     checkAuthState: () => Promise<void>;
 }
@@ -32,22 +32,61 @@ export const AuthProvider: React.FC<AuthProviderProps> = ({ children }) => {
     const [userToken, setUserToken] = useState<string | null>(null);
     const [userData, setUserData] = useState<UserData | null>(null);
     const [isLoading, setIsLoading] = useState<boolean>(false);
+    const refreshTimer = useRef<ReturnType<typeof setTimeout> | null>(null);
 
     // Verificar si el usuario está autenticado al iniciar la app
     useEffect(() => {
         checkAuthState();
+        return () => {
+            if (refreshTimer.current) clearTimeout(refreshTimer.current);
+        };
     }, []);
 
+    // El access token dura pocos minutos: se renueva un minuto antes de vencer
+    // canjeando el refresh token (que rota en cada uso).
+    const scheduleRefresh = (expiresIn: number) => {
+        if (refreshTimer.current) clearTimeout(refreshTimer.current);
+        const delay = Math.max(expiresIn - 60, 10) * 1000;
+        refreshTimer.current = setTimeout(async () => {
+            const ok = await refreshSession();
+            if (!ok) await logout();
+        }, delay);
+    };
+
+    const refreshSession = async (): Promise<boolean> => {
+        try {
+            const refreshToken = await AsyncStorage.getItem('refreshToken');
+            if (!refreshToken) return false;
+
+            const response = await fetch(`${API_URL}/api/qr/refresh`, {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({ refresh_token: refreshToken }),
+            });
+            if (!response.ok) return false;
+
+            const data = await response.json();
+            await AsyncStorage.setItem('userToken', data.token);
+            await AsyncStorage.setItem('refreshToken', data.refresh_token);
+            setUserToken(data.token);
+            scheduleRefresh(data.expires_in);
+            return true;
+        } catch (error) {
+            console.error('Error renovando la sesión:', error);
+            return false;
+        }
+    };
+
     const checkAuthState = async (): Promise<void> => {
         try {
             const token = await AsyncStorage.getItem('userToken');
             const user = await AsyncStorage.getItem('userData');
             
             if (token && user) {
-                // Verificar si el token sigue siendo válido
-                const isValid = await validateToken(token);
+                // Renovar la sesión guardada: si el refresh token ya no sirve
+                // (expiró o fue revocado con logout), hay que volver a entrar
+                const isValid = await refreshSession();
                 if (isValid) {
-                    setUserToken(token);
                     setUserData(JSON.parse(user));
                     setIsAuthenticated(true);
                 } else {
@@ -62,35 +101,18 @@ export const AuthProvider: React.FC<AuthProviderProps> = ({ children }) => {
         }
     };
 
-    const validateToken = async (token: string): Promise<boolean> => {
-        try {
-            // Hacer una petición al backend para validar el token
-            const response = await fetch(`${API_URL}/api/qr/validate-token`, {
-                method: 'POST',
-                headers: {
-                    'Content-Type': 'application/json',
-                    'Authorization': `Bearer ${token}`
-                },
-            });
-
-            return response.ok;
-        } catch (error) {
-            console.error('Error validando token:', error);
-            return false;
-        }
-    };
-
-    const login = async (token: string, userData: UserData): Promise<void> => {
+    const login = async (token: string, userData: UserData, refreshToken: string, expiresIn: number): Promise<void> => {
         try {
             // Limpiar datos antiguos antes de guardar los nuevos
-            await AsyncStorage.removeItem('userToken');
-            await AsyncStorage.removeItem('userData');
+            await clearAuthData();
             await AsyncStorage.setItem('userToken', token);
+            await AsyncStorage.setItem('refreshToken', refreshToken);
             await AsyncStorage.setItem('userData', JSON.stringify(userData));
             
             setUserToken(token);
             setUserData(userData);
             setIsAuthenticated(true);
+            scheduleRefresh(expiresIn);
         } catch (error) {
             console.error('Error saving auth data:', error);
             throw error;
@@ -99,6 +121,18 @@ export const AuthProvider: React.FC<AuthProviderProps> = ({ children }) => {
 
     const logout = async (): Promise<void> => {
         try {
+            if (refreshTimer.current) clearTimeout(refreshTimer.current);
+            const token = await AsyncStorage.getItem('userToken');
+            const refreshToken = await AsyncStorage.getItem('refreshToken');
+            // Revoca la sesión en el backend; si falla igual se limpia local.
+            await fetch(`${API_URL}/api/qr/logout`, {
+                method: 'POST',
+                headers: {
+                    'Content-Type': 'application/json',
+                    ...(token ? { Authorization: `Bearer ${token}` } : {}),
+                },
+                body: JSON.stringify({ refresh_token: refreshToken }),
+            }).catch(() => undefined);
             await clearAuthData();
             setUserToken(null);
             setUserData(null);
@@ -110,6 +144,7 @@ export const AuthProvider: React.FC<AuthProviderProps> = ({ children }) => {
 
     const clearAuthData = async (): Promise<void> => {
         await AsyncStorage.removeItem('userToken');
+        await AsyncStorage.removeItem('refreshToken');
         await AsyncStorage.removeItem('userData');
     };
 
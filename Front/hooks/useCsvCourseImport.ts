@@ -1,22 +1,29 @@
 import * as DocumentPicker from 'expo-document-picker';
 import { useState } from 'react';
-import {
-  cleanStudentRow,
-  extractCourseCodeFromFilename,
-  extractCourseNameFromCsv,
-  parseCsvRows,
-  readTextFile,
-  uploadStudentBatches,
-} from '../services/csvImport';
+import { CanvasFile, ImportSummary, importCanvasCsv } from '../services/csvImport';
 
 export interface ExtractedCourse {
   codigo: string;
   nombre: string;
 }
 
+function describeChanges(summary: ImportSummary): string {
+  const parts = [
+    summary.new_section ? 'sección nueva' : `sección ${summary.section_id} existente`,
+    `${summary.added.length} altas`,
+    `${summary.removed.length} bajas`,
+    `${summary.changed.length} con datos nuevos`,
+    `${summary.unchanged} sin cambios`,
+  ];
+  if (summary.scheduled_modules > 0) parts.push(`${summary.scheduled_modules} clases por programar`);
+  if (summary.skipped.length > 0) parts.push(`${summary.skipped.length} filas omitidas`);
+  return parts.join(', ');
+}
+
 // Maneja la selección y subida del CSV de alumnos (export de Canvas) que
-// da de alta un curso completo. La pantalla solo llama pickFile/upload y
-// pinta uploadProgress/uploadStatus.
+// da de alta un curso completo. El archivo lo interpreta el backend: al
+// elegirlo se pide la vista previa y al subirlo se confirma. La pantalla
+// solo llama pickFile/upload y pinta uploadProgress/uploadStatus.
 export function useCsvCourseImport() {
   const [selectedFile, setSelectedFile] = useState<DocumentPicker.DocumentPickerResult | null>(null);
   const [uploadProgress, setUploadProgress] = useState(0);
@@ -28,93 +35,72 @@ export function useCsvCourseImport() {
     setUploadStatus('');
   };
 
-  // Abre el picker, valida el nombre del archivo y extrae código/nombre del
-  // curso. Devuelve null si el usuario canceló o el archivo no calza.
-  const pickFile = async (): Promise<ExtractedCourse | null> => {
+  const canvasFile = (result: DocumentPicker.DocumentPickerResult | null): CanvasFile | null => {
+    const asset = result?.assets?.[0];
+    return asset ? { uri: asset.uri, name: asset.name, mimeType: asset.mimeType } : null;
+  };
+
+  // Abre el picker y pide al backend la vista previa del archivo, de donde
+  // salen código y nombre del curso. Devuelve null si el usuario canceló o
+  // el archivo no sirve.
+  const pickFile = async (token: string): Promise<ExtractedCourse | null> => {
     try {
       const result = await DocumentPicker.getDocumentAsync({
         type: 'text/csv',
         copyToCacheDirectory: true,
       });
 
-      if (!result.assets || result.assets.length === 0) return null;
-
-      const fileName = result.assets[0].name;
-      const codigo = extractCourseCodeFromFilename(fileName);
-      if (!codigo) {
-        setUploadStatus('Error: El nombre del archivo debe contener el código en formato CIT1000_CA16');
-        return null;
-      }
+      const file = canvasFile(result);
+      if (!file) return null;
 
       setSelectedFile(result);
-      setUploadStatus('Archivo seleccionado: ' + fileName);
-
-      const fileContent = await readTextFile(result.assets[0].uri);
-      const nombre = extractCourseNameFromCsv(fileContent);
-      if (!nombre) {
-        setUploadStatus('Error: No se pudo extraer el nombre del curso del CSV');
-        return null;
-      }
+      setUploadProgress(0);
+      setUploadStatus('Revisando archivo...');
 
-      return { codigo, nombre };
+      const preview = await importCanvasCsv(file, { dias: [], bloque: '' }, token, false);
+      setUploadStatus(`Archivo seleccionado: ${file.name} (${describeChanges(preview)})`);
+      return { codigo: preview.course.code, nombre: preview.course.name };
     } catch (error) {
       console.error('Error al seleccionar archivo:', error);
-      setUploadStatus('Error al seleccionar archivo');
+      setSelectedFile(null);
+      setUploadStatus(`Error: ${error instanceof Error ? error.message : 'no se pudo leer el archivo'}`);
       return null;
     }
   };
 
   const upload = async (params: {
     cit: string;
-    nombre: string;
     dias: string[];
     bloque: string;
-    profesorId: string;
+    token: string;
   }) => {
-    if (!selectedFile?.assets || selectedFile.assets.length === 0) {
+    const file = canvasFile(selectedFile);
+    if (!file) {
       setUploadStatus('Por favor, seleccione un archivo primero');
       return;
     }
-    if (!params.cit || !params.nombre) {
+    if (!params.cit) {
       setUploadStatus('Error: No se pudo extraer el código o la sección del archivo');
       return;
     }
 
     try {
-      const fileContent = await readTextFile(selectedFile.assets[0].uri);
-      const students = parseCsvRows(fileContent)
-        .slice(1) // la primera fila es "Points Possible", no un alumno
-        .map(cleanStudentRow)
-        .filter((s): s is NonNullable<typeof s> => s !== null);
-
-      if (students.length === 0) {
-        setUploadStatus('No se encontraron alumnos válidos en el archivo');
-        return;
-      }
-
       setUploadStatus('Procesando archivo...');
       setUploadProgress(0);
 
-      await uploadStudentBatches(
-        students,
-        {
-          codigo: params.cit,
-          nombre: params.nombre,
-          dias: params.dias,
-          bloque: params.bloque ? params.bloque.slice(-5) : '',
-        },
-        params.profesorId,
-        (percent, statusText) => {
-          setUploadProgress(percent);
-          setUploadStatus(statusText);
-        },
+      const summary = await importCanvasCsv(
+        file,
+        { codigo: params.cit, dias: params.dias, bloque: params.bloque },
+        params.token,
+        true,
       );
 
-      setUploadStatus('¡Archivo procesado con éxito!');
+      setUploadProgress(100);
+      setUploadStatus(`¡Archivo procesado con éxito! ${describeChanges(summary)}`);
       setTimeout(reset, 2000);
     } catch (error) {
       console.error('Error procesando CSV:', error);
-      setUploadStatus('Error al procesar el archivo');
+      setUploadStatus(`Error al procesar el archivo: ${error instanceof Error ? error.message : ''}`);
     }
   };
 
@@ -1,4 +1,5 @@
 import { useEffect, useState } from 'react';
+import { useAuth } from '../context/AuthContext';
 import { getProfessorSections } from '../services/professorApi';
 import { CourseBase } from '../types/domain';
 
@@ -12,17 +13,13 @@ export interface TeacherCourse extends CourseBase {
 // cursos (dias/bloque quedan vacíos: hoy el backend no los modela, solo
 // existen para el alta manual de un curso desde la propia pantalla).
 export function useProfessorSections(profesorId: string | undefined) {
+  const { userToken } = useAuth();
   const [courses, setCourses] = useState<TeacherCourse[]>([]);
 
   useEffect(() => {
-    if (!profesorId) return;
-    const numId = parseInt(profesorId, 10);
-    if (isNaN(numId)) {
-      console.error('ID de profesor inválido');
-      return;
-    }
+    if (!profesorId || !userToken) return;
 
-    getProfessorSections(numId)
+    getProfessorSections(userToken)
       .then(secciones => {
         setCourses(secciones.map(seccion => ({
           id: seccion.seccion_id.toString(),
@@ -34,7 +31,7 @@ export function useProfessorSections(profesorId: string | undefined) {
         })));
       })
       .catch(error => console.error('Error al cargar las secciones:', error));
-  }, [profesorId]);
+  }, [profesorId, userToken]);
 
   return { courses, setCourses };
 }
@@ -1,4 +1,5 @@
 import { useEffect, useState } from 'react';
+import { useAuth } from '../context/AuthContext';
 import { getStudentSections } from '../services/studentApi';
 import { CourseBase } from '../types/domain';
 
@@ -9,17 +10,13 @@ export interface StudentCourse extends CourseBase {
 // Secciones en las que está inscrito el alumno, mapeadas a la forma que usa
 // la lista de cursos.
 export function useStudentCourses(alumnoId: string | number | undefined) {
+  const { userToken } = useAuth();
   const [courses, setCourses] = useState<StudentCourse[]>([]);
 
   useEffect(() => {
-    if (!alumnoId) return;
-    const numId = parseInt(alumnoId.toString(), 10);
-    if (isNaN(numId)) {
-      console.error('ID de alumno inválido');
-      return;
-    }
+    if (!alumnoId || !userToken) return;
 
-    getStudentSections(numId)
+    getStudentSections(userToken)
       .then(secciones => {
         setCourses(secciones.map(seccion => ({
           id: seccion.seccion_id.toString(),
@@ -29,7 +26,7 @@ export function useStudentCourses(alumnoId: string | number | undefined) {
         })));
       })
       .catch(error => console.error('Error al cargar las secciones:', error));
-  }, [alumnoId]);
+  }, [alumnoId, userToken]);
 
   return { courses };
 }
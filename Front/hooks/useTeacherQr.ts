@@ -14,17 +14,15 @@ export function useTeacherQr(profesorId: string | undefined, active: boolean) {
 
   // Carga informativa apenas se conoce el profesor, antes de abrir el modal.
   useEffect(() => {
-    if (!profesorId) return;
-    const numId = parseInt(profesorId, 10);
-    if (isNaN(numId)) return;
+    if (!profesorId || !userToken) return;
 
-    getCurrentClass(numId)
+    getCurrentClass(userToken)
       .then(setCurrentClass)
       .catch(error => {
         console.error('Error al cargar la clase actual:', error);
         setCurrentClass(null);
       });
-  }, [profesorId]);
+  }, [profesorId, userToken]);
 
   useEffect(() => {
     if (!active || !userToken) return;
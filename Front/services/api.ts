@@ -1,3 +1,9 @@
 // URL base del backend (Traefik). Único lugar que hay que tocar si cambia
 // la IP/host del servidor.
 export const API_URL = 'http://192.168.206.9:8088';
+
+// Header de autorización con el access token; todos los endpoints del
+// backend salvo login y registro lo exigen.
+export function authHeaders(token: string | null): Record<string, string> {
+  return token ? { Authorization: `Bearer ${token}` } : {};
+}
@@ -0,0 +1,19 @@
+import AsyncStorage from '@react-native-async-storage/async-storage';
+
+const DEVICE_ID_KEY = 'deviceId';
+
+// Identificador estable por instalación de la app. Se manda en cada login
+// para que la auditoría del backend (tabla LogIn) sepa desde qué equipo se
+// entró; no identifica al hardware, solo a esta instalación.
+export async function getDeviceId(): Promise<string> {
+  const stored = await AsyncStorage.getItem(DEVICE_ID_KEY);
+  if (stored) return stored;
+
+  const bytes = new Uint8Array(16);
+  for (let i = 0; i < bytes.length; i++) {
+    bytes[i] = Math.floor(Math.random() * 256);
+  }
+  const id = Array.from(bytes, b => b.toString(16).padStart(2, '0')).join('');
+  await AsyncStorage.setItem(DEVICE_ID_KEY, id);
+  return id;
+}
@@ -1,4 +1,4 @@
-import { API_URL } from './api';
+import { API_URL, authHeaders } from './api';
 import { SeccionAsignatura } from '../types/domain';
 
 export interface ModuleSection {
@@ -6,9 +6,10 @@ export interface ModuleSection {
   seccion_id: number;
 }
 
-// GET /api/db/sections/professor/:id — secciones que dicta un profesor.
-export async function getProfessorSections(profesorId: number): Promise<SeccionAsignatura[]> {
-  const response = await fetch(`${API_URL}/api/db/sections/professor/${profesorId}`);
+// GET /api/db/sections/professor/me — secciones que dicta el profesor
+// autenticado (el backend lo saca del token).
+export async function getProfessorSections(token: string): Promise<SeccionAsignatura[]> {
+  const response = await fetch(`${API_URL}/api/db/sections/professor/me`, { headers: authHeaders(token) });
   if (!response.ok) {
     throw new Error(`Error ${response.status}: ${await response.text()}`);
   }
@@ -17,10 +18,10 @@ export async function getProfessorSections(profesorId: number): Promise<SeccionA
 
 // GET /api/db/professor/current-class — módulo/sección vigente ahora mismo,
 // solo informativo (la fuente de verdad para emitir el QR es issueQr).
-export async function getCurrentClass(profesorId: number): Promise<ModuleSection | null> {
-  const response = await fetch(`${API_URL}/api/db/professor/current-class?profesor_id=${profesorId}`);
-  if (response.status === 404) {
-    return null;
+export async function getCurrentClass(token: string): Promise<ModuleSection | null> {
+  const response = await fetch(`${API_URL}/api/db/professor/current-class`, { headers: authHeaders(token) });
+  if (response.status === 404 || response.status === 409) {
+    return null; // sin clase, o dos secciones en el mismo horario (ver getCurrentClasses)
   }
   if (!response.ok) {
     throw new Error(`Error ${response.status}: ${await response.text()}`);
@@ -32,25 +33,55 @@ export async function getCurrentClass(profesorId: number): Promise<ModuleSection
   return null;
 }
 
+export interface CurrentClass {
+  module_id: number;
+  section_id: number;
+  course_code: string;
+  course_name: string;
+  date: string;
+  start_time: string;
+  end_time: string;
+  phase: 'in_progress' | 'upcoming' | 'ended';
+}
+
+// GET /api/classes/current — clases del profesor que pueden ser la actual,
+// de la más a la menos probable. Si dicta dos en el mismo horario hay que
+// pasarle a issueQr la que elija.
+export async function getCurrentClasses(token: string): Promise<CurrentClass[]> {
+  const response = await fetch(`${API_URL}/api/classes/current`, { headers: authHeaders(token) });
+  if (!response.ok) {
+    throw new Error(`Error ${response.status}: ${await response.text()}`);
+  }
+  return (await response.json()).classes;
+}
+
 export interface IssuedQr {
   encryptedQr: string;
   moduleSection: ModuleSection;
 }
 
 // POST /api/classes/start — le pide al backend un QR fresco para la clase
-// que le corresponde ahora mismo al profesor autenticado (servicio `teacher`).
-export async function issueQr(token: string): Promise<IssuedQr | null> {
+// que le corresponde ahora mismo al profesor autenticado (servicio `teacher`),
+// o para la que eligió de getCurrentClasses.
+export async function issueQr(
+  token: string,
+  choice?: { section_id: number; module_id?: number },
+): Promise<IssuedQr | null> {
   const response = await fetch(`${API_URL}/api/classes/start`, {
     method: 'POST',
     headers: {
       'Content-Type': 'application/json',
-      Authorization: `Bearer ${token}`,
+      ...authHeaders(token),
     },
+    ...(choice ? { body: JSON.stringify(choice) } : {}),
   });
 
   if (response.status === 404) {
     return null; // no hay clase programada en este momento
   }
+  if (response.status === 409) {
+    return null; // pausada, cerrada, o hay que elegir la sección
+  }
   if (!response.ok) {
     throw new Error(`Error ${response.status}: ${await response.text()}`);
   }
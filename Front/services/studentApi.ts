@@ -1,9 +1,10 @@
-import { API_URL } from './api';
+import { API_URL, authHeaders } from './api';
 import { SeccionAsignatura } from '../types/domain';
 
-// GET /api/db/sections/student/:id — secciones en las que está inscrito un alumno.
-export async function getStudentSections(alumnoId: number): Promise<SeccionAsignatura[]> {
-  const response = await fetch(`${API_URL}/api/db/sections/student/${alumnoId}`);
+// GET /api/db/sections/student/me — secciones en las que está inscrito el
+// alumno autenticado (el backend lo saca del token).
+export async function getStudentSections(token: string): Promise<SeccionAsignatura[]> {
+  const response = await fetch(`${API_URL}/api/db/sections/student/me`, { headers: authHeaders(token) });
   if (!response.ok) {
     throw new Error(`Error ${response.status}: ${await response.text()}`);
   }
@@ -25,7 +26,7 @@ export async function scanAttendance(qr: string, token: string): Promise<ScanRes
     method: 'POST',
     headers: {
       'Content-Type': 'application/json',
-      Authorization: `Bearer ${token}`,
+      ...authHeaders(token),
     },
     body: JSON.stringify({ qr }),
   });
@@ -30,7 +30,7 @@ export interface CourseBase {
 }
 
 // Fila del reporte de asistencia de toda una sección
-// (función SQL obtener_asistencia_por_seccion): una entrada por fecha con
+// (GET /api/db/attendance/report): una entrada por fecha con
 // el detalle de alumno/módulo.
 export interface SectionAttendanceRow {
   estudiante: string;
@@ -45,7 +45,7 @@ export interface SectionAttendanceRow {
 }
 
 // Reporte de asistencia de un único alumno
-// (función SQL obtener_asistencia_estudiante_seccion): una entrada por
+// (GET /api/db/attendance/student): una entrada por
 // fecha, solo el emoji de estado.
 export interface StudentAttendanceRow {
   estudiante: string;